@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/seokheejang/go/cache-layer/internal/api"
+	"github.com/seokheejang/go/cache-layer/internal/app"
+	"github.com/seokheejang/go/cache-layer/internal/config"
+	"github.com/seokheejang/go/cache-layer/internal/infrastructure/repository"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/admin"
+	"gorm.io/gorm/logger"
+)
+
+func main() {
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		log.Fatal("Failed to load config: ", err)
+	}
+	log.Printf("Effective config:\n%s", cfg)
+
+	application, err := app.New(cfg, logger.Info)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer application.Close()
+
+	handler := api.NewHandler(
+		repository.NewUserRepository(application.DB),
+		repository.NewRoleRepository(application.DB),
+	)
+	// Report not ready until the cache has been warmed up
+	handler.SetReady(false)
+
+	mux := http.NewServeMux()
+	mux.Handle("/", handler)
+	if cfg.Server.AdminToken != "" {
+		// Hot keys include SQL text and bind arguments, so they are only
+		// shown to operators
+		mux.Handle("GET /debug/hotkeys", admin.RequireToken(cfg.Server.AdminToken, api.HotKeysHandler(application.HotKeys)))
+		mux.Handle("/admin/", http.StripPrefix("/admin", admin.NewHandler(application.Cache, &admin.Options{
+			Token:    cfg.Server.AdminToken,
+			ReadOnly: cfg.Server.AdminReadOnly,
+			Logger:   log.Default(),
+		})))
+	}
+
+	srv := &http.Server{
+		Addr:              cfg.Server.Addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		log.Printf("Server is running on %s", cfg.Server.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	application.Warmup(ctx)
+	handler.SetReady(true)
+
+	<-ctx.Done()
+	log.Println("Shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Shutdown error: %v", err)
+	}
+}
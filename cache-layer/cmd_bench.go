@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/seokheejang/go/cache-layer/internal/app"
+	"github.com/seokheejang/go/cache-layer/internal/config"
+	"github.com/seokheejang/go/cache-layer/internal/domains/user"
+	"github.com/seokheejang/go/cache-layer/internal/infrastructure/repository"
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/hotkey"
+	"gorm.io/gorm/logger"
+)
+
+// benchResult holds the outcome of benchmarking a single backend
+type benchResult struct {
+	backend   string
+	elapsed   time.Duration
+	reads     []time.Duration
+	writes    []time.Duration
+	errors    int
+	stats     cache.Stats
+	coalesced uint64
+	hotKeys   []hotkey.HotKey
+}
+
+// runBench runs a read/write mix against every requested backend
+func runBench(args []string) error {
+	fs := newFlagSet("bench")
+	backends := fs.String("backends", "mem", "comma separated cache backends to compare (mem, redis)")
+	duration := fs.Duration("duration", 10*time.Second, "how long to run against each backend")
+	concurrency := fs.Int("concurrency", 8, "number of concurrent workers")
+	readRatio := fs.Float64("read-ratio", 0.95, "share of operations that are reads (0..1)")
+
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+	if *concurrency < 1 || *duration <= 0 || *readRatio < 0 || *readRatio > 1 {
+		return errors.New("concurrency and duration must be positive and read-ratio within 0..1")
+	}
+
+	var results []benchResult
+	for _, backend := range strings.Split(*backends, ",") {
+		backendCfg := *cfg
+		backendCfg.Cache.Type = strings.TrimSpace(backend)
+
+		res, err := benchBackend(&backendCfg, *duration, *concurrency, *readRatio)
+		if err != nil {
+			return fmt.Errorf("%s: %w", backend, err)
+		}
+		results = append(results, res)
+	}
+
+	printBench(results)
+	return nil
+}
+
+func benchBackend(cfg *config.Config, duration time.Duration, concurrency int, readRatio float64) (benchResult, error) {
+	res := benchResult{backend: cfg.Cache.Type}
+
+	application, err := app.New(cfg, logger.Silent)
+	if err != nil {
+		return res, err
+	}
+	defer application.Close()
+
+	ctx := context.Background()
+	// Writes keep each user's role, so that they only rename users
+	var rows []user.User
+	if err := application.DB.WithContext(ctx).Select("id", "role_id").Order("id").Find(&rows).Error; err != nil {
+		return res, err
+	}
+	if len(rows) == 0 {
+		return res, errors.New("no users found, run seed first")
+	}
+
+	// Start from a cold cache so that backends are compared fairly
+	if err := application.Cache.Clear(ctx); err != nil {
+		return res, err
+	}
+	provider, _ := application.Cache.(cache.StatsProvider)
+	var before cache.Stats
+	if provider != nil {
+		before, _ = provider.Stats(ctx)
+	}
+	dedupBefore := application.Dedup.Stats().Coalesced
+
+	users := repository.NewUserRepository(application.DB)
+	deadline := time.Now().Add(duration)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var reads, writes []time.Duration
+			errs := 0
+			for time.Now().Before(deadline) {
+				row := rows[rand.IntN(len(rows))]
+				opStart := time.Now()
+				if rand.Float64() < readRatio {
+					if _, err := users.Get(ctx, row.ID); err != nil {
+						errs++
+					}
+					reads = append(reads, time.Since(opStart))
+				} else {
+					u := &user.User{ID: row.ID, RoleID: row.RoleID, Name: fmt.Sprintf("user-%06d-%d", row.ID, rand.IntN(1000))}
+					if err := users.Update(ctx, u); err != nil {
+						errs++
+					}
+					writes = append(writes, time.Since(opStart))
+				}
+			}
+
+			mu.Lock()
+			res.reads = append(res.reads, reads...)
+			res.writes = append(res.writes, writes...)
+			res.errors += errs
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	res.elapsed = time.Since(start)
+
+	if provider != nil {
+		after, err := provider.Stats(ctx)
+		if err == nil {
+			res.stats = after.Sub(before)
+		}
+	}
+	res.coalesced = application.Dedup.Stats().Coalesced - dedupBefore
+	res.hotKeys = application.HotKeys()
+	return res, nil
+}
+
+func printBench(results []benchResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "backend\tops\tops/s\terrors\thit ratio\tcoalesced\tread p50\tread p90\tread p99\twrite p50\twrite p90\twrite p99\t")
+	for _, res := range results {
+		ops := len(res.reads) + len(res.writes)
+		slices.Sort(res.reads)
+		slices.Sort(res.writes)
+		fmt.Fprintf(w, "%s\t%d\t%.0f\t%d\t%.1f%%\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
+			res.backend,
+			ops,
+			float64(ops)/res.elapsed.Seconds(),
+			res.errors,
+			res.stats.HitRatio()*100,
+			res.coalesced,
+			percentile(res.reads, 0.50),
+			percentile(res.reads, 0.90),
+			percentile(res.reads, 0.99),
+			percentile(res.writes, 0.50),
+			percentile(res.writes, 0.90),
+			percentile(res.writes, 0.99),
+		)
+	}
+	w.Flush()
+
+	for _, res := range results {
+		if len(res.hotKeys) == 0 {
+			continue
+		}
+		fmt.Printf("\nHot keys (%s):\n", res.backend)
+		for i, k := range res.hotKeys {
+			if i == 5 {
+				fmt.Printf("  ... and %d more\n", len(res.hotKeys)-i)
+				break
+			}
+			fmt.Printf("  %6d  %s\n", k.Count, k.Key)
+		}
+	}
+}
+
+// percentile returns the p-th percentile of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(p * float64(len(sorted)-1))
+	return sorted[i].Round(time.Microsecond)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/memory"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/netcache"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// runCacheServer hosts an in-memory cache for other processes, which use it
+// with --cache=grpc. It listens on the loopback interface unless told
+// otherwise; clients must send --cache-grpc-token when it is set.
+func runCacheServer(args []string) error {
+	fs := newFlagSet("cache-server")
+	listen := fs.String("listen", "127.0.0.1:9090", "address to listen on")
+	tlsCert := fs.String("tls-cert", "", "TLS certificate file (serves plaintext if empty)")
+	tlsKey := fs.String("tls-key", "", "TLS private key file")
+	maxSize := fs.Int64("max-size", 100000, "maximum number of cache entries")
+	maxValueSize := fs.Int("max-value-size", netcache.DefaultMaxValueSize, "size of the largest value accepted, in bytes")
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+
+	c, err := memory.New(&cache.Options{
+		DefaultTTL: cfg.Cache.TTL,
+		MaxTTL:     cfg.Cache.MaxTTL,
+		MaxSize:    *maxSize,
+	})
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	lis, err := net.Listen("tcp", *listen)
+	if err != nil {
+		return err
+	}
+
+	// Leave room for the key and the rest of the message around values
+	opts := []grpc.ServerOption{grpc.MaxRecvMsgSize(*maxValueSize + 64<<10)}
+	if *tlsCert != "" || *tlsKey != "" {
+		creds, err := credentials.NewServerTLSFromFile(*tlsCert, *tlsKey)
+		if err != nil {
+			return fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	if cfg.Cache.GRPCToken != "" {
+		opts = append(opts, grpc.UnaryInterceptor(netcache.TokenInterceptor(cfg.Cache.GRPCToken)))
+	} else {
+		log.Printf("No --cache-grpc-token set, any client reaching %s can read and clear the cache", *listen)
+	}
+	g := grpc.NewServer(opts...)
+	netcache.NewServer(c, &netcache.ServerOptions{MaxValueSize: *maxValueSize}).Register(g)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		g.GracefulStop()
+	}()
+
+	log.Printf("Cache server listening on %s", lis.Addr())
+	if err := g.Serve(lis); err != nil {
+		return fmt.Errorf("serve: %w", err)
+	}
+	return nil
+}
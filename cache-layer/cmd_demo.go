@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/seokheejang/go/cache-layer/internal/domains/user"
+	"gorm.io/gorm/logger"
+)
+
+// runDemo seeds two users and shows a cache miss followed by a cache hit
+func runDemo(args []string) error {
+	fs := newFlagSet("demo")
+	application, err := openApp(fs, args, logger.Info)
+	if err != nil {
+		return err
+	}
+	defer application.Close()
+	db := application.DB
+
+	// Clean up existing data
+	if err := db.Exec("DELETE FROM users").Error; err != nil {
+		return err
+	}
+	if err := db.Exec("DELETE FROM user_roles").Error; err != nil {
+		return err
+	}
+
+	// Create roles
+	adminRole := &user.UserRole{
+		Name: "Admin",
+	}
+	if err := db.Create(adminRole).Error; err != nil {
+		return err
+	}
+
+	guestRole := &user.UserRole{
+		Name: "Guest",
+	}
+	if err := db.Create(guestRole).Error; err != nil {
+		return err
+	}
+
+	// Create users
+	adminUser := &user.User{
+		Name:   "admin",
+		RoleID: adminRole.ID,
+		Role:   adminRole,
+	}
+	if err := db.Create(adminUser).Error; err != nil {
+		return err
+	}
+
+	guestUser := &user.User{
+		Name:   "guest",
+		RoleID: guestRole.ID,
+		Role:   guestRole,
+	}
+	if err := db.Create(guestUser).Error; err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	fmt.Println("\n=== Starting Cache Test ===")
+
+	fmt.Println("\n[Test 1] First query (expected cache miss)")
+	var users1 []user.User
+	if err := db.WithContext(ctx).Find(&users1, "name = ?", "admin").Error; err != nil {
+		log.Printf("DB error: %v", err)
+	}
+	if len(users1) > 0 {
+		fmt.Printf("Result: %+v\n", users1[0])
+	}
+
+	// Second query (cache hit)
+	fmt.Println("\n[Test 2] Second query (expected cache hit)")
+	var users2 []user.User
+	if err := db.WithContext(ctx).Find(&users2, "name = ?", "admin").Error; err != nil {
+		log.Printf("DB error: %v", err)
+	}
+	if len(users2) > 0 {
+		fmt.Printf("Result: %+v\n", users2[0])
+	}
+
+	stats := application.Dedup.Stats()
+	fmt.Printf("\nQueries executed: %d, coalesced: %d\n", stats.Executed, stats.Coalesced)
+
+	fmt.Println("\n=== Cache Test Completed ===")
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/seokheejang/go/cache-layer/internal/app"
+	"github.com/seokheejang/go/cache-layer/internal/domains/user"
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	gormCache "github.com/seokheejang/go/cache-layer/pkg/cache/gorm"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// runQuery runs the same lookup several times and shows whether each run
+// was served from the cache
+func runQuery(args []string) error {
+	fs := newFlagSet("query")
+	id := fs.Uint("id", 0, "look up a user by id")
+	name := fs.String("name", "", "look up users by name")
+	role := fs.String("role", "", "look up users by role name")
+	limit := fs.Int("limit", 10, "maximum number of users to print")
+	repeat := fs.Int("repeat", 2, "number of times to run the query")
+	noCache := fs.Bool("no-cache", false, "bypass the cache")
+	verbose := fs.Bool("v", false, "log SQL statements")
+
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+
+	logLevel := logger.Warn
+	if *verbose {
+		logLevel = logger.Info
+	}
+	application, err := app.New(cfg, logLevel)
+	if err != nil {
+		return err
+	}
+	defer application.Close()
+
+	ctx := context.Background()
+	if *noCache {
+		ctx = gormCache.NoCache(ctx)
+	}
+
+	db := application.DB.WithContext(ctx).Preload("Role").Order("id").Limit(*limit)
+	switch {
+	case *id != 0:
+		db = db.Where("id = ?", *id)
+	case *name != "":
+		db = db.Where("name = ?", *name)
+	case *role != "":
+		db = db.Where("role_id IN (?)", application.DB.Model(&user.UserRole{}).Select("id").Where("name = ?", *role))
+	}
+
+	stats, _ := application.Cache.(cache.StatsProvider)
+	for i := 1; i <= *repeat; i++ {
+		var before cache.Stats
+		if stats != nil {
+			before, _ = stats.Stats(ctx)
+		}
+
+		var users []user.User
+		start := time.Now()
+		err := db.Session(&gorm.Session{}).Find(&users).Error
+		elapsed := time.Since(start)
+		if err != nil {
+			return err
+		}
+
+		source := "unknown"
+		if stats != nil {
+			after, _ := stats.Stats(ctx)
+			delta := after.Sub(before)
+			switch {
+			case delta.Misses > 0:
+				source = "cache miss"
+			case delta.Hits > 0:
+				source = "cache hit"
+			}
+		}
+		if *noCache {
+			source = "cache bypassed"
+		}
+
+		fmt.Printf("\n[Run %d] %d users in %s (%s)\n", i, len(users), elapsed, source)
+		for _, u := range users {
+			roleName := ""
+			if u.Role != nil {
+				roleName = u.Role.Name
+			}
+			fmt.Printf("  #%d %s (role: %s)\n", u.ID, u.Name, roleName)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand/v2"
+
+	"github.com/seokheejang/go/cache-layer/internal/domains/user"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// runSeed generates roles and users, replacing existing ones by default
+func runSeed(args []string) error {
+	fs := newFlagSet("seed")
+	users := fs.Int("users", 1000, "number of users to create")
+	roles := fs.Int("roles", 5, "number of roles to create")
+	batch := fs.Int("batch", 500, "insert batch size")
+	reset := fs.Bool("reset", true, "delete existing users and roles first")
+
+	application, err := openApp(fs, args, logger.Warn)
+	if err != nil {
+		return err
+	}
+	defer application.Close()
+
+	if *roles < 1 || *users < 0 || *batch < 1 {
+		return fmt.Errorf("roles and batch must be positive, users must not be negative")
+	}
+
+	db := application.DB.WithContext(context.Background())
+	if *reset {
+		// Deleting through GORM (rather than Exec) also invalidates the cache
+		all := db.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped()
+		if err := all.Delete(&user.User{}).Error; err != nil {
+			return err
+		}
+		if err := all.Delete(&user.UserRole{}).Error; err != nil {
+			return err
+		}
+	}
+
+	roleRows := make([]user.UserRole, *roles)
+	for i := range roleRows {
+		roleRows[i].Name = fmt.Sprintf("role-%d", i+1)
+	}
+	if err := db.CreateInBatches(roleRows, *batch).Error; err != nil {
+		return err
+	}
+
+	userRows := make([]user.User, *users)
+	for i := range userRows {
+		userRows[i].Name = fmt.Sprintf("user-%06d", i+1)
+		userRows[i].RoleID = roleRows[rand.IntN(len(roleRows))].ID
+	}
+	if len(userRows) > 0 {
+		if err := db.CreateInBatches(userRows, *batch).Error; err != nil {
+			return err
+		}
+	}
+
+	log.Printf("Seeded %d roles and %d users", len(roleRows), len(userRows))
+	return nil
+}
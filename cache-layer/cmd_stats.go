@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/seokheejang/go/cache-layer/internal/domains/user"
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	gormCache "github.com/seokheejang/go/cache-layer/pkg/cache/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// runStats prints the statistics of the configured cache backend
+func runStats(args []string) error {
+	fs := newFlagSet("stats")
+	application, err := openApp(fs, args, logger.Warn)
+	if err != nil {
+		return err
+	}
+	defer application.Close()
+
+	ctx := gormCache.NoCache(context.Background())
+
+	var users, roles int64
+	if err := application.DB.WithContext(ctx).Model(&user.User{}).Count(&users).Error; err != nil {
+		return err
+	}
+	if err := application.DB.WithContext(ctx).Model(&user.UserRole{}).Count(&roles).Error; err != nil {
+		return err
+	}
+	fmt.Printf("Database: %d users, %d roles\n", users, roles)
+
+	provider, ok := application.Cache.(cache.StatsProvider)
+	if !ok {
+		return errors.New("cache backend does not report statistics")
+	}
+	stats, err := provider.Stats(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Cache (%s):\n", application.Config.Cache.Type)
+	printStats(stats)
+	if application.Config.Cache.Type == "mem" {
+		fmt.Println("Note: the in-memory cache only lives as long as this process; use bench to see it under load.")
+	}
+	return nil
+}
+
+func printStats(stats cache.Stats) {
+	fmt.Printf("  entries:   %d\n", stats.Entries)
+	fmt.Printf("  hits:      %d\n", stats.Hits)
+	fmt.Printf("  misses:    %d\n", stats.Misses)
+	fmt.Printf("  hit ratio: %.1f%%\n", stats.HitRatio()*100)
+	fmt.Printf("  sets:      %d\n", stats.Sets)
+	fmt.Printf("  deletes:   %d\n", stats.Deletes)
+	fmt.Printf("  evictions: %d\n", stats.Evictions)
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/seokheejang/go/cache-layer/pkg/cache/hotkey"
+)
+
+type hotKeyResponse struct {
+	Key   string `json:"key"`
+	Count uint32 `json:"count"`
+}
+
+// HotKeysHandler serves the keys currently detected as hot, hottest first
+func HotKeysHandler(hotKeys func() []hotkey.HotKey) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys := hotKeys()
+		items := make([]hotKeyResponse, 0, len(keys))
+		for _, k := range keys {
+			items = append(items, hotKeyResponse{Key: k.Key, Count: k.Count})
+		}
+		writeJSON(w, http.StatusOK, items)
+	})
+}
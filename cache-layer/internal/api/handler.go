@@ -0,0 +1,410 @@
+// Package api exposes the user and role repositories as a JSON REST API.
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/seokheejang/go/cache-layer/internal/domains/user"
+)
+
+const maxPageSize = 100
+
+type roleResponse struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type userResponse struct {
+	ID        uint          `json:"id"`
+	Name      string        `json:"name"`
+	RoleID    uint          `json:"role_id"`
+	Role      *roleResponse `json:"role,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+type pageResponse[T any] struct {
+	Items []T   `json:"items"`
+	Page  int   `json:"page"`
+	Size  int   `json:"size"`
+	Total int64 `json:"total"`
+}
+
+type userRequest struct {
+	Name   string `json:"name"`
+	RoleID uint   `json:"role_id"`
+}
+
+type roleRequest struct {
+	Name string `json:"name"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func toRoleResponse(r *user.UserRole) *roleResponse {
+	if r == nil {
+		return nil
+	}
+	return &roleResponse{ID: r.ID, Name: r.Name, CreatedAt: r.CreatedAt, UpdatedAt: r.UpdatedAt}
+}
+
+func toUserResponse(u *user.User) userResponse {
+	return userResponse{
+		ID:        u.ID,
+		Name:      u.Name,
+		RoleID:    u.RoleID,
+		Role:      toRoleResponse(u.Role),
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+	}
+}
+
+// Handler serves the REST API
+type Handler struct {
+	users user.UserRepository
+	roles user.RoleRepository
+	mux   *http.ServeMux
+	ready atomic.Bool
+}
+
+// NewHandler creates the REST API handler
+func NewHandler(users user.UserRepository, roles user.RoleRepository) *Handler {
+	h := &Handler{
+		users: users,
+		roles: roles,
+		mux:   http.NewServeMux(),
+	}
+	h.ready.Store(true)
+
+	h.mux.HandleFunc("GET /healthz", h.healthz)
+	h.mux.HandleFunc("GET /readyz", h.readyz)
+
+	h.mux.HandleFunc("GET /users", h.listUsers)
+	h.mux.HandleFunc("POST /users", h.createUser)
+	h.mux.HandleFunc("GET /users/{id}", h.getUser)
+	h.mux.HandleFunc("PUT /users/{id}", h.updateUser)
+	h.mux.HandleFunc("DELETE /users/{id}", h.deleteUser)
+
+	h.mux.HandleFunc("GET /roles", h.listRoles)
+	h.mux.HandleFunc("POST /roles", h.createRole)
+	h.mux.HandleFunc("GET /roles/{id}", h.getRole)
+	h.mux.HandleFunc("PUT /roles/{id}", h.updateRole)
+	h.mux.HandleFunc("DELETE /roles/{id}", h.deleteRole)
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// SetReady controls whether /readyz reports the service as ready to take
+// traffic, e.g. to hold it back while the cache is warming up
+func (h *Handler) SetReady(ready bool) {
+	h.ready.Store(ready)
+}
+
+func (h *Handler) readyz(w http.ResponseWriter, r *http.Request) {
+	if !h.ready.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "warming up"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+}
+
+func (h *Handler) listUsers(w http.ResponseWriter, r *http.Request) {
+	page, err := parsePage(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	users, total, err := h.users.List(r.Context(), page)
+	if err != nil {
+		writeRepoError(w, err)
+		return
+	}
+
+	items := make([]userResponse, 0, len(users))
+	for i := range users {
+		items = append(items, toUserResponse(&users[i]))
+	}
+	writeJSON(w, http.StatusOK, pageResponse[userResponse]{Items: items, Page: page.Number, Size: page.Size, Total: total})
+}
+
+func (h *Handler) getUser(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	u, err := h.users.Get(r.Context(), id)
+	if err != nil {
+		writeRepoError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toUserResponse(u))
+}
+
+func (h *Handler) createUser(w http.ResponseWriter, r *http.Request) {
+	req, ok := h.decodeUser(w, r)
+	if !ok {
+		return
+	}
+
+	u := &user.User{Name: req.Name, RoleID: req.RoleID}
+	if err := h.users.Create(r.Context(), u); err != nil {
+		writeRepoError(w, err)
+		return
+	}
+
+	created, err := h.users.Get(r.Context(), u.ID)
+	if err != nil {
+		writeRepoError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, toUserResponse(created))
+}
+
+func (h *Handler) updateUser(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	req, ok := h.decodeUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.users.Update(r.Context(), &user.User{ID: id, Name: req.Name, RoleID: req.RoleID}); err != nil {
+		writeRepoError(w, err)
+		return
+	}
+
+	updated, err := h.users.Get(r.Context(), id)
+	if err != nil {
+		writeRepoError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toUserResponse(updated))
+}
+
+func (h *Handler) deleteUser(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.users.Delete(r.Context(), id); err != nil {
+		writeRepoError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeUser reads and validates a user request body, checking that the role exists
+func (h *Handler) decodeUser(w http.ResponseWriter, r *http.Request) (*userRequest, bool) {
+	var req userRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, errors.New("name is required"))
+		return nil, false
+	}
+
+	if _, err := h.roles.Get(r.Context(), req.RoleID); err != nil {
+		if errors.Is(err, user.ErrNotFound) {
+			writeError(w, http.StatusBadRequest, errors.New("role_id does not exist"))
+		} else {
+			writeRepoError(w, err)
+		}
+		return nil, false
+	}
+	return &req, true
+}
+
+func (h *Handler) listRoles(w http.ResponseWriter, r *http.Request) {
+	page, err := parsePage(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	roles, total, err := h.roles.List(r.Context(), page)
+	if err != nil {
+		writeRepoError(w, err)
+		return
+	}
+
+	items := make([]roleResponse, 0, len(roles))
+	for i := range roles {
+		items = append(items, *toRoleResponse(&roles[i]))
+	}
+	writeJSON(w, http.StatusOK, pageResponse[roleResponse]{Items: items, Page: page.Number, Size: page.Size, Total: total})
+}
+
+func (h *Handler) getRole(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	role, err := h.roles.Get(r.Context(), id)
+	if err != nil {
+		writeRepoError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toRoleResponse(role))
+}
+
+func (h *Handler) createRole(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeRole(w, r)
+	if !ok {
+		return
+	}
+
+	role := &user.UserRole{Name: req.Name}
+	if err := h.roles.Create(r.Context(), role); err != nil {
+		writeRepoError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, toRoleResponse(role))
+}
+
+func (h *Handler) updateRole(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	req, ok := decodeRole(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.roles.Update(r.Context(), &user.UserRole{ID: id, Name: req.Name}); err != nil {
+		writeRepoError(w, err)
+		return
+	}
+
+	updated, err := h.roles.Get(r.Context(), id)
+	if err != nil {
+		writeRepoError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toRoleResponse(updated))
+}
+
+func (h *Handler) deleteRole(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.roles.Delete(r.Context(), id); err != nil {
+		writeRepoError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeRole reads and validates a role request body
+func decodeRole(w http.ResponseWriter, r *http.Request) (*roleRequest, bool) {
+	var req roleRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, errors.New("name is required"))
+		return nil, false
+	}
+	return &req, true
+}
+
+func parseID(r *http.Request) (uint, error) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil || id == 0 {
+		return 0, errors.New("invalid id")
+	}
+	return uint(id), nil
+}
+
+func parsePage(r *http.Request) (user.Page, error) {
+	page := user.Page{Number: 1, Size: user.DefaultPageSize}
+	query := r.URL.Query()
+
+	if v := query.Get("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return page, errors.New("page must be a positive integer")
+		}
+		page.Number = n
+	}
+	if v := query.Get("size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			return page, errors.New("size must be between 1 and " + strconv.Itoa(maxPageSize))
+		}
+		page.Size = n
+	}
+	return page, nil
+}
+
+func decodeJSON(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return errors.New("invalid request body: " + err.Error())
+	}
+	return nil
+}
+
+func writeRepoError(w http.ResponseWriter, err error) {
+	if errors.Is(err, user.ErrNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	log.Printf("api: %v", err)
+	writeError(w, http.StatusInternalServerError, errors.New("internal server error"))
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("api: failed to write response: %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/seokheejang/go/cache-layer/internal/domains/user"
+	"github.com/seokheejang/go/cache-layer/internal/infrastructure/repository"
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	gormCache "github.com/seokheejang/go/cache-layer/pkg/cache/gorm"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/memory"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupHandler(t *testing.T) *Handler {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "api.db")), &gorm.Config{})
+	assert.NoError(t, err)
+
+	memCache, err := memory.New(&cache.Options{DefaultTTL: time.Minute, MaxTTL: time.Minute, MaxSize: 1000})
+	assert.NoError(t, err)
+	assert.NoError(t, gormCache.WithGormCache(db, memCache))
+	assert.NoError(t, db.AutoMigrate(&user.UserRole{}, &user.User{}))
+
+	return NewHandler(repository.NewUserRepository(db), repository.NewRoleRepository(db))
+}
+
+func do(t *testing.T, h http.Handler, method, path string, body interface{}) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		assert.NoError(t, json.NewEncoder(&buf).Encode(body))
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, path, &buf))
+	return rec
+}
+
+func decode[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	var v T
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&v))
+	return v
+}
+
+func TestHandler_UsersAndRoles(t *testing.T) {
+	h := setupHandler(t)
+
+	rec := do(t, h, http.MethodPost, "/roles", roleRequest{Name: "Admin"})
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	admin := decode[roleResponse](t, rec)
+
+	rec = do(t, h, http.MethodPost, "/roles", roleRequest{Name: "Guest"})
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	guest := decode[roleResponse](t, rec)
+
+	rec = do(t, h, http.MethodPost, "/users", userRequest{Name: "admin", RoleID: admin.ID})
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	created := decode[userResponse](t, rec)
+	assert.Equal(t, "admin", created.Name)
+	if assert.NotNil(t, created.Role) {
+		assert.Equal(t, "Admin", created.Role.Name)
+	}
+
+	t.Run("cached read", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			rec := do(t, h, http.MethodGet, "/users/1", nil)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "admin", decode[userResponse](t, rec).Name)
+		}
+	})
+
+	t.Run("writes invalidate cached reads", func(t *testing.T) {
+		rec := do(t, h, http.MethodPut, "/users/1", userRequest{Name: "root", RoleID: guest.ID})
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		rec = do(t, h, http.MethodGet, "/users/1", nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		updated := decode[userResponse](t, rec)
+		assert.Equal(t, "root", updated.Name)
+		if assert.NotNil(t, updated.Role) {
+			assert.Equal(t, "Guest", updated.Role.Name)
+		}
+	})
+
+	t.Run("paging", func(t *testing.T) {
+		for _, name := range []string{"u2", "u3", "u4"} {
+			rec := do(t, h, http.MethodPost, "/users", userRequest{Name: name, RoleID: guest.ID})
+			assert.Equal(t, http.StatusCreated, rec.Code)
+		}
+
+		rec := do(t, h, http.MethodGet, "/users?page=2&size=3", nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		page := decode[pageResponse[userResponse]](t, rec)
+		assert.Equal(t, int64(4), page.Total)
+		assert.Equal(t, 2, page.Page)
+		if assert.Len(t, page.Items, 1) {
+			assert.Equal(t, "u4", page.Items[0].Name)
+		}
+
+		rec = do(t, h, http.MethodGet, "/roles", nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Len(t, decode[pageResponse[roleResponse]](t, rec).Items, 2)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		rec := do(t, h, http.MethodDelete, "/users/1", nil)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		rec = do(t, h, http.MethodGet, "/users/1", nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		rec = do(t, h, http.MethodDelete, "/users/1", nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("role update", func(t *testing.T) {
+		rec := do(t, h, http.MethodPut, "/roles/2", roleRequest{Name: "Visitor"})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "Visitor", decode[roleResponse](t, rec).Name)
+	})
+}
+
+func TestHandler_BadRequests(t *testing.T) {
+	h := setupHandler(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+		status int
+	}{
+		{"invalid id", http.MethodGet, "/users/abc", nil, http.StatusBadRequest},
+		{"missing user", http.MethodGet, "/users/42", nil, http.StatusNotFound},
+		{"invalid page", http.MethodGet, "/users?page=0", nil, http.StatusBadRequest},
+		{"page too large", http.MethodGet, "/users?size=1000", nil, http.StatusBadRequest},
+		{"empty name", http.MethodPost, "/roles", roleRequest{Name: " "}, http.StatusBadRequest},
+		{"unknown role", http.MethodPost, "/users", userRequest{Name: "x", RoleID: 9}, http.StatusBadRequest},
+		{"unknown field", http.MethodPost, "/roles", map[string]string{"title": "x"}, http.StatusBadRequest},
+		{"method not allowed", http.MethodPatch, "/users/1", nil, http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(t, h, tt.method, tt.path, tt.body)
+			assert.Equal(t, tt.status, rec.Code)
+		})
+	}
+}
+
+func TestHandler_Readiness(t *testing.T) {
+	h := setupHandler(t)
+
+	assert.Equal(t, http.StatusOK, do(t, h, http.MethodGet, "/readyz", nil).Code)
+
+	h.SetReady(false)
+	assert.Equal(t, http.StatusServiceUnavailable, do(t, h, http.MethodGet, "/readyz", nil).Code)
+	assert.Equal(t, http.StatusOK, do(t, h, http.MethodGet, "/healthz", nil).Code)
+
+	h.SetReady(true)
+	assert.Equal(t, http.StatusOK, do(t, h, http.MethodGet, "/readyz", nil).Code)
+}
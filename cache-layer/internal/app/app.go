@@ -0,0 +1,304 @@
+// Package app wires the database, the cache backend and the GORM cache
+// plugin together from the config.
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/seokheejang/go/cache-layer/internal/config"
+	"github.com/seokheejang/go/cache-layer/internal/domains/user"
+	"github.com/seokheejang/go/cache-layer/internal/infrastructure/database"
+	cachePkg "github.com/seokheejang/go/cache-layer/pkg/cache"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/bloom"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/breaker"
+	gormCache "github.com/seokheejang/go/cache-layer/pkg/cache/gorm"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/hotkey"
+	memcachedCache "github.com/seokheejang/go/cache-layer/pkg/cache/memcached"
+	memoryCache "github.com/seokheejang/go/cache-layer/pkg/cache/memory"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/netcache"
+	redisCache "github.com/seokheejang/go/cache-layer/pkg/cache/redis"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// App holds the cached database connection
+type App struct {
+	Config *config.Config
+	DB     *gorm.DB
+	Cache  cachePkg.Cache
+	Dedup  *gormCache.Deduplicator
+	// Filter is the Bloom filter guarding primary key lookups, if enabled
+	Filter bloom.Filter
+
+	stopRebuild context.CancelFunc
+	bloomClient *redis.Client
+}
+
+// New opens the database, creates the cache backend, applies the GORM cache
+// plugin and migrates the user tables
+func New(cfg *config.Config, logLevel logger.LogLevel) (*App, error) {
+	// Configure GORM logger
+	newLogger := logger.New(
+		log.New(log.Writer(), "\r\n", log.LstdFlags),
+		logger.Config{
+			SlowThreshold:             time.Second,
+			LogLevel:                  logLevel,
+			IgnoreRecordNotFoundError: true,
+			Colorful:                  true,
+		},
+	)
+
+	log.Printf("Connecting to %s", database.Describe(cfg.Database))
+	db, err := database.Open(cfg.Database, &gorm.Config{
+		Logger: newLogger,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cacheService, err := NewCache(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &App{
+		Config:      cfg,
+		DB:          db,
+		Cache:       cacheService,
+		Dedup:       gormCache.NewDeduplicator(),
+		stopRebuild: func() {},
+	}
+	a.Filter, a.bloomClient = newFilter(cfg)
+
+	// Apply GORM cache plugin, coalescing identical in-flight queries on a miss
+	if err := gormCache.WithGormCacheOptions(db, cacheService, &gormCache.Options{
+		Deduplicator: a.Dedup,
+		Filter:       a.Filter,
+	}); err != nil {
+		a.Close()
+		return nil, fmt.Errorf("failed to apply cache plugin: %w", err)
+	}
+
+	// Run migrations
+	if err := db.AutoMigrate(&user.UserRole{}, &user.User{}); err != nil {
+		a.Close()
+		return nil, fmt.Errorf("failed to migrate: %w", err)
+	}
+
+	if a.Filter != nil {
+		if err := a.startFilter(); err != nil {
+			a.Close()
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// newFilter creates the Bloom filter selected by cfg.Cache.Bloom, along with
+// the Redis client it owns
+func newFilter(cfg *config.Config) (bloom.Filter, *redis.Client) {
+	options := &bloom.Options{
+		ExpectedItems:     uint64(cfg.Cache.BloomExpectedItems),
+		FalsePositiveRate: cfg.Cache.BloomFalsePositiveRate,
+	}
+
+	switch cfg.Cache.Bloom {
+	case "mem":
+		return bloom.NewMemory(options), nil
+	case "redis":
+		rdb := redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		})
+		return bloom.NewRedis(rdb, "cache-layer:bloom", options), rdb
+	default:
+		return nil, nil
+	}
+}
+
+// startFilter fills the Bloom filter from the database and keeps rebuilding
+// it in the background, so that deleted rows eventually drop out of it
+func (a *App) startFilter() error {
+	source := gormCache.FilterSource(a.DB, &user.UserRole{}, &user.User{})
+
+	start := time.Now()
+	if err := a.Filter.Rebuild(context.Background(), source); err != nil {
+		return fmt.Errorf("failed to build Bloom filter: %w", err)
+	}
+	log.Printf("Bloom filter (%s) built in %s", a.Config.Cache.Bloom, time.Since(start))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.stopRebuild = cancel
+	go bloom.RebuildEvery(ctx, a.Filter, source, a.Config.Cache.BloomRebuildInterval, func(err error) {
+		log.Printf("Failed to rebuild Bloom filter: %v", err)
+	})
+	return nil
+}
+
+// NewCache creates the cache backend selected by cfg.Cache.Type
+func NewCache(cfg *config.Config) (cachePkg.Cache, error) {
+	switch cfg.Cache.Type {
+	case "mem":
+		log.Println("Using in-memory cache")
+
+		cacheService, err := memoryCache.New(&cachePkg.Options{
+			DefaultTTL: cfg.Cache.TTL,
+			MaxTTL:     cfg.Cache.MaxTTL,
+			MaxSize:    1000,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create memory cache: %w", err)
+		}
+		return cacheService, nil
+	case "redis":
+		log.Println("Using Redis cache")
+
+		rdb := redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		})
+		// The prefix keeps Clear and the admin API away from the Bloom
+		// filter and any other data sharing the database
+		redisService, err := redisCache.NewWithOptions(rdb, &redisCache.Options{
+			Options: cachePkg.Options{
+				DefaultTTL: cfg.Cache.TTL,
+				MaxTTL:     cfg.Cache.MaxTTL,
+			},
+			Prefix: "cache-layer:cache:",
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Redis cache: %w", err)
+		}
+
+		// Fall through to the database instead of blocking when Redis is down
+		guarded := guard("Redis", redisService, cfg.Cache.ErrorPolicy)
+		if cfg.Cache.HotKeyThreshold == 0 {
+			return guarded, nil
+		}
+
+		// Serve the most read keys from short-lived local copies so that
+		// they do not all land on a single Redis shard
+		return hotkey.New(guarded, &hotkey.Options{
+			Threshold: uint32(cfg.Cache.HotKeyThreshold),
+			LocalTTL:  cfg.Cache.HotKeyTTL,
+		}), nil
+	case "memcached":
+		log.Printf("Using memcached cache at %s", cfg.Cache.MemcachedServers)
+
+		servers := strings.Split(cfg.Cache.MemcachedServers, ",")
+		for i := range servers {
+			servers[i] = strings.TrimSpace(servers[i])
+		}
+		client, err := memcachedCache.NewClient(servers...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create memcached client: %w", err)
+		}
+		memcachedService, err := memcachedCache.New(client, &memcachedCache.Options{
+			Options: cachePkg.Options{
+				DefaultTTL: cfg.Cache.TTL,
+				MaxTTL:     cfg.Cache.MaxTTL,
+			},
+			Namespace: "cache-layer",
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create memcached cache: %w", err)
+		}
+		return guard("Memcached", memcachedService, cfg.Cache.ErrorPolicy), nil
+	case "grpc":
+		log.Printf("Using cache server at %s", cfg.Cache.GRPCAddr)
+
+		opts, err := grpcDialOptions(cfg.Cache)
+		if err != nil {
+			return nil, err
+		}
+		client, err := netcache.Dial(cfg.Cache.GRPCAddr, nil, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cache server client: %w", err)
+		}
+		return guard("Cache server", client, cfg.Cache.ErrorPolicy), nil
+	default:
+		return nil, fmt.Errorf("invalid cache type %q, use 'mem', 'redis', 'memcached' or 'grpc'", cfg.Cache.Type)
+	}
+}
+
+// grpcDialOptions returns the transport and per-call credentials used to
+// reach the cache server. A token is sent over plaintext connections too,
+// as the server may be listening on a trusted network without TLS.
+func grpcDialOptions(cfg config.CacheConfig) ([]grpc.DialOption, error) {
+	creds := insecure.NewCredentials()
+	if cfg.GRPCTLS {
+		creds = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+		if cfg.GRPCCAFile != "" {
+			var err error
+			if creds, err = credentials.NewClientTLSFromFile(cfg.GRPCCAFile, ""); err != nil {
+				return nil, fmt.Errorf("failed to load cache server CA: %w", err)
+			}
+		}
+	}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	if cfg.GRPCToken != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(netcache.TokenCredentials(cfg.GRPCToken, !cfg.GRPCTLS)))
+	}
+	return opts, nil
+}
+
+// guard wraps a remote cache in a circuit breaker, so that the database is
+// queried directly instead of waiting on it while it is down. The error
+// policy (cfg.Cache.ErrorPolicy) decides whether failed reads become cache
+// misses ("swallow", "log") or errors ("propagate"); failed invalidations
+// are always returned.
+func guard(name string, c cachePkg.Cache, policy string) *breaker.Breaker {
+	options := &breaker.Options{
+		FailureThreshold: 5,
+		OpenTimeout:      10 * time.Second,
+		Timeout:          100 * time.Millisecond,
+		FailOpen:         policy != "propagate",
+		OnStateChange: func(ev breaker.Event) {
+			log.Printf("%s circuit breaker %s -> %s (err: %v)", name, ev.From, ev.To, ev.Err)
+		},
+	}
+	if policy == "log" {
+		options.OnError = func(err error) {
+			log.Printf("%s cache error: %v", name, err)
+		}
+	}
+	return breaker.New(c, options)
+}
+
+// HotKeys returns the keys currently replicated in process, or nil when
+// hot-key detection is not enabled for the cache backend
+func (a *App) HotKeys() []hotkey.HotKey {
+	if detector, ok := a.Cache.(*hotkey.Cache); ok {
+		return detector.HotKeys()
+	}
+	return nil
+}
+
+// Close releases the cache backend and the database connection
+func (a *App) Close() error {
+	a.stopRebuild()
+	if a.bloomClient != nil {
+		_ = a.bloomClient.Close()
+	}
+	cacheErr := a.Cache.Close()
+
+	sqlDB, err := a.DB.DB()
+	if err != nil {
+		return err
+	}
+	if err := sqlDB.Close(); err != nil {
+		return err
+	}
+	return cacheErr
+}
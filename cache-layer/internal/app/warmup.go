@@ -0,0 +1,108 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/seokheejang/go/cache-layer/internal/domains/user"
+	"github.com/seokheejang/go/cache-layer/internal/infrastructure/repository"
+	gormCache "github.com/seokheejang/go/cache-layer/pkg/cache/gorm"
+	"gorm.io/gorm"
+)
+
+// NewWarmer returns the named queries that can be listed under warmup.queries.
+// Every query goes through the repositories the REST API uses, so that it
+// fills exactly the cache entries the API reads.
+func NewWarmer() *gormCache.Warmer {
+	w := gormCache.NewWarmer()
+
+	w.Register("roles.list", func(db *gorm.DB, arg string) error {
+		page, err := parsePage(arg)
+		if err != nil {
+			return err
+		}
+		_, _, err = repository.NewRoleRepository(db).List(db.Statement.Context, page)
+		return err
+	})
+	w.Register("roles.by_id", func(db *gorm.DB, arg string) error {
+		id, err := parseID(arg)
+		if err != nil {
+			return err
+		}
+		_, err = repository.NewRoleRepository(db).Get(db.Statement.Context, id)
+		return err
+	})
+	w.Register("users.by_id", func(db *gorm.DB, arg string) error {
+		id, err := parseID(arg)
+		if err != nil {
+			return err
+		}
+		_, err = repository.NewUserRepository(db).Get(db.Statement.Context, id)
+		return err
+	})
+	w.Register("users.list", func(db *gorm.DB, arg string) error {
+		page, err := parsePage(arg)
+		if err != nil {
+			return err
+		}
+		_, _, err = repository.NewUserRepository(db).List(db.Statement.Context, page)
+		return err
+	})
+	return w
+}
+
+// Warmup runs the warm-up queries from the config and logs the outcome.
+// Their results are cached for warmup.ttl rather than the default TTL, so
+// that they are still there when the traffic arrives.
+func (a *App) Warmup(ctx context.Context) *gormCache.WarmupReport {
+	ttl := a.Config.Warmup.TTL
+	if ttl == 0 {
+		ttl = a.Config.Cache.MaxTTL
+	}
+	ctx = gormCache.WithTTL(ctx, ttl)
+
+	tasks := make([]gormCache.WarmupTask, len(a.Config.Warmup.Queries))
+	for i, q := range a.Config.Warmup.Queries {
+		tasks[i] = gormCache.WarmupTask{Name: q.Name, Args: q.Args}
+	}
+
+	report := NewWarmer().Run(ctx, a.DB, tasks, &gormCache.WarmupOptions{
+		Concurrency: a.Config.Warmup.Concurrency,
+		Timeout:     a.Config.Warmup.Timeout,
+	})
+	if len(tasks) == 0 {
+		return report
+	}
+
+	log.Printf("Cache warm-up finished in %s: %d succeeded, %d failed",
+		report.Duration, report.Succeeded, len(report.Failures))
+	for _, f := range report.Failures {
+		log.Printf("Cache warm-up failed: %v", f)
+	}
+	return report
+}
+
+// parsePage returns the page of the API's default size numbered arg, or the
+// first one when arg is empty
+func parsePage(arg string) (user.Page, error) {
+	page := user.Page{Number: 1, Size: user.DefaultPageSize}
+	if arg == "" {
+		return page, nil
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 {
+		return page, fmt.Errorf("invalid page %q", arg)
+	}
+	page.Number = n
+	return page, nil
+}
+
+func parseID(arg string) (uint, error) {
+	id, err := strconv.ParseUint(arg, 10, 64)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("invalid id %q", arg)
+	}
+	return uint(id), nil
+}
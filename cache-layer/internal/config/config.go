@@ -1,39 +1,94 @@
 package config
 
 import (
+	"fmt"
 	"time"
 )
 
 type Config struct {
-	Database DatabaseConfig
-	Redis    RedisConfig
-	Cache    CacheConfig
+	Server   ServerConfig   `yaml:"server" toml:"server"`
+	Database DatabaseConfig `yaml:"database" toml:"database"`
+	Redis    RedisConfig    `yaml:"redis" toml:"redis"`
+	Cache    CacheConfig    `yaml:"cache" toml:"cache"`
+	Warmup   WarmupConfig   `yaml:"warmup" toml:"warmup"`
+}
+
+type ServerConfig struct {
+	Addr string `yaml:"addr" toml:"addr"`
+	// AdminToken enables the cache admin API under /admin/ when set
+	AdminToken    string `yaml:"admin_token" toml:"admin_token"`
+	AdminReadOnly bool   `yaml:"admin_read_only" toml:"admin_read_only"`
 }
 
 type DatabaseConfig struct {
-	Host     string
-	Port     int
-	User     string
-	Password string
-	DBName   string
-	SSLMode  string
+	Driver   string `yaml:"driver" toml:"driver"` // postgres or sqlite
+	Path     string `yaml:"path" toml:"path"`     // SQLite database file
+	Host     string `yaml:"host" toml:"host"`
+	Port     int    `yaml:"port" toml:"port"`
+	User     string `yaml:"user" toml:"user"`
+	Password string `yaml:"password" toml:"password"`
+	DBName   string `yaml:"dbname" toml:"dbname"`
+	SSLMode  string `yaml:"sslmode" toml:"sslmode"`
 }
 
 type RedisConfig struct {
-	Addr     string
-	Password string
-	DB       int
+	Addr     string `yaml:"addr" toml:"addr"`
+	Password string `yaml:"password" toml:"password"`
+	DB       int    `yaml:"db" toml:"db"`
 }
 
 type CacheConfig struct {
-	Type   string
-	TTL    time.Duration
-	MaxTTL time.Duration
+	Type        string        `yaml:"type" toml:"type"` // mem, redis, memcached or grpc
+	TTL         time.Duration `yaml:"ttl" toml:"ttl"`
+	MaxTTL      time.Duration `yaml:"max_ttl" toml:"max_ttl"`
+	ErrorPolicy string        `yaml:"error_policy" toml:"error_policy"` // swallow, log or propagate
+	// HotKeyThreshold is the reads per 10s that make a Redis key hot enough
+	// to be replicated in process (0 disables hot-key detection)
+	HotKeyThreshold int           `yaml:"hot_key_threshold" toml:"hot_key_threshold"`
+	HotKeyTTL       time.Duration `yaml:"hot_key_ttl" toml:"hot_key_ttl"`
+	// Bloom is the Bloom filter guarding primary key lookups: off, mem or redis
+	Bloom                  string        `yaml:"bloom" toml:"bloom"`
+	BloomExpectedItems     int           `yaml:"bloom_expected_items" toml:"bloom_expected_items"`
+	BloomFalsePositiveRate float64       `yaml:"bloom_false_positive_rate" toml:"bloom_false_positive_rate"`
+	BloomRebuildInterval   time.Duration `yaml:"bloom_rebuild_interval" toml:"bloom_rebuild_interval"`
+	// GRPCAddr is the address of the cache server used by the grpc type
+	GRPCAddr string `yaml:"grpc_addr" toml:"grpc_addr"`
+	// GRPCToken is the bearer token sent to, and required by, the cache
+	// server
+	GRPCToken string `yaml:"grpc_token" toml:"grpc_token"`
+	// GRPCTLS connects to the cache server over TLS, verified with the CA
+	// certificates in GRPCCAFile or else the system ones
+	GRPCTLS    bool   `yaml:"grpc_tls" toml:"grpc_tls"`
+	GRPCCAFile string `yaml:"grpc_ca_file" toml:"grpc_ca_file"`
+	// MemcachedServers is the comma separated list of servers (host:port)
+	// used by the memcached type
+	MemcachedServers string `yaml:"memcached_servers" toml:"memcached_servers"`
+}
+
+// WarmupConfig lists the named queries run to fill the cache on startup
+type WarmupConfig struct {
+	Concurrency int           `yaml:"concurrency" toml:"concurrency"`
+	Timeout     time.Duration `yaml:"timeout" toml:"timeout"`
+	// TTL of the warmed entries; 0 uses cache.max_ttl
+	TTL     time.Duration       `yaml:"ttl" toml:"ttl"`
+	Queries []WarmupQueryConfig `yaml:"queries" toml:"queries"`
+}
+
+// WarmupQueryConfig selects a named warm-up query; it runs once per arg,
+// or once when there are no args
+type WarmupQueryConfig struct {
+	Name string   `yaml:"name" toml:"name"`
+	Args []string `yaml:"args,omitempty" toml:"args,omitempty"`
 }
 
 func NewDefaultConfig() *Config {
 	return &Config{
+		Server: ServerConfig{
+			Addr: ":8080",
+		},
 		Database: DatabaseConfig{
+			Driver:   "postgres",
+			Path:     "cache-layer.db",
 			Host:     "localhost",
 			Port:     6432,
 			User:     "postgres",
@@ -47,9 +102,42 @@ func NewDefaultConfig() *Config {
 			DB:       0,
 		},
 		Cache: CacheConfig{
-			Type:   "mem",
-			TTL:    2 * time.Second,
-			MaxTTL: 30 * time.Second,
+			Type:            "mem",
+			TTL:             2 * time.Second,
+			MaxTTL:          30 * time.Second,
+			ErrorPolicy:     "log",
+			HotKeyThreshold: 100,
+			HotKeyTTL:       time.Second,
+
+			Bloom:                  "off",
+			BloomExpectedItems:     100000,
+			BloomFalsePositiveRate: 0.01,
+			BloomRebuildInterval:   10 * time.Minute,
+
+			GRPCAddr:         "localhost:9090",
+			MemcachedServers: "localhost:11211",
+		},
+		Warmup: WarmupConfig{
+			Concurrency: 4,
+			Timeout:     30 * time.Second,
 		},
 	}
 }
+
+// DSN returns the PostgreSQL connection string
+func (c DatabaseConfig) DSN() string {
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s",
+		c.Host,
+		c.User,
+		c.Password,
+		c.DBName,
+		c.Port,
+		c.SSLMode,
+	)
+}
+
+// MaskedDSN returns the PostgreSQL connection string with the password masked
+func (c DatabaseConfig) MaskedDSN() string {
+	c.Password = mask(c.Password)
+	return c.DSN()
+}
@@ -0,0 +1,364 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is the prefix of every environment variable read by Load
+const EnvPrefix = "CACHE_LAYER_"
+
+// maskedValue replaces secrets when the config is printed
+const maskedValue = "******"
+
+// field binds a single config value to its flag and environment variable
+type field struct {
+	flag   string
+	env    string
+	usage  string
+	secret bool
+	ptr    func(*Config) interface{}
+}
+
+var fields = []field{
+	{"addr", "SERVER_ADDR", "HTTP listen address", false, func(c *Config) interface{} { return &c.Server.Addr }},
+	{"admin-token", "SERVER_ADMIN_TOKEN", "bearer token of the cache admin API under /admin/ (empty disables it)", true, func(c *Config) interface{} { return &c.Server.AdminToken }},
+	{"admin-read-only", "SERVER_ADMIN_READ_ONLY", "only allow reads through the cache admin API", false, func(c *Config) interface{} { return &c.Server.AdminReadOnly }},
+	{"db-driver", "DATABASE_DRIVER", "database driver (postgres or sqlite)", false, func(c *Config) interface{} { return &c.Database.Driver }},
+	{"db-path", "DATABASE_PATH", "SQLite database file", false, func(c *Config) interface{} { return &c.Database.Path }},
+	{"db-host", "DATABASE_HOST", "PostgreSQL host", false, func(c *Config) interface{} { return &c.Database.Host }},
+	{"db-port", "DATABASE_PORT", "PostgreSQL port", false, func(c *Config) interface{} { return &c.Database.Port }},
+	{"db-user", "DATABASE_USER", "PostgreSQL user", false, func(c *Config) interface{} { return &c.Database.User }},
+	{"db-password", "DATABASE_PASSWORD", "PostgreSQL password", true, func(c *Config) interface{} { return &c.Database.Password }},
+	{"db-name", "DATABASE_DBNAME", "PostgreSQL database name", false, func(c *Config) interface{} { return &c.Database.DBName }},
+	{"db-sslmode", "DATABASE_SSLMODE", "PostgreSQL sslmode", false, func(c *Config) interface{} { return &c.Database.SSLMode }},
+	{"redis-addr", "REDIS_ADDR", "Redis address (host:port)", false, func(c *Config) interface{} { return &c.Redis.Addr }},
+	{"redis-password", "REDIS_PASSWORD", "Redis password", true, func(c *Config) interface{} { return &c.Redis.Password }},
+	{"redis-db", "REDIS_DB", "Redis database number", false, func(c *Config) interface{} { return &c.Redis.DB }},
+	{"cache", "CACHE_TYPE", "cache type choice (mem, redis, memcached or grpc)", false, func(c *Config) interface{} { return &c.Cache.Type }},
+	{"cache-ttl", "CACHE_TTL", "default cache entry TTL", false, func(c *Config) interface{} { return &c.Cache.TTL }},
+	{"cache-max-ttl", "CACHE_MAX_TTL", "maximum cache entry TTL", false, func(c *Config) interface{} { return &c.Cache.MaxTTL }},
+	{"cache-error-policy", "CACHE_ERROR_POLICY", "cache error policy (swallow, log or propagate)", false, func(c *Config) interface{} { return &c.Cache.ErrorPolicy }},
+	{"cache-hot-key-threshold", "CACHE_HOT_KEY_THRESHOLD", "reads per 10s that make a Redis key hot (0 disables)", false, func(c *Config) interface{} { return &c.Cache.HotKeyThreshold }},
+	{"cache-hot-key-ttl", "CACHE_HOT_KEY_TTL", "lifetime of in-process copies of hot keys", false, func(c *Config) interface{} { return &c.Cache.HotKeyTTL }},
+	{"cache-bloom", "CACHE_BLOOM", "Bloom filter guarding primary key lookups (off, mem for a single instance, or redis)", false, func(c *Config) interface{} { return &c.Cache.Bloom }},
+	{"cache-bloom-expected-items", "CACHE_BLOOM_EXPECTED_ITEMS", "number of rows the Bloom filter is sized for", false, func(c *Config) interface{} { return &c.Cache.BloomExpectedItems }},
+	{"cache-bloom-fp-rate", "CACHE_BLOOM_FALSE_POSITIVE_RATE", "target false positive rate of the Bloom filter", false, func(c *Config) interface{} { return &c.Cache.BloomFalsePositiveRate }},
+	{"cache-bloom-rebuild-interval", "CACHE_BLOOM_REBUILD_INTERVAL", "how often the Bloom filter is rebuilt from the database", false, func(c *Config) interface{} { return &c.Cache.BloomRebuildInterval }},
+	{"cache-grpc-addr", "CACHE_GRPC_ADDR", "cache server address (host:port) of the grpc cache type", false, func(c *Config) interface{} { return &c.Cache.GRPCAddr }},
+	{"cache-grpc-token", "CACHE_GRPC_TOKEN", "bearer token of the cache server (empty sends and requires none)", true, func(c *Config) interface{} { return &c.Cache.GRPCToken }},
+	{"cache-grpc-tls", "CACHE_GRPC_TLS", "connect to the cache server over TLS", false, func(c *Config) interface{} { return &c.Cache.GRPCTLS }},
+	{"cache-grpc-ca-file", "CACHE_GRPC_CA_FILE", "CA certificates verifying the cache server (defaults to the system ones)", false, func(c *Config) interface{} { return &c.Cache.GRPCCAFile }},
+	{"cache-memcached-servers", "CACHE_MEMCACHED_SERVERS", "comma separated memcached servers (host:port) of the memcached cache type", false, func(c *Config) interface{} { return &c.Cache.MemcachedServers }},
+	{"warmup-concurrency", "WARMUP_CONCURRENCY", "maximum number of concurrent warm-up queries", false, func(c *Config) interface{} { return &c.Warmup.Concurrency }},
+	{"warmup-timeout", "WARMUP_TIMEOUT", "maximum duration of the cache warm-up", false, func(c *Config) interface{} { return &c.Warmup.Timeout }},
+	{"warmup-ttl", "WARMUP_TTL", "TTL of the entries filled by the warm-up (0 uses the maximum cache TTL)", false, func(c *Config) interface{} { return &c.Warmup.TTL }},
+}
+
+// Load builds the effective config from, in increasing order of precedence:
+// the defaults, a YAML or TOML file (--config or CACHE_LAYER_CONFIG),
+// CACHE_LAYER_* environment variables and command line flags.
+func Load(args []string) (*Config, error) {
+	return LoadFlagSet(flag.NewFlagSet("cache-layer", flag.ContinueOnError), args)
+}
+
+// LoadFlagSet is like Load but registers the config flags on fs, so that
+// callers such as CLI subcommands can parse their own flags alongside them
+func LoadFlagSet(fs *flag.FlagSet, args []string) (*Config, error) {
+	return load(fs, args, os.LookupEnv)
+}
+
+func load(fs *flag.FlagSet, args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	// Flags are parsed into a scratch config first; only the ones actually
+	// given on the command line are copied over once file and env are applied
+	flagged := NewDefaultConfig()
+	configPath := fs.String("config", "", "path to a YAML or TOML config file (env "+EnvPrefix+"CONFIG)")
+	for _, f := range fields {
+		usage := fmt.Sprintf("%s (env %s%s)", f.usage, EnvPrefix, f.env)
+		switch p := f.ptr(flagged).(type) {
+		case *string:
+			fs.StringVar(p, f.flag, *p, usage)
+		case *int:
+			fs.IntVar(p, f.flag, *p, usage)
+		case *float64:
+			fs.Float64Var(p, f.flag, *p, usage)
+		case *bool:
+			fs.BoolVar(p, f.flag, *p, usage)
+		case *time.Duration:
+			fs.DurationVar(p, f.flag, *p, usage)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := NewDefaultConfig()
+
+	path := *configPath
+	if path == "" {
+		path, _ = lookupEnv(EnvPrefix + "CONFIG")
+	}
+	if path != "" {
+		if err := loadFile(cfg, path); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, f := range fields {
+		raw, ok := lookupEnv(EnvPrefix + f.env)
+		if !ok {
+			continue
+		}
+		if err := setString(f.ptr(cfg), raw); err != nil {
+			return nil, fmt.Errorf("config: invalid %s%s: %w", EnvPrefix, f.env, err)
+		}
+	}
+
+	byFlag := make(map[string]field, len(fields))
+	for _, f := range fields {
+		byFlag[f.flag] = f
+	}
+	fs.Visit(func(fl *flag.Flag) {
+		if f, ok := byFlag[fl.Name]; ok {
+			// Values were already validated by the flag package
+			_ = setString(f.ptr(cfg), fl.Value.String())
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile merges a YAML or TOML config file into cfg, chosen by its
+// extension. Unknown fields are rejected in both formats.
+func loadFile(cfg *Config, path string) error {
+	var decode func(data []byte) error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decode = func(data []byte) error {
+			dec := yaml.NewDecoder(bytes.NewReader(data))
+			dec.KnownFields(true)
+			if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+				return err
+			}
+			return nil
+		}
+	case ".toml":
+		decode = func(data []byte) error {
+			md, err := toml.Decode(string(data), cfg)
+			if err != nil {
+				return err
+			}
+			if undecoded := md.Undecoded(); len(undecoded) > 0 {
+				return fmt.Errorf("unknown field %q", undecoded[0].String())
+			}
+			return nil
+		}
+	default:
+		return fmt.Errorf("config: unsupported config file format %q", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: failed to read %s: %w", path, err)
+	}
+	if err := decode(data); err != nil {
+		return fmt.Errorf("config: failed to parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// setString parses raw into the value pointed to by ptr
+func setString(ptr interface{}, raw string) error {
+	switch p := ptr.(type) {
+	case *string:
+		*p = raw
+	case *int:
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		*p = v
+	case *float64:
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		*p = v
+	case *bool:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		*p = v
+	case *time.Duration:
+		v, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		*p = v
+	default:
+		return fmt.Errorf("unsupported field type %T", ptr)
+	}
+	return nil
+}
+
+// Validate checks that the config is usable
+func (c *Config) Validate() error {
+	var errs []error
+
+	switch c.Database.Driver {
+	case "postgres":
+		if c.Database.Host == "" {
+			errs = append(errs, errors.New("database.host must not be empty"))
+		}
+		if c.Database.Port < 1 || c.Database.Port > 65535 {
+			errs = append(errs, fmt.Errorf("database.port %d is out of range", c.Database.Port))
+		}
+	case "sqlite":
+		if c.Database.Path == "" {
+			errs = append(errs, errors.New("database.path must not be empty"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("database.driver %q must be postgres or sqlite", c.Database.Driver))
+	}
+	if err := checkAddr("server.addr", c.Server.Addr); err != nil {
+		errs = append(errs, err)
+	}
+	if err := checkAddr("redis.addr", c.Redis.Addr); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Redis.DB < 0 {
+		errs = append(errs, fmt.Errorf("redis.db %d must not be negative", c.Redis.DB))
+	}
+	switch c.Cache.Type {
+	case "mem", "redis":
+	case "grpc":
+		if err := checkAddr("cache.grpc_addr", c.Cache.GRPCAddr); err != nil {
+			errs = append(errs, err)
+		}
+		if c.Cache.GRPCCAFile != "" && !c.Cache.GRPCTLS {
+			errs = append(errs, errors.New("cache.grpc_ca_file requires cache.grpc_tls"))
+		}
+	case "memcached":
+		for _, server := range strings.Split(c.Cache.MemcachedServers, ",") {
+			if err := checkAddr("cache.memcached_servers", strings.TrimSpace(server)); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	default:
+		errs = append(errs, fmt.Errorf("cache.type %q must be mem, redis, memcached or grpc", c.Cache.Type))
+	}
+	switch c.Cache.ErrorPolicy {
+	case "swallow", "log", "propagate":
+	default:
+		errs = append(errs, fmt.Errorf("cache.error_policy %q must be swallow, log or propagate", c.Cache.ErrorPolicy))
+	}
+	if c.Cache.TTL <= 0 {
+		errs = append(errs, fmt.Errorf("cache.ttl %s must be positive", c.Cache.TTL))
+	}
+	if c.Cache.MaxTTL <= 0 {
+		errs = append(errs, fmt.Errorf("cache.max_ttl %s must be positive", c.Cache.MaxTTL))
+	}
+	if c.Cache.TTL > c.Cache.MaxTTL {
+		errs = append(errs, fmt.Errorf("cache.ttl %s exceeds cache.max_ttl %s", c.Cache.TTL, c.Cache.MaxTTL))
+	}
+	if c.Cache.HotKeyThreshold < 0 {
+		errs = append(errs, fmt.Errorf("cache.hot_key_threshold %d must not be negative", c.Cache.HotKeyThreshold))
+	}
+	if c.Cache.HotKeyTTL <= 0 {
+		errs = append(errs, fmt.Errorf("cache.hot_key_ttl %s must be positive", c.Cache.HotKeyTTL))
+	}
+	switch c.Cache.Bloom {
+	case "off", "mem", "redis":
+	default:
+		errs = append(errs, fmt.Errorf("cache.bloom %q must be off, mem or redis", c.Cache.Bloom))
+	}
+	if c.Cache.Bloom != "off" {
+		if c.Cache.BloomExpectedItems < 1 {
+			errs = append(errs, fmt.Errorf("cache.bloom_expected_items %d must be positive", c.Cache.BloomExpectedItems))
+		}
+		if c.Cache.BloomFalsePositiveRate <= 0 || c.Cache.BloomFalsePositiveRate >= 1 {
+			errs = append(errs, fmt.Errorf("cache.bloom_false_positive_rate %v must be between 0 and 1", c.Cache.BloomFalsePositiveRate))
+		}
+		if c.Cache.BloomRebuildInterval <= 0 {
+			errs = append(errs, fmt.Errorf("cache.bloom_rebuild_interval %s must be positive", c.Cache.BloomRebuildInterval))
+		}
+	}
+	if c.Warmup.Concurrency < 1 {
+		errs = append(errs, fmt.Errorf("warmup.concurrency %d must be at least 1", c.Warmup.Concurrency))
+	}
+	if c.Warmup.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("warmup.timeout %s must be positive", c.Warmup.Timeout))
+	}
+	if c.Warmup.TTL < 0 || c.Warmup.TTL > c.Cache.MaxTTL {
+		errs = append(errs, fmt.Errorf("warmup.ttl %s must be between 0 and cache.max_ttl %s", c.Warmup.TTL, c.Cache.MaxTTL))
+	}
+	for i, q := range c.Warmup.Queries {
+		if q.Name == "" {
+			errs = append(errs, fmt.Errorf("warmup.queries[%d].name must not be empty", i))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// checkAddr checks that addr is a host:port address with a port between 1
+// and 65535; the host may be empty
+func checkAddr(name, addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("%s %q: %w", name, addr, err)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("%s port %q is out of range", name, port)
+	}
+	return nil
+}
+
+// Masked returns a copy of the config with every secret masked
+func (c *Config) Masked() *Config {
+	masked := *c
+	for _, f := range fields {
+		if !f.secret {
+			continue
+		}
+		if p, ok := f.ptr(&masked).(*string); ok {
+			*p = mask(*p)
+		}
+	}
+	return &masked
+}
+
+// String renders the effective config as YAML with secrets masked
+func (c *Config) String() string {
+	out, err := yaml.Marshal(c.Masked())
+	if err != nil {
+		return fmt.Sprintf("config: %v", err)
+	}
+	return string(out)
+}
+
+func mask(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return maskedValue
+}
@@ -0,0 +1,239 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func loadArgs(args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	return load(flag.NewFlagSet("test", flag.ContinueOnError), args, lookupEnv)
+}
+
+func envFrom(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+}
+
+func writeConfig(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := loadArgs(nil, envFrom(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, NewDefaultConfig(), cfg)
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	path := writeConfig(t, "config.yaml", `
+database:
+  host: file-host
+  port: 5432
+redis:
+  addr: file-redis:6379
+cache:
+  type: redis
+  ttl: 5s
+  max_ttl: 1m
+`)
+
+	t.Run("file overrides defaults", func(t *testing.T) {
+		cfg, err := loadArgs([]string{"--config", path}, envFrom(nil))
+		assert.NoError(t, err)
+		assert.Equal(t, "file-host", cfg.Database.Host)
+		assert.Equal(t, 5432, cfg.Database.Port)
+		assert.Equal(t, "postgres", cfg.Database.User)
+		assert.Equal(t, "redis", cfg.Cache.Type)
+		assert.Equal(t, 5*time.Second, cfg.Cache.TTL)
+		assert.Equal(t, time.Minute, cfg.Cache.MaxTTL)
+	})
+
+	t.Run("env overrides file", func(t *testing.T) {
+		cfg, err := loadArgs(nil, envFrom(map[string]string{
+			"CACHE_LAYER_CONFIG":        path,
+			"CACHE_LAYER_DATABASE_HOST": "env-host",
+			"CACHE_LAYER_CACHE_TTL":     "10s",
+		}))
+		assert.NoError(t, err)
+		assert.Equal(t, "env-host", cfg.Database.Host)
+		assert.Equal(t, 5432, cfg.Database.Port)
+		assert.Equal(t, 10*time.Second, cfg.Cache.TTL)
+	})
+
+	t.Run("flags override env", func(t *testing.T) {
+		cfg, err := loadArgs(
+			[]string{"--config", path, "--db-host", "flag-host", "--cache=mem"},
+			envFrom(map[string]string{
+				"CACHE_LAYER_DATABASE_HOST": "env-host",
+				"CACHE_LAYER_DATABASE_PORT": "7000",
+			}),
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, "flag-host", cfg.Database.Host)
+		assert.Equal(t, 7000, cfg.Database.Port)
+		assert.Equal(t, "mem", cfg.Cache.Type)
+	})
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+	}{
+		{name: "invalid env int", env: map[string]string{"CACHE_LAYER_DATABASE_PORT": "abc"}},
+		{name: "port out of range", args: []string{"--db-port", "70000"}},
+		{name: "unknown driver", args: []string{"--db-driver", "mysql"}},
+		{name: "sqlite without path", args: []string{"--db-driver", "sqlite", "--db-path", ""}},
+		{name: "server addr without port", args: []string{"--addr", "foo"}},
+		{name: "server addr port out of range", args: []string{"--addr", ":0"}},
+		{name: "server addr port not a number", args: []string{"--addr", "localhost:http"}},
+		{name: "redis addr without port", args: []string{"--redis-addr", "localhost"}},
+		{name: "redis addr port out of range", args: []string{"--redis-addr", "localhost:65536"}},
+		{name: "unknown cache type", args: []string{"--cache", "disk"}},
+		{name: "memcached server without port", args: []string{"--cache", "memcached", "--cache-memcached-servers", "localhost:11211,localhost"}},
+		{name: "memcached server port out of range", args: []string{"--cache", "memcached", "--cache-memcached-servers", "localhost:11211,localhost:99999"}},
+		{name: "grpc addr without port", args: []string{"--cache", "grpc", "--cache-grpc-addr", "localhost"}},
+		{name: "grpc CA file without TLS", args: []string{"--cache", "grpc", "--cache-grpc-ca-file", "ca.pem"}},
+		{name: "grpc addr port out of range", args: []string{"--cache", "grpc", "--cache-grpc-addr", "localhost:0"}},
+		{name: "ttl exceeds max ttl", args: []string{"--cache-ttl", "1m", "--cache-max-ttl", "30s"}},
+		{name: "unknown error policy", args: []string{"--cache-error-policy", "ignore"}},
+		{name: "non-positive ttl", env: map[string]string{"CACHE_LAYER_CACHE_TTL": "0s"}},
+		{name: "negative hot key threshold", args: []string{"--cache-hot-key-threshold", "-1"}},
+		{name: "unknown bloom filter", args: []string{"--cache-bloom", "disk"}},
+		{name: "bloom false positive rate out of range", args: []string{"--cache-bloom", "mem", "--cache-bloom-fp-rate", "1.5"}},
+		{name: "invalid env float", env: map[string]string{"CACHE_LAYER_CACHE_BLOOM_FALSE_POSITIVE_RATE": "low"}},
+		{name: "zero warmup concurrency", args: []string{"--warmup-concurrency", "0"}},
+		{name: "warmup ttl exceeds max ttl", args: []string{"--warmup-ttl", "1m", "--cache-max-ttl", "30s"}},
+		{name: "unsupported file format", args: []string{"--config", "config.json"}},
+		{name: "unknown flag", args: []string{"--nope"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadArgs(tt.args, envFrom(tt.env))
+			assert.Error(t, err)
+		})
+	}
+
+	t.Run("unknown file field", func(t *testing.T) {
+		path := writeConfig(t, "config.yml", "cache:\n  tll: 5s\n")
+		_, err := loadArgs([]string{"--config", path}, envFrom(nil))
+		assert.Error(t, err)
+	})
+
+	t.Run("unknown toml field", func(t *testing.T) {
+		path := writeConfig(t, "config.toml", "[cache]\ntll = \"5s\"\n")
+		_, err := loadArgs([]string{"--config", path}, envFrom(nil))
+		assert.ErrorContains(t, err, "cache.tll")
+	})
+
+	t.Run("unnamed warmup query", func(t *testing.T) {
+		path := writeConfig(t, "config.yml", "warmup:\n  queries:\n    - args: [a]\n")
+		_, err := loadArgs([]string{"--config", path}, envFrom(nil))
+		assert.Error(t, err)
+	})
+}
+
+func TestLoad_WarmupQueries(t *testing.T) {
+	path := writeConfig(t, "config.yaml", `
+warmup:
+  concurrency: 8
+  ttl: 20s
+  queries:
+    - name: roles.list
+    - name: users.by_id
+      args: ["1", "2"]
+`)
+
+	cfg, err := loadArgs([]string{"--config", path, "--warmup-timeout", "5s"}, envFrom(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 8, cfg.Warmup.Concurrency)
+	assert.Equal(t, 5*time.Second, cfg.Warmup.Timeout)
+	assert.Equal(t, 20*time.Second, cfg.Warmup.TTL)
+	assert.Equal(t, []WarmupQueryConfig{
+		{Name: "roles.list"},
+		{Name: "users.by_id", Args: []string{"1", "2"}},
+	}, cfg.Warmup.Queries)
+}
+
+func TestLoad_TOML(t *testing.T) {
+	path := writeConfig(t, "config.toml", `
+[cache]
+ttl = "5s"
+
+[warmup]
+concurrency = 8
+ttl = "20s"
+
+[[warmup.queries]]
+name = "roles.list"
+
+[[warmup.queries]]
+name = "users.by_id"
+args = ["1", "2"]
+`)
+
+	cfg, err := loadArgs([]string{"--config", path}, envFrom(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Second, cfg.Cache.TTL)
+	assert.Equal(t, 8, cfg.Warmup.Concurrency)
+	assert.Equal(t, 20*time.Second, cfg.Warmup.TTL)
+	assert.Equal(t, []WarmupQueryConfig{
+		{Name: "roles.list"},
+		{Name: "users.by_id", Args: []string{"1", "2"}},
+	}, cfg.Warmup.Queries)
+}
+
+func TestLoad_Admin(t *testing.T) {
+	cfg, err := loadArgs([]string{"--admin-read-only"}, envFrom(map[string]string{
+		"CACHE_LAYER_SERVER_ADMIN_TOKEN": "s3cret",
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cret", cfg.Server.AdminToken)
+	assert.True(t, cfg.Server.AdminReadOnly)
+	assert.NotContains(t, cfg.String(), "s3cret")
+
+	_, err = loadArgs(nil, envFrom(map[string]string{"CACHE_LAYER_SERVER_ADMIN_READ_ONLY": "maybe"}))
+	assert.Error(t, err)
+}
+
+func TestLoadFlagSet_ExtraFlags(t *testing.T) {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	users := fs.Int("users", 10, "number of users")
+
+	cfg, err := load(fs, []string{"--users", "50", "--cache", "redis"}, envFrom(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 50, *users)
+	assert.Equal(t, "redis", cfg.Cache.Type)
+}
+
+func TestConfig_Masking(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Database.Password = "s3cret"
+	cfg.Redis.Password = "hunter2"
+	cfg.Cache.GRPCToken = "opensesame"
+
+	out := cfg.String()
+	assert.NotContains(t, out, "s3cret")
+	assert.NotContains(t, out, "hunter2")
+	assert.NotContains(t, out, "opensesame")
+	assert.Contains(t, out, "ttl: 2s")
+	assert.Equal(t, "s3cret", cfg.Database.Password, "masking must not modify the original")
+
+	dsn := cfg.Database.MaskedDSN()
+	assert.NotContains(t, dsn, "s3cret")
+	assert.Contains(t, dsn, "password="+maskedValue)
+	assert.Contains(t, cfg.Database.DSN(), "password=s3cret")
+}
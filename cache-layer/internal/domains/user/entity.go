@@ -15,6 +15,11 @@ type UserRole struct {
 	Name      string         `gorm:"unique"`
 }
 
+// CacheTTL keeps roles cached longer than users since they rarely change
+func (UserRole) CacheTTL() time.Duration {
+	return 30 * time.Second
+}
+
 // User represents a user in the system
 type User struct {
 	ID        uint `gorm:"primarykey"`
@@ -0,0 +1,43 @@
+package user
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotFound is returned when a user or role does not exist
+var ErrNotFound = errors.New("user: record not found")
+
+// DefaultPageSize is the size of a listing page when none is requested
+const DefaultPageSize = 20
+
+// Page selects a window of a listing; Number starts at 1
+type Page struct {
+	Number int
+	Size   int
+}
+
+// Offset returns the number of records skipped by the page
+func (p Page) Offset() int {
+	return (p.Number - 1) * p.Size
+}
+
+// UserRepository persists users
+type UserRepository interface {
+	Create(ctx context.Context, u *User) error
+	// Get returns the user with its role
+	Get(ctx context.Context, id uint) (*User, error)
+	// List returns a page of users with their roles and the total count
+	List(ctx context.Context, page Page) ([]User, int64, error)
+	Update(ctx context.Context, u *User) error
+	Delete(ctx context.Context, id uint) error
+}
+
+// RoleRepository persists roles
+type RoleRepository interface {
+	Create(ctx context.Context, r *UserRole) error
+	Get(ctx context.Context, id uint) (*UserRole, error)
+	List(ctx context.Context, page Page) ([]UserRole, int64, error)
+	Update(ctx context.Context, r *UserRole) error
+	Delete(ctx context.Context, id uint) error
+}
@@ -0,0 +1,141 @@
+// Package adapter bridges the two cache abstractions of the project:
+// pkg/cache.Cache, which stores arbitrary values, and the domain
+// cache.Service, which stores GORM query results. Any backend or decorator
+// written for one of them can be used wherever the other is expected.
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-gorm/caches/v4"
+	"github.com/seokheejang/go/cache-layer/internal/domains/cache"
+	cachepkg "github.com/seokheejang/go/cache-layer/pkg/cache"
+)
+
+// serviceAdapter exposes a cachepkg.Cache as a cache.Service
+type serviceAdapter struct {
+	cache cachepkg.Cache
+}
+
+// NewService returns a cache.Service storing encoded queries in c
+func NewService(c cachepkg.Cache) cache.Service {
+	return &serviceAdapter{cache: c}
+}
+
+func (s *serviceAdapter) Get(ctx context.Context, key string, q *caches.Query[any]) (*caches.Query[any], error) {
+	if q == nil {
+		q = &caches.Query[any]{}
+	}
+
+	value, err := s.cache.Get(ctx, key)
+	if errors.Is(err, cachepkg.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		// Backends that decode values themselves hand back the query as a map
+		if data, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := q.Unmarshal(data); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func (s *serviceAdapter) Store(ctx context.Context, key string, val *caches.Query[any]) error {
+	if val == nil {
+		return nil
+	}
+	data, err := val.Marshal()
+	if err != nil {
+		return err
+	}
+	// A TTL of 0 leaves it to the cache implementation's default
+	return s.cache.Set(ctx, key, data, 0)
+}
+
+func (s *serviceAdapter) Delete(ctx context.Context, key string) error {
+	return s.cache.Delete(ctx, key)
+}
+
+func (s *serviceAdapter) Invalidate(ctx context.Context) error {
+	return s.cache.Clear(ctx)
+}
+
+func (s *serviceAdapter) Close() {
+	_ = s.cache.Close()
+}
+
+// envelope is how cacheAdapter stores a value inside a query. []byte values
+// are kept apart so that they come back as []byte rather than a string.
+type envelope struct {
+	Bytes []byte      `json:"b,omitempty"`
+	Value interface{} `json:"v,omitempty"`
+}
+
+// cacheAdapter exposes a cache.Service as a cachepkg.Cache
+type cacheAdapter struct {
+	service cache.Service
+}
+
+// NewCache returns a cachepkg.Cache backed by s. Values are JSON encoded by
+// the service, so Get returns them in their decoded JSON form (except for
+// []byte values, which are returned as is). Per-call TTLs are ignored in
+// favour of the TTL the service was configured with.
+func NewCache(s cache.Service) cachepkg.Cache {
+	return &cacheAdapter{service: s}
+}
+
+func (c *cacheAdapter) Get(ctx context.Context, key string) (interface{}, error) {
+	env := &envelope{}
+	q, err := c.service.Get(ctx, key, &caches.Query[any]{Dest: env})
+	if err != nil {
+		return nil, err
+	}
+	if q == nil {
+		return nil, cachepkg.ErrNotFound
+	}
+
+	if env.Bytes != nil {
+		return env.Bytes, nil
+	}
+	return env.Value, nil
+}
+
+func (c *cacheAdapter) Set(ctx context.Context, key string, value interface{}, _ time.Duration) error {
+	env := &envelope{Value: value}
+	if b, ok := value.([]byte); ok {
+		env = &envelope{Bytes: b}
+	}
+	return c.service.Store(ctx, key, &caches.Query[any]{Dest: env})
+}
+
+func (c *cacheAdapter) Delete(ctx context.Context, key string) error {
+	return c.service.Delete(ctx, key)
+}
+
+func (c *cacheAdapter) Clear(ctx context.Context) error {
+	return c.service.Invalidate(ctx)
+}
+
+func (c *cacheAdapter) Close() error {
+	c.service.Close()
+	return nil
+}
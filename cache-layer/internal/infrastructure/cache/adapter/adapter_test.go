@@ -0,0 +1,144 @@
+package adapter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-gorm/caches/v4"
+	cachegorm "github.com/seokheejang/go/cache-layer/internal/infrastructure/cache/gorm"
+	"github.com/seokheejang/go/cache-layer/internal/infrastructure/cache/memory"
+	cachepkg "github.com/seokheejang/go/cache-layer/pkg/cache"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/breaker"
+	pkgmemory "github.com/seokheejang/go/cache-layer/pkg/cache/memory"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type testModel struct {
+	ID   uint `gorm:"primarykey"`
+	Name string
+}
+
+func newPkgCaches(t *testing.T) map[string]cachepkg.Cache {
+	mem, err := pkgmemory.New(&cachepkg.Options{DefaultTTL: time.Minute, MaxTTL: time.Minute, MaxSize: 100})
+	assert.NoError(t, err)
+	return map[string]cachepkg.Cache{
+		"memory":   mem,
+		"go-cache": pkgmemory.NewGoCacheWrapper(&cachepkg.Options{DefaultTTL: time.Minute}),
+	}
+}
+
+func TestNewService(t *testing.T) {
+	ctx := context.Background()
+
+	for name, backend := range newPkgCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			service := NewService(backend)
+			defer service.Close()
+
+			// Store and get
+			model := &testModel{ID: 1, Name: "admin"}
+			assert.NoError(t, service.Store(ctx, "key", &caches.Query[any]{Dest: model, RowsAffected: 1}))
+
+			result, err := service.Get(ctx, "key", &caches.Query[any]{Dest: &testModel{}})
+			assert.NoError(t, err)
+			if assert.NotNil(t, result) {
+				assert.Equal(t, model, result.Dest)
+				assert.Equal(t, int64(1), result.RowsAffected)
+			}
+
+			// Miss
+			result, err = service.Get(ctx, "missing", &caches.Query[any]{Dest: &testModel{}})
+			assert.NoError(t, err)
+			assert.Nil(t, result)
+
+			// Delete
+			assert.NoError(t, service.Delete(ctx, "key"))
+			result, err = service.Get(ctx, "key", &caches.Query[any]{Dest: &testModel{}})
+			assert.NoError(t, err)
+			assert.Nil(t, result)
+
+			// Invalidate
+			assert.NoError(t, service.Store(ctx, "key", &caches.Query[any]{Dest: model}))
+			assert.NoError(t, service.Invalidate(ctx))
+			result, err = service.Get(ctx, "key", &caches.Query[any]{Dest: &testModel{}})
+			assert.NoError(t, err)
+			assert.Nil(t, result)
+		})
+	}
+}
+
+func TestNewCache(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache(memory.NewInMemoryCache(time.Minute, time.Minute))
+	defer c.Close()
+
+	assert.NoError(t, c.Set(ctx, "string", "value", 0))
+	assert.NoError(t, c.Set(ctx, "bytes", []byte("raw"), 0))
+	assert.NoError(t, c.Set(ctx, "map", map[string]interface{}{"n": float64(1)}, 0))
+
+	val, err := c.Get(ctx, "string")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", val)
+
+	val, err = c.Get(ctx, "bytes")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("raw"), val)
+
+	val, err = c.Get(ctx, "map")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"n": float64(1)}, val)
+
+	_, err = c.Get(ctx, "missing")
+	assert.ErrorIs(t, err, cachepkg.ErrNotFound)
+
+	assert.NoError(t, c.Delete(ctx, "string"))
+	_, err = c.Get(ctx, "string")
+	assert.ErrorIs(t, err, cachepkg.ErrNotFound)
+
+	assert.NoError(t, c.Clear(ctx))
+	_, err = c.Get(ctx, "bytes")
+	assert.ErrorIs(t, err, cachepkg.ErrNotFound)
+}
+
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+
+	// internal backend -> pkg decorator -> internal service
+	service := NewService(breaker.New(NewCache(memory.NewInMemoryCache(time.Minute, time.Minute)), nil))
+	defer service.Close()
+
+	model := &testModel{ID: 7, Name: "guest"}
+	assert.NoError(t, service.Store(ctx, "key", &caches.Query[any]{Dest: model}))
+
+	result, err := service.Get(ctx, "key", &caches.Query[any]{Dest: &testModel{}})
+	assert.NoError(t, err)
+	if assert.NotNil(t, result) {
+		assert.Equal(t, model, result.Dest)
+	}
+}
+
+func TestInternalPluginWithPkgBackend(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&testModel{}))
+	assert.NoError(t, db.Create(&testModel{Name: "admin"}).Error)
+
+	backend := pkgmemory.NewGoCacheWrapper(&cachepkg.Options{DefaultTTL: time.Minute})
+	assert.NoError(t, cachegorm.WithGormCache(db, NewService(backend)))
+
+	var first []testModel
+	assert.NoError(t, db.Find(&first, "name = ?", "admin").Error)
+	assert.Len(t, first, 1)
+
+	// Raw statements do not invalidate the cache, so a stale name proves a hit
+	assert.NoError(t, db.Exec("UPDATE test_models SET name = ?", "renamed").Error)
+
+	var cached []testModel
+	assert.NoError(t, db.Find(&cached, "name = ?", "admin").Error)
+	if assert.Len(t, cached, 1) {
+		assert.Equal(t, "admin", cached[0].Name)
+	}
+}
@@ -3,43 +3,105 @@
 package memory
 
 import (
+	"container/list"
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-gorm/caches/v4"
 	"github.com/seokheejang/go/cache-layer/internal/domains/cache"
 )
 
+// Options represents the configuration options for the in-memory cache
+type Options struct {
+	// TTL is the time-to-live for cache entries (0 means no expiration)
+	TTL time.Duration
+	// PurgeInterval is the interval for periodic cleanup (defaults to 1 minute if 0)
+	PurgeInterval time.Duration
+	// MaxEntries is the maximum number of entries (0 means unlimited)
+	MaxEntries int
+	// MaxBytes is the maximum total size of keys and values (0 means unlimited)
+	MaxBytes int64
+}
+
 // cacheItem represents a cached value with its expiration time
 type cacheItem struct {
+	key       string
 	data      []byte
 	expiresAt time.Time
 }
 
+func (i *cacheItem) size() int64 {
+	return int64(len(i.key) + len(i.data))
+}
+
+// generation is one incarnation of the cache contents. Invalidate swaps in a
+// fresh generation, so readers, writers and the janitor holding the old one
+// never race with it, and stores that started before the swap are discarded.
+type generation struct {
+	mu    sync.Mutex
+	items map[string]*list.Element
+	lru   *list.List // front is most recently used
+	bytes int64
+}
+
+func newGeneration() *generation {
+	return &generation{
+		items: make(map[string]*list.Element),
+		lru:   list.New(),
+	}
+}
+
+// remove deletes an element; callers must hold g.mu
+func (g *generation) remove(el *list.Element) {
+	item := el.Value.(*cacheItem)
+	g.lru.Remove(el)
+	delete(g.items, item.key)
+	g.bytes -= item.size()
+}
+
 // memoryCache implements cache.Service interface
 type memoryCache struct {
-	store         *sync.Map
+	store         atomic.Pointer[generation]
 	ttl           time.Duration // TTL for each key
 	purgeInterval time.Duration // Interval for periodic cleanup
+	maxEntries    int
+	maxBytes      int64
 	stopJanitor   chan struct{} // Signal to stop janitor
 	once          sync.Once
 }
 
-// NewInMemoryCache creates a new in-memory cache instance
+// NewInMemoryCache creates a new unbounded in-memory cache instance
 // ttl: Time-to-live for cache entries (0 means no expiration)
 // purgeInterval: Interval for periodic cleanup (defaults to 1 minute if 0)
 func NewInMemoryCache(ttl, purgeInterval time.Duration) cache.Service {
+	return New(&Options{
+		TTL:           ttl,
+		PurgeInterval: purgeInterval,
+	})
+}
+
+// New creates a new in-memory cache instance that evicts the least recently
+// used entries once MaxEntries or MaxBytes is exceeded
+func New(options *Options) cache.Service {
+	if options == nil {
+		options = &Options{}
+	}
+
+	purgeInterval := options.PurgeInterval
 	if purgeInterval == 0 {
 		purgeInterval = time.Minute
 	}
 
 	mc := &memoryCache{
-		store:         &sync.Map{},
-		ttl:           ttl,
+		ttl:           options.TTL,
 		purgeInterval: purgeInterval,
+		maxEntries:    options.MaxEntries,
+		maxBytes:      options.MaxBytes,
 		stopJanitor:   make(chan struct{}),
 	}
+	mc.store.Store(newGeneration())
 
 	go mc.janitor()
 
@@ -54,41 +116,64 @@ func (c *memoryCache) janitor() {
 	for {
 		select {
 		case <-ticker.C:
-			now := time.Now()
-			c.store.Range(func(k, v any) bool {
-				item := v.(cacheItem)
-				if c.ttl > 0 && now.After(item.expiresAt) {
-					c.store.Delete(k)
-				}
-				return true
-			})
+			c.purgeExpired(time.Now())
 		case <-c.stopJanitor:
 			return
 		}
 	}
 }
 
+// purgeExpired removes every expired entry of the current generation
+func (c *memoryCache) purgeExpired(now time.Time) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	g := c.store.Load()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for el := g.lru.Front(); el != nil; {
+		next := el.Next()
+		if now.After(el.Value.(*cacheItem).expiresAt) {
+			g.remove(el)
+		}
+		el = next
+	}
+}
+
 // Close stops the background janitor goroutine
 func (c *memoryCache) Close() { c.once.Do(func() { close(c.stopJanitor) }) }
 
 // Get retrieves a value from the cache
 func (c *memoryCache) Get(ctx context.Context, key string, q *caches.Query[any]) (*caches.Query[any], error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	if q == nil {
 		q = &caches.Query[any]{}
 	}
 
-	val, ok := c.store.Load(key)
+	g := c.store.Load()
+	g.mu.Lock()
+	el, ok := g.items[key]
 	if !ok {
+		g.mu.Unlock()
 		return nil, nil
 	}
 
-	item := val.(cacheItem)
+	item := el.Value.(*cacheItem)
 	if c.ttl > 0 && time.Now().After(item.expiresAt) {
-		c.store.Delete(key)
+		g.remove(el)
+		g.mu.Unlock()
 		return nil, nil
 	}
+	g.lru.MoveToFront(el)
+	data := item.data
+	g.mu.Unlock()
 
-	if err := q.Unmarshal(item.data); err != nil {
+	if err := q.Unmarshal(data); err != nil {
 		return nil, err
 	}
 	return q, nil
@@ -96,6 +181,10 @@ func (c *memoryCache) Get(ctx context.Context, key string, q *caches.Query[any])
 
 // Store saves a value to the cache
 func (c *memoryCache) Store(ctx context.Context, key string, val *caches.Query[any]) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	if val == nil {
 		return nil
 	}
@@ -108,18 +197,57 @@ func (c *memoryCache) Store(ctx context.Context, key string, val *caches.Query[a
 	if c.ttl > 0 {
 		expires = time.Now().Add(c.ttl)
 	}
-	c.store.Store(key, cacheItem{data: data, expiresAt: expires})
+	item := &cacheItem{key: key, data: data, expiresAt: expires}
+
+	g := c.store.Load()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if el, ok := g.items[key]; ok {
+		g.remove(el)
+	}
+	// An entry that can never fit is not cached at all, and the value it
+	// replaces is gone as well
+	if c.maxBytes > 0 && item.size() > c.maxBytes {
+		return nil
+	}
+	g.items[key] = g.lru.PushFront(item)
+	g.bytes += item.size()
+
+	for (c.maxEntries > 0 && g.lru.Len() > c.maxEntries) || (c.maxBytes > 0 && g.bytes > c.maxBytes) {
+		g.remove(g.lru.Back())
+	}
 	return nil
 }
 
 // Delete removes a single key from the cache
 func (c *memoryCache) Delete(ctx context.Context, key string) error {
-	c.store.Delete(key)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	g := c.store.Load()
+	g.mu.Lock()
+	if el, ok := g.items[key]; ok {
+		g.remove(el)
+	}
+	g.mu.Unlock()
 	return nil
 }
 
-// Invalidate clears all cache entries
+// Invalidate clears all cache entries by atomically swapping in a new generation
 func (c *memoryCache) Invalidate(ctx context.Context) error {
-	c.store = &sync.Map{}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.store.Store(newGeneration())
 	return nil
 }
+
+// stats returns the number of entries and their total size
+func (c *memoryCache) stats() (int, int64) {
+	g := c.store.Load()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.lru.Len(), g.bytes
+}
@@ -2,6 +2,8 @@ package memory
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -45,7 +47,7 @@ func TestNewInMemoryCache(t *testing.T) {
 			cache := NewInMemoryCache(tt.ttl, tt.purgeInterval).(*memoryCache)
 			assert.Equal(t, tt.expectedTTL, cache.ttl)
 			assert.Equal(t, tt.expectedPurge, cache.purgeInterval)
-			assert.NotNil(t, cache.store)
+			assert.NotNil(t, cache.store.Load())
 			assert.NotNil(t, cache.stopJanitor)
 		})
 	}
@@ -269,6 +271,26 @@ func TestMemoryCache_Delete(t *testing.T) {
 	})
 }
 
+func TestMemoryCache_CancelledContext(t *testing.T) {
+	cache := NewInMemoryCache(time.Hour, time.Minute)
+	query := &caches.Query[any]{Dest: map[string]interface{}{"test": "data"}}
+	assert.NoError(t, cache.Store(context.Background(), "key", query))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := cache.Get(ctx, "key", &caches.Query[any]{})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, cache.Store(ctx, "other", query), context.Canceled)
+	assert.ErrorIs(t, cache.Delete(ctx, "key"), context.Canceled)
+	assert.ErrorIs(t, cache.Invalidate(ctx), context.Canceled)
+
+	// Nothing was changed
+	result, err := cache.Get(context.Background(), "key", &caches.Query[any]{})
+	assert.NoError(t, err)
+	assert.Equal(t, query.Dest, result.Dest)
+}
+
 func TestMemoryCache_Invalidate(t *testing.T) {
 	cache := NewInMemoryCache(time.Hour, time.Minute)
 	ctx := context.Background()
@@ -315,3 +337,173 @@ func TestMemoryCache_Close(t *testing.T) {
 		}
 	})
 }
+
+func TestMemoryCache_MaxEntries(t *testing.T) {
+	cache := New(&Options{TTL: time.Hour, MaxEntries: 2}).(*memoryCache)
+	defer cache.Close()
+	ctx := context.Background()
+
+	for _, key := range []string{"key1", "key2"} {
+		err := cache.Store(ctx, key, &caches.Query[any]{Dest: key})
+		assert.NoError(t, err)
+	}
+
+	// Touch key1 so that key2 becomes the least recently used entry
+	result, err := cache.Get(ctx, "key1", &caches.Query[any]{})
+	assert.NoError(t, err)
+	assert.NotNil(t, result)
+
+	err = cache.Store(ctx, "key3", &caches.Query[any]{Dest: "key3"})
+	assert.NoError(t, err)
+
+	entries, _ := cache.stats()
+	assert.Equal(t, 2, entries)
+
+	result, err = cache.Get(ctx, "key2", &caches.Query[any]{})
+	assert.NoError(t, err)
+	assert.Nil(t, result, "least recently used key should be evicted")
+
+	for _, key := range []string{"key1", "key3"} {
+		result, err := cache.Get(ctx, key, &caches.Query[any]{})
+		assert.NoError(t, err)
+		assert.NotNil(t, result)
+	}
+}
+
+func TestMemoryCache_MaxBytes(t *testing.T) {
+	query := &caches.Query[any]{Dest: "0123456789"}
+	data, err := query.Marshal()
+	assert.NoError(t, err)
+	entrySize := int64(len("keyN") + len(data))
+
+	cache := New(&Options{TTL: time.Hour, MaxBytes: 3 * entrySize}).(*memoryCache)
+	defer cache.Close()
+	ctx := context.Background()
+
+	t.Run("evicts until the total size fits", func(t *testing.T) {
+		for i := 1; i <= 4; i++ {
+			err := cache.Store(ctx, fmt.Sprintf("key%d", i), query)
+			assert.NoError(t, err)
+		}
+
+		entries, size := cache.stats()
+		assert.Equal(t, 3, entries)
+		assert.Equal(t, 3*entrySize, size)
+
+		result, err := cache.Get(ctx, "key1", &caches.Query[any]{})
+		assert.NoError(t, err)
+		assert.Nil(t, result)
+	})
+
+	t.Run("overwriting a key does not double count it", func(t *testing.T) {
+		err := cache.Store(ctx, "key4", query)
+		assert.NoError(t, err)
+
+		_, size := cache.stats()
+		assert.Equal(t, 3*entrySize, size)
+	})
+
+	t.Run("oversized entries are not cached", func(t *testing.T) {
+		big := &caches.Query[any]{Dest: string(make([]byte, 4*entrySize))}
+		err := cache.Store(ctx, "big", big)
+		assert.NoError(t, err)
+
+		result, err := cache.Get(ctx, "big", &caches.Query[any]{})
+		assert.NoError(t, err)
+		assert.Nil(t, result)
+
+		entries, _ := cache.stats()
+		assert.Equal(t, 3, entries)
+	})
+
+	t.Run("overwriting with an oversized entry drops the old one", func(t *testing.T) {
+		big := &caches.Query[any]{Dest: string(make([]byte, 4*entrySize))}
+		err := cache.Store(ctx, "key4", big)
+		assert.NoError(t, err)
+
+		result, err := cache.Get(ctx, "key4", &caches.Query[any]{})
+		assert.NoError(t, err)
+		assert.Nil(t, result)
+
+		entries, size := cache.stats()
+		assert.Equal(t, 2, entries)
+		assert.Equal(t, 2*entrySize, size)
+	})
+}
+
+func TestMemoryCache_InvalidateDropsConcurrentGeneration(t *testing.T) {
+	cache := NewInMemoryCache(time.Hour, time.Minute).(*memoryCache)
+	defer cache.Close()
+	ctx := context.Background()
+
+	err := cache.Store(ctx, "key", &caches.Query[any]{Dest: "value"})
+	assert.NoError(t, err)
+
+	old := cache.store.Load()
+	err = cache.Invalidate(ctx)
+	assert.NoError(t, err)
+
+	assert.NotSame(t, old, cache.store.Load())
+	entries, size := cache.stats()
+	assert.Equal(t, 0, entries)
+	assert.Equal(t, int64(0), size)
+}
+
+// TestMemoryCache_ConcurrentJanitorStoreInvalidate is meant to be run with -race
+func TestMemoryCache_ConcurrentJanitorStoreInvalidate(t *testing.T) {
+	cache := New(&Options{
+		TTL:           5 * time.Millisecond,
+		PurgeInterval: time.Millisecond,
+		MaxEntries:    50,
+		MaxBytes:      4096,
+	}).(*memoryCache)
+	defer cache.Close()
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+
+	worker := func(fn func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+					fn(i)
+				}
+			}
+		}()
+	}
+
+	for w := 0; w < 4; w++ {
+		worker(func(i int) {
+			key := fmt.Sprintf("key%d", i%100)
+			assert.NoError(t, cache.Store(ctx, key, &caches.Query[any]{Dest: map[string]interface{}{"i": float64(i)}}))
+		})
+		worker(func(i int) {
+			_, err := cache.Get(ctx, fmt.Sprintf("key%d", i%100), &caches.Query[any]{})
+			assert.NoError(t, err)
+		})
+	}
+	worker(func(i int) {
+		assert.NoError(t, cache.Delete(ctx, fmt.Sprintf("key%d", i%100)))
+	})
+	worker(func(i int) {
+		assert.NoError(t, cache.Invalidate(ctx))
+		time.Sleep(time.Millisecond)
+	})
+	worker(func(i int) {
+		cache.purgeExpired(time.Now())
+	})
+
+	time.Sleep(200 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+
+	entries, size := cache.stats()
+	assert.LessOrEqual(t, entries, 50)
+	assert.LessOrEqual(t, size, int64(4096))
+}
@@ -2,44 +2,102 @@ package redis
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
 	"time"
 
 	"github.com/go-gorm/caches/v4"
 	"github.com/redis/go-redis/v9"
+	"github.com/seokheejang/go/cache-layer/internal/config"
 	"github.com/seokheejang/go/cache-layer/internal/domains/cache"
 )
 
+// ErrorPolicy decides what happens to Redis read errors. Errors of Store,
+// Delete and Invalidate are always returned, since hiding a failed
+// invalidation would leave stale entries behind, and PolicyLog logs them
+// too.
+type ErrorPolicy string
+
+const (
+	// PolicySwallow turns read errors into cache misses
+	PolicySwallow ErrorPolicy = "swallow"
+	// PolicyLog behaves like PolicySwallow but logs every error, including
+	// the returned ones of writes
+	PolicyLog ErrorPolicy = "log"
+	// PolicyPropagate returns read errors to the caller as well
+	PolicyPropagate ErrorPolicy = "propagate"
+)
+
+// Operation names passed to Options.OnError
 const (
-	defaultTTL = 2 * time.Second
+	OpGet        = "get"
+	OpStore      = "store"
+	OpDelete     = "delete"
+	OpInvalidate = "invalidate"
+	OpClose      = "close"
 )
 
+// Options represents the configuration options for the Redis cache
+type Options struct {
+	// TTL is the expiration of stored entries (0 keeps them until invalidated)
+	TTL time.Duration
+	// ErrorPolicy decides how Redis read errors are surfaced (defaults to PolicyLog)
+	ErrorPolicy ErrorPolicy
+	// OnError, if set, is called for every Redis error regardless of the policy
+	OnError func(op, key string, err error)
+	// Logger is used by PolicyLog (defaults to the standard logger)
+	Logger *log.Logger
+	// OwnsClient makes Close also close the Redis client
+	OwnsClient bool
+}
+
+// OptionsFromConfig returns the options described by the cache config
+func OptionsFromConfig(cfg config.CacheConfig) *Options {
+	return &Options{
+		TTL:         cfg.TTL,
+		ErrorPolicy: ErrorPolicy(cfg.ErrorPolicy),
+	}
+}
+
 type redisCache struct {
-	rdb *redis.Client
-	ttl time.Duration
+	rdb     *redis.Client
+	options Options
+	once    sync.Once
 }
 
-// NewRedisCache creates a new Redis cache with optional TTL.
-// If ttl is not provided (nil), defaultTTL will be used.
-func NewRedisCache(rdb *redis.Client, ttl *time.Duration) cache.Service {
-	duration := defaultTTL
-	if ttl != nil {
-		duration = *ttl
+// NewRedisCache creates a new Redis cache
+func NewRedisCache(rdb *redis.Client, options *Options) cache.Service {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.ErrorPolicy == "" {
+		opts.ErrorPolicy = PolicyLog
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.Default()
 	}
 
 	return &redisCache{
-		rdb: rdb,
-		ttl: duration,
+		rdb:     rdb,
+		options: opts,
 	}
 }
 
 func (c *redisCache) Get(ctx context.Context, key string, q *caches.Query[any]) (*caches.Query[any], error) {
+	if q == nil {
+		q = &caches.Query[any]{}
+	}
+
 	res, err := c.rdb.Get(ctx, key).Bytes()
 	if err == redis.Nil {
 		return nil, nil
 	}
 
 	if err != nil {
-		return nil, nil
+		return nil, c.handle(OpGet, key, err)
 	}
 
 	if err := q.Unmarshal(res); err != nil {
@@ -50,20 +108,58 @@ func (c *redisCache) Get(ctx context.Context, key string, q *caches.Query[any])
 }
 
 func (c *redisCache) Store(ctx context.Context, key string, val *caches.Query[any]) error {
+	if val == nil {
+		return nil
+	}
+
 	res, err := val.Marshal()
 	if err != nil {
 		return err
 	}
 
-	return c.rdb.Set(ctx, key, res, c.ttl).Err()
+	return c.handle(OpStore, key, c.rdb.Set(ctx, key, res, c.options.TTL).Err())
 }
 
 func (c *redisCache) Invalidate(ctx context.Context) error {
-	return c.rdb.FlushDB(ctx).Err()
+	return c.handle(OpInvalidate, "", c.rdb.FlushDB(ctx).Err())
 }
 
 func (c *redisCache) Delete(ctx context.Context, key string) error {
-	return c.rdb.Del(ctx, key).Err()
+	return c.handle(OpDelete, key, c.rdb.Del(ctx, key).Err())
 }
 
-func (c *redisCache) Close() {}
+// Close releases the Redis client if the cache owns it
+func (c *redisCache) Close() {
+	if !c.options.OwnsClient {
+		return
+	}
+	c.once.Do(func() {
+		if err := c.rdb.Close(); err != nil && !errors.Is(err, redis.ErrClosed) {
+			_ = c.handle(OpClose, "", err)
+		}
+	})
+}
+
+// handle applies the error policy to a Redis error
+func (c *redisCache) handle(op, key string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if c.options.OnError != nil {
+		c.options.OnError(op, key, err)
+	}
+
+	if key != "" {
+		err = fmt.Errorf("redis cache %s %q: %w", op, key, err)
+	} else {
+		err = fmt.Errorf("redis cache %s: %w", op, err)
+	}
+	if c.options.ErrorPolicy != PolicySwallow && c.options.ErrorPolicy != PolicyPropagate {
+		c.options.Logger.Print(err)
+	}
+	if op != OpGet || c.options.ErrorPolicy == PolicyPropagate {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package redis
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-gorm/caches/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/seokheejang/go/cache-layer/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+type errorCall struct {
+	op  string
+	key string
+}
+
+func setupRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { _ = rdb.Close() })
+	return mr, rdb
+}
+
+func TestRedisCache_GetAndStore(t *testing.T) {
+	mr, rdb := setupRedis(t)
+	cache := NewRedisCache(rdb, OptionsFromConfig(config.NewDefaultConfig().Cache))
+	ctx := context.Background()
+
+	query := &caches.Query[any]{Dest: map[string]interface{}{"name": "admin"}}
+	err := cache.Store(ctx, "key", query)
+	assert.NoError(t, err)
+	assert.Equal(t, 2*time.Second, mr.TTL("key"))
+
+	result, err := cache.Get(ctx, "key", &caches.Query[any]{})
+	assert.NoError(t, err)
+	if assert.NotNil(t, result) {
+		assert.Equal(t, query.Dest, result.Dest)
+	}
+
+	result, err = cache.Get(ctx, "missing", &caches.Query[any]{})
+	assert.NoError(t, err)
+	assert.Nil(t, result)
+
+	err = cache.Delete(ctx, "key")
+	assert.NoError(t, err)
+	assert.False(t, mr.Exists("key"))
+}
+
+func TestRedisCache_ErrorPolicy(t *testing.T) {
+	ctx := context.Background()
+	query := &caches.Query[any]{Dest: "value"}
+
+	tests := []struct {
+		policy        ErrorPolicy
+		expectReadErr bool
+		expectLogs    int
+	}{
+		{policy: PolicySwallow},
+		{policy: PolicyLog, expectLogs: 4},
+		{policy: PolicyPropagate, expectReadErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			mr, rdb := setupRedis(t)
+
+			var calls []errorCall
+			var logs bytes.Buffer
+			cache := NewRedisCache(rdb, &Options{
+				TTL:         time.Minute,
+				ErrorPolicy: tt.policy,
+				OnError: func(op, key string, err error) {
+					assert.Error(t, err)
+					calls = append(calls, errorCall{op: op, key: key})
+				},
+				Logger: log.New(&logs, "", 0),
+			})
+
+			mr.Close()
+
+			result, err := cache.Get(ctx, "key", &caches.Query[any]{})
+			assert.Nil(t, result)
+			assert.Equal(t, tt.expectReadErr, err != nil)
+
+			// Failed writes and invalidations are never hidden
+			assert.Error(t, cache.Store(ctx, "key", query))
+			assert.Error(t, cache.Delete(ctx, "key"))
+			assert.Error(t, cache.Invalidate(ctx))
+
+			assert.Equal(t, []errorCall{
+				{op: OpGet, key: "key"},
+				{op: OpStore, key: "key"},
+				{op: OpDelete, key: "key"},
+				{op: OpInvalidate, key: ""},
+			}, calls)
+			// PolicyLog logs the failures of every operation
+			assert.Equal(t, tt.expectLogs, strings.Count(logs.String(), "redis cache "))
+			if tt.expectLogs > 0 {
+				for _, op := range []string{OpGet, OpStore, OpDelete, OpInvalidate} {
+					assert.Contains(t, logs.String(), "redis cache "+op)
+				}
+			}
+		})
+	}
+}
+
+func TestRedisCache_Close(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("borrowed client stays open", func(t *testing.T) {
+		_, rdb := setupRedis(t)
+		cache := NewRedisCache(rdb, nil)
+		cache.Close()
+		assert.NoError(t, rdb.Ping(ctx).Err())
+	})
+
+	t.Run("owned client is closed once", func(t *testing.T) {
+		_, rdb := setupRedis(t)
+		var calls []errorCall
+		cache := NewRedisCache(rdb, &Options{
+			OwnsClient: true,
+			OnError:    func(op, key string, err error) { calls = append(calls, errorCall{op: op, key: key}) },
+		})
+		cache.Close()
+		cache.Close()
+		assert.ErrorIs(t, rdb.Ping(ctx).Err(), redis.ErrClosed)
+		assert.Empty(t, calls)
+	})
+}
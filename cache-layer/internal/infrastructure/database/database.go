@@ -0,0 +1,47 @@
+// Package database opens the GORM connection described by the config.
+package database
+
+import (
+	"fmt"
+
+	"github.com/seokheejang/go/cache-layer/internal/config"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// Open connects to PostgreSQL or SQLite depending on cfg.Driver
+func Open(cfg config.DatabaseConfig, gormConfig *gorm.Config) (*gorm.DB, error) {
+	switch cfg.Driver {
+	case "sqlite":
+		db, err := gorm.Open(sqlite.Open(cfg.Path), gormConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+		}
+		return db, nil
+	case "postgres", "":
+		db, err := gorm.Open(postgres.Open(cfg.DSN()), gormConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to database: %w", err)
+		}
+
+		if err := db.Exec("CREATE SCHEMA IF NOT EXISTS public").Error; err != nil {
+			return nil, fmt.Errorf("failed to create schema: %w", err)
+		}
+
+		if err := db.Exec("SET search_path TO public").Error; err != nil {
+			return nil, fmt.Errorf("failed to set search path: %w", err)
+		}
+		return db, nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.Driver)
+	}
+}
+
+// Describe returns a loggable description of the connection with secrets masked
+func Describe(cfg config.DatabaseConfig) string {
+	if cfg.Driver == "sqlite" {
+		return "SQLite " + cfg.Path
+	}
+	return "PostgreSQL " + cfg.MaskedDSN()
+}
@@ -0,0 +1,143 @@
+// Package repository implements the domain repositories on top of GORM.
+// Reads go through whatever cache plugin is installed on the *gorm.DB, and
+// every write invalidates it through the plugin's mutation callbacks.
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/seokheejang/go/cache-layer/internal/domains/user"
+	"gorm.io/gorm"
+)
+
+type userRepository struct {
+	db *gorm.DB
+}
+
+// NewUserRepository creates a GORM backed user.UserRepository
+func NewUserRepository(db *gorm.DB) user.UserRepository {
+	return &userRepository{db: db}
+}
+
+func (r *userRepository) Create(ctx context.Context, u *user.User) error {
+	return r.db.WithContext(ctx).Omit("Role").Create(u).Error
+}
+
+func (r *userRepository) Get(ctx context.Context, id uint) (*user.User, error) {
+	var u user.User
+	if err := r.db.WithContext(ctx).Preload("Role").First(&u, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &u, nil
+}
+
+func (r *userRepository) List(ctx context.Context, page user.Page) ([]user.User, int64, error) {
+	var total int64
+	if err := r.db.WithContext(ctx).Model(&user.User{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	users := []user.User{}
+	err := r.db.WithContext(ctx).
+		Preload("Role").
+		Order("id").
+		Offset(page.Offset()).
+		Limit(page.Size).
+		Find(&users).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (r *userRepository) Update(ctx context.Context, u *user.User) error {
+	res := r.db.WithContext(ctx).Model(&user.User{ID: u.ID}).Select("Name", "RoleID").Updates(u)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return user.ErrNotFound
+	}
+	return nil
+}
+
+func (r *userRepository) Delete(ctx context.Context, id uint) error {
+	res := r.db.WithContext(ctx).Delete(&user.User{}, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return user.ErrNotFound
+	}
+	return nil
+}
+
+type roleRepository struct {
+	db *gorm.DB
+}
+
+// NewRoleRepository creates a GORM backed user.RoleRepository
+func NewRoleRepository(db *gorm.DB) user.RoleRepository {
+	return &roleRepository{db: db}
+}
+
+func (r *roleRepository) Create(ctx context.Context, role *user.UserRole) error {
+	return r.db.WithContext(ctx).Create(role).Error
+}
+
+func (r *roleRepository) Get(ctx context.Context, id uint) (*user.UserRole, error) {
+	var role user.UserRole
+	if err := r.db.WithContext(ctx).First(&role, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &role, nil
+}
+
+func (r *roleRepository) List(ctx context.Context, page user.Page) ([]user.UserRole, int64, error) {
+	var total int64
+	if err := r.db.WithContext(ctx).Model(&user.UserRole{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	roles := []user.UserRole{}
+	err := r.db.WithContext(ctx).
+		Order("id").
+		Offset(page.Offset()).
+		Limit(page.Size).
+		Find(&roles).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return roles, total, nil
+}
+
+func (r *roleRepository) Update(ctx context.Context, role *user.UserRole) error {
+	res := r.db.WithContext(ctx).Model(&user.UserRole{ID: role.ID}).Select("Name").Updates(role)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return user.ErrNotFound
+	}
+	return nil
+}
+
+func (r *roleRepository) Delete(ctx context.Context, id uint) error {
+	res := r.db.WithContext(ctx).Delete(&user.UserRole{}, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return user.ErrNotFound
+	}
+	return nil
+}
+
+// notFound maps GORM's not found error to the domain one
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return user.ErrNotFound
+	}
+	return err
+}
@@ -1,178 +1,92 @@
 package main
 
 import (
-	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
-	"time"
+	"strings"
 
-	"github.com/redis/go-redis/v9"
+	"github.com/seokheejang/go/cache-layer/internal/app"
 	"github.com/seokheejang/go/cache-layer/internal/config"
-	"github.com/seokheejang/go/cache-layer/internal/domains/user"
-	cachePkg "github.com/seokheejang/go/cache-layer/pkg/cache"
-	gormCache "github.com/seokheejang/go/cache-layer/pkg/cache/gorm"
-	memoryCache "github.com/seokheejang/go/cache-layer/pkg/cache/memory"
-	redisCache "github.com/seokheejang/go/cache-layer/pkg/cache/redis"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
-func main() {
-	if len(os.Args) == 1 {
-		fmt.Println("Usage: go run main.go --cache=mem|redis")
-		os.Exit(1)
-	}
-
-	cfg := config.NewDefaultConfig()
-	cacheType := flag.String("cache", cfg.Cache.Type, "cache type choice (mem or redis)")
-	flag.Parse()
-
-	// Configure GORM logger
-	newLogger := logger.New(
-		log.New(log.Writer(), "\r\n", log.LstdFlags),
-		logger.Config{
-			SlowThreshold:             time.Second,
-			LogLevel:                  logger.Info,
-			IgnoreRecordNotFoundError: true,
-			Colorful:                  true,
-		},
-	)
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
 
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s",
-		cfg.Database.Host,
-		cfg.Database.User,
-		cfg.Database.Password,
-		cfg.Database.DBName,
-		cfg.Database.Port,
-		cfg.Database.SSLMode,
-	)
-	log.Printf("Connecting to PostgreSQL with DSN: %s", dsn)
+var commands = []command{
+	{"demo", "seed two users and show a cache miss followed by a hit", runDemo},
+	{"seed", "generate users and roles", runSeed},
+	{"query", "run ad-hoc cached user lookups", runQuery},
+	{"bench", "run a read/write mix and report hit ratio and latencies", runBench},
+	{"stats", "print cache backend and database statistics", runStats},
+	{"cache-server", "share an in-memory cache with other processes over gRPC", runCacheServer},
+}
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: newLogger,
-	})
-	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: go run . <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", cmd.name, cmd.summary)
 	}
+	fmt.Fprintln(os.Stderr, "\nRun 'go run . <command> -h' for the flags of a command.")
+}
 
-	if err := db.Exec("CREATE SCHEMA IF NOT EXISTS public").Error; err != nil {
-		log.Fatal("Failed to create schema:", err)
+func main() {
+	args := os.Args[1:]
+	if len(args) == 0 {
+		usage()
+		os.Exit(1)
 	}
 
-	if err := db.Exec("SET search_path TO public").Error; err != nil {
-		log.Fatal("Failed to set search path:", err)
+	// Flags without a command keep the original behaviour: go run . --cache=mem
+	name := "demo"
+	if !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
 	}
 
-	var cacheService cachePkg.Cache
-	switch *cacheType {
-	case "mem":
-		log.Println("Using in-memory cache")
-
-		cacheService, err = memoryCache.New(&cachePkg.Options{
-			DefaultTTL: cfg.Cache.TTL,
-			MaxTTL:     cfg.Cache.MaxTTL,
-			MaxSize:    1000,
-		})
-		if err != nil {
-			log.Fatal("Failed to create memory cache:", err)
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
 		}
-	case "redis":
-		log.Println("Using Redis cache")
-
-		rdb := redis.NewClient(&redis.Options{
-			Addr:     cfg.Redis.Addr,
-			Password: cfg.Redis.Password,
-			DB:       cfg.Redis.DB,
-		})
-		cacheService, err = redisCache.New(rdb, &cachePkg.Options{
-			DefaultTTL: cfg.Cache.TTL,
-			MaxTTL:     cfg.Cache.MaxTTL,
-		})
-		if err != nil {
-			log.Fatal("Failed to create Redis cache:", err)
+		if err := cmd.run(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				os.Exit(0)
+			}
+			log.Fatalf("%s: %v", name, err)
 		}
-	default:
-		log.Fatal("Invalid cache type. Use 'mem' or 'redis'")
-	}
-	defer cacheService.Close()
-
-	// Apply GORM cache plugin
-	if err := gormCache.WithGormCache(db, cacheService); err != nil {
-		log.Fatal("Failed to apply cache plugin:", err)
-	}
-
-	// Run migrations
-	if err := db.AutoMigrate(&user.UserRole{}, &user.User{}); err != nil {
-		log.Fatal(err)
-	}
-
-	// Clean up existing data
-	if err := db.Exec("DELETE FROM users").Error; err != nil {
-		log.Fatal(err)
-	}
-	if err := db.Exec("DELETE FROM user_roles").Error; err != nil {
-		log.Fatal(err)
+		return
 	}
 
-	// Create roles
-	adminRole := &user.UserRole{
-		Name: "Admin",
-	}
-	if err := db.Create(adminRole).Error; err != nil {
-		log.Fatal(err)
-	}
-
-	guestRole := &user.UserRole{
-		Name: "Guest",
-	}
-	if err := db.Create(guestRole).Error; err != nil {
-		log.Fatal(err)
-	}
-
-	// Create users
-	adminUser := &user.User{
-		Name:   "admin",
-		RoleID: adminRole.ID,
-		Role:   adminRole,
-	}
-	if err := db.Create(adminUser).Error; err != nil {
-		log.Fatal(err)
-	}
-
-	guestUser := &user.User{
-		Name:   "guest",
-		RoleID: guestRole.ID,
-		Role:   guestRole,
-	}
-	if err := db.Create(guestUser).Error; err != nil {
-		log.Fatal(err)
-	}
-
-	ctx := context.Background()
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	usage()
+	os.Exit(1)
+}
 
-	fmt.Println("\n=== Starting Cache Test ===")
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet(name, flag.ContinueOnError)
+}
 
-	fmt.Println("\n[Test 1] First query (expected cache miss)")
-	var users1 []user.User
-	if err := db.WithContext(ctx).Find(&users1, "name = ?", "admin").Error; err != nil {
-		log.Printf("DB error: %v", err)
-	}
-	if len(users1) > 0 {
-		fmt.Printf("Result: %+v\n", users1[0])
+// loadConfig parses the command flags in fs together with the config flags
+func loadConfig(fs *flag.FlagSet, args []string) (*config.Config, error) {
+	cfg, err := config.LoadFlagSet(fs, args)
+	if err != nil {
+		return nil, err
 	}
+	log.Printf("Effective config:\n%s", cfg)
+	return cfg, nil
+}
 
-	// Second query (cache hit)
-	fmt.Println("\n[Test 2] Second query (expected cache hit)")
-	var users2 []user.User
-	if err := db.WithContext(ctx).Find(&users2, "name = ?", "admin").Error; err != nil {
-		log.Printf("DB error: %v", err)
-	}
-	if len(users2) > 0 {
-		fmt.Printf("Result: %+v\n", users2[0])
+// openApp loads the config and opens the cached database
+func openApp(fs *flag.FlagSet, args []string, logLevel logger.LogLevel) (*app.App, error) {
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return nil, err
 	}
-
-	fmt.Println("\n=== Cache Test Completed ===")
+	return app.New(cfg, logLevel)
 }
@@ -0,0 +1,357 @@
+// Package admin serves an HTTP API to look into and manage a running cache:
+// listing keys, showing entries, deleting keys or patterns, invalidating the
+// cached queries of GORM tables and reporting statistics. Listing relies on
+// cache.KeyLister, entry metadata on cache.Inspector and statistics on
+// cache.StatsProvider; endpoints whose interface the cache lacks answer
+// 501 Not Implemented.
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	gormCache "github.com/seokheejang/go/cache-layer/pkg/cache/gorm"
+)
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// Options represents the configuration options for the admin API
+type Options struct {
+	// Token must be sent as "Authorization: Bearer <token>". Every request
+	// is rejected while it is empty.
+	Token string
+	// ReadOnly rejects every request that would delete entries
+	ReadOnly bool
+	// Logger records deletions and cache failures (nothing is logged if
+	// nil)
+	Logger *log.Logger
+}
+
+type handler struct {
+	cache   cache.Cache
+	options Options
+	mux     *http.ServeMux
+	now     func() time.Time
+}
+
+type keysResponse struct {
+	Keys   []string `json:"keys"`
+	Cursor string   `json:"cursor,omitempty"`
+}
+
+type entryResponse struct {
+	Key string `json:"key"`
+	// Type is the Go type of the value as returned by the cache
+	Type      string     `json:"type"`
+	Size      *int64     `json:"size,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	TTL       string     `json:"ttl,omitempty"`
+	// Encoding tells how Value renders the value: json, text or base64
+	Encoding string          `json:"encoding"`
+	Value    json.RawMessage `json:"value"`
+}
+
+type deletedResponse struct {
+	Deleted int `json:"deleted"`
+}
+
+type statsResponse struct {
+	Hits      uint64  `json:"hits"`
+	Misses    uint64  `json:"misses"`
+	Sets      uint64  `json:"sets"`
+	Deletes   uint64  `json:"deletes"`
+	Evictions uint64  `json:"evictions"`
+	Entries   int64   `json:"entries"`
+	HitRatio  float64 `json:"hit_ratio"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// NewHandler creates the admin API of c, to be mounted under a prefix with
+// http.StripPrefix:
+//
+//	GET    /keys?pattern=&cursor=&limit=  a page of keys
+//	DELETE /keys?pattern=&all=            deletes the matching keys
+//	GET    /entry?key=                    an entry with its metadata
+//	DELETE /entry?key=                    deletes an entry
+//	POST   /tables/invalidate?table=      drops the cached queries of tables
+//	GET    /stats                         statistics
+//
+// Patterns use '*' and '?' wildcards and '[...]' classes, see cache.Match.
+// Deleting with a pattern matching every key takes all=true.
+func NewHandler(c cache.Cache, options *Options) http.Handler {
+	h := &handler{
+		cache: c,
+		mux:   http.NewServeMux(),
+		now:   time.Now,
+	}
+	if options != nil {
+		h.options = *options
+	}
+
+	h.mux.HandleFunc("GET /keys", h.listKeys)
+	h.mux.HandleFunc("DELETE /keys", h.deleteKeys)
+	h.mux.HandleFunc("GET /entry", h.getEntry)
+	h.mux.HandleFunc("DELETE /entry", h.deleteEntry)
+	h.mux.HandleFunc("POST /tables/invalidate", h.invalidateTables)
+	h.mux.HandleFunc("GET /stats", h.stats)
+	return h
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !authorized(r, h.options.Token) {
+		h.unauthorized(w)
+		return
+	}
+	if h.options.ReadOnly && r.Method != http.MethodGet && r.Method != http.MethodHead {
+		h.writeError(w, http.StatusForbidden, errors.New("admin API is read-only"))
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+// RequireToken guards next with the same bearer token check as the admin
+// API, for other operator endpoints such as the hot key listing. Every
+// request is rejected while token is empty.
+func RequireToken(token string, next http.Handler) http.Handler {
+	h := &handler{}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r, token) {
+			h.unauthorized(w)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authorized checks the bearer token in constant time
+func authorized(r *http.Request, want string) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && want != "" &&
+		subtle.ConstantTimeCompare([]byte(token), []byte(want)) == 1
+}
+
+func (h *handler) unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	h.writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+}
+
+func (h *handler) listKeys(w http.ResponseWriter, r *http.Request) {
+	lister, ok := h.cache.(cache.KeyLister)
+	if !ok {
+		h.writeCacheError(w, cache.ErrNotSupported)
+		return
+	}
+
+	limit := defaultPageSize
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			h.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", raw))
+			return
+		}
+		limit = min(n, maxPageSize)
+	}
+
+	query := r.URL.Query()
+	page, err := lister.Keys(r.Context(), query.Get("pattern"), query.Get("cursor"), limit)
+	if err != nil {
+		h.writeCacheError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, keysResponse{Keys: page.Keys, Cursor: page.Cursor})
+}
+
+func (h *handler) deleteKeys(w http.ResponseWriter, r *http.Request) {
+	// Deleting everything takes an explicit "*" and all=true
+	query := r.URL.Query()
+	pattern := query.Get("pattern")
+	if pattern == "" {
+		h.writeError(w, http.StatusBadRequest, errors.New("pattern is required"))
+		return
+	}
+	if strings.Trim(pattern, "*") == "" && query.Get("all") != "true" {
+		h.writeError(w, http.StatusBadRequest, errors.New("pattern matches every key, add all=true"))
+		return
+	}
+
+	deleted, err := cache.DeleteMatching(r.Context(), h.cache, pattern)
+	if err != nil {
+		h.writeCacheError(w, err)
+		return
+	}
+	h.logf("deleted %d keys matching %q", deleted, pattern)
+	h.writeJSON(w, http.StatusOK, deletedResponse{Deleted: deleted})
+}
+
+func (h *handler) getEntry(w http.ResponseWriter, r *http.Request) {
+	key, ok := h.requireKey(w, r)
+	if !ok {
+		return
+	}
+
+	var info cache.EntryInfo
+	var err error
+	if inspector, ok := h.cache.(cache.Inspector); ok {
+		info, err = inspector.Inspect(r.Context(), key)
+	} else {
+		// Without an Inspector, the entry is read like any other
+		info = cache.EntryInfo{Key: key, Size: -1}
+		info.Value, err = h.cache.Get(r.Context(), key)
+		if err == nil && info.Value == nil {
+			err = cache.ErrNotFound
+		}
+	}
+	if err != nil {
+		h.writeCacheError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, h.entry(info))
+}
+
+func (h *handler) deleteEntry(w http.ResponseWriter, r *http.Request) {
+	key, ok := h.requireKey(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.cache.Delete(r.Context(), key); err != nil {
+		h.writeCacheError(w, err)
+		return
+	}
+	h.logf("deleted key %q", key)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) invalidateTables(w http.ResponseWriter, r *http.Request) {
+	tables := r.URL.Query()["table"]
+	if len(tables) == 0 {
+		h.writeError(w, http.StatusBadRequest, errors.New("table is required"))
+		return
+	}
+
+	deleted, err := gormCache.InvalidateTables(r.Context(), h.cache, tables...)
+	if err != nil {
+		h.writeCacheError(w, err)
+		return
+	}
+	h.logf("invalidated %d cached queries of %s", deleted, strings.Join(tables, ", "))
+	h.writeJSON(w, http.StatusOK, deletedResponse{Deleted: deleted})
+}
+
+func (h *handler) stats(w http.ResponseWriter, r *http.Request) {
+	provider, ok := h.cache.(cache.StatsProvider)
+	if !ok {
+		h.writeCacheError(w, cache.ErrNotSupported)
+		return
+	}
+
+	stats, err := provider.Stats(r.Context())
+	if err != nil {
+		h.writeCacheError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, statsResponse{
+		Hits:      stats.Hits,
+		Misses:    stats.Misses,
+		Sets:      stats.Sets,
+		Deletes:   stats.Deletes,
+		Evictions: stats.Evictions,
+		Entries:   stats.Entries,
+		HitRatio:  stats.HitRatio(),
+	})
+}
+
+// entry renders an entry, decoding byte values such as the JSON encoded
+// queries stored by the GORM plugin
+func (h *handler) entry(info cache.EntryInfo) entryResponse {
+	resp := entryResponse{Key: info.Key, Type: fmt.Sprintf("%T", info.Value)}
+	if info.Size >= 0 {
+		resp.Size = &info.Size
+	}
+	if !info.ExpiresAt.IsZero() {
+		resp.ExpiresAt = &info.ExpiresAt
+		resp.TTL = info.ExpiresAt.Sub(h.now()).Round(time.Second).String()
+	}
+
+	var value interface{} = info.Value
+	resp.Encoding = "json"
+	switch v := info.Value.(type) {
+	case []byte:
+		switch {
+		case json.Valid(v):
+			resp.Value = v
+			return resp
+		case utf8.Valid(v):
+			value, resp.Encoding = string(v), "text"
+		default:
+			value, resp.Encoding = base64.StdEncoding.EncodeToString(v), "base64"
+		}
+	case string:
+		resp.Encoding = "text"
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprintf("%+v", value))
+		resp.Encoding = "text"
+	}
+	resp.Value = data
+	return resp
+}
+
+func (h *handler) requireKey(w http.ResponseWriter, r *http.Request) (string, bool) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		h.writeError(w, http.StatusBadRequest, errors.New("key is required"))
+		return "", false
+	}
+	return key, true
+}
+
+// logf logs through Options.Logger, if any
+func (h *handler) logf(format string, args ...interface{}) {
+	if h.options.Logger != nil {
+		h.options.Logger.Printf("admin: "+format, args...)
+	}
+}
+
+// writeCacheError maps cache errors to statuses
+func (h *handler) writeCacheError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, cache.ErrNotFound):
+		h.writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, cache.ErrNotSupported):
+		h.writeError(w, http.StatusNotImplemented, err)
+	default:
+		h.logf("%v", err)
+		h.writeError(w, http.StatusInternalServerError, err)
+	}
+}
+
+func (h *handler) writeError(w http.ResponseWriter, status int, err error) {
+	h.writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func (h *handler) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		h.logf("failed to write response: %v", err)
+	}
+}
@@ -0,0 +1,300 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/breaker"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+const testToken = "s3cret"
+
+// bareCache hides every optional interface of the cache it wraps
+type bareCache struct {
+	cache.Cache
+}
+
+func newTestCache(t *testing.T) cache.Cache {
+	c, err := memory.New(&cache.Options{DefaultTTL: time.Minute, MaxTTL: time.Minute})
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+// do sends an authorized request and decodes the JSON response into out
+func do(t *testing.T, h http.Handler, method, target string, out interface{}) int {
+	t.Helper()
+	req := httptest.NewRequest(method, target, nil)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if out != nil {
+		body, _ := io.ReadAll(rec.Body)
+		assert.NoError(t, json.Unmarshal(body, out), string(body))
+	}
+	return rec.Code
+}
+
+func TestHandler_Auth(t *testing.T) {
+	c := newTestCache(t)
+	h := NewHandler(c, &Options{Token: testToken})
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer nope", http.StatusUnauthorized},
+		{"wrong scheme", "Basic " + testToken, http.StatusUnauthorized},
+		{"valid token", "Bearer " + testToken, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/stats", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+
+	t.Run("no token configured", func(t *testing.T) {
+		var resp errorResponse
+		assert.Equal(t, http.StatusUnauthorized, do(t, NewHandler(c, nil), http.MethodGet, "/stats", &resp))
+	})
+}
+
+func TestRequireToken(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		want   int
+	}{
+		{"no token", testToken, "", http.StatusUnauthorized},
+		{"wrong token", testToken, "Bearer nope", http.StatusUnauthorized},
+		{"valid token", testToken, "Bearer " + testToken, http.StatusTeapot},
+		{"no token configured", "", "Bearer ", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/debug/hotkeys", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			RequireToken(tt.token, next).ServeHTTP(rec, req)
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
+
+func TestHandler_Keys(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCache(t)
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, c.Set(ctx, fmt.Sprintf("users:%d", i), "value", 0))
+	}
+	assert.NoError(t, c.Set(ctx, "roles:0", "value", 0))
+	h := NewHandler(c, &Options{Token: testToken})
+
+	var page keysResponse
+	assert.Equal(t, http.StatusOK, do(t, h, http.MethodGet, "/keys?pattern=users:*&limit=3", &page))
+	assert.Equal(t, keysResponse{Keys: []string{"users:0", "users:1", "users:2"}, Cursor: "users:2"}, page)
+
+	var last keysResponse
+	assert.Equal(t, http.StatusOK, do(t, h, http.MethodGet, "/keys?pattern=users:*&limit=3&cursor="+page.Cursor, &last))
+	assert.Equal(t, keysResponse{Keys: []string{"users:3", "users:4"}}, last)
+
+	var resp errorResponse
+	assert.Equal(t, http.StatusBadRequest, do(t, h, http.MethodGet, "/keys?limit=0", &resp))
+
+	var deleted deletedResponse
+	assert.Equal(t, http.StatusOK, do(t, h, http.MethodDelete, "/keys?pattern=users:*", &deleted))
+	assert.Equal(t, 5, deleted.Deleted)
+	assert.Equal(t, http.StatusOK, do(t, h, http.MethodGet, "/keys", &page))
+	assert.Equal(t, []string{"roles:0"}, page.Keys)
+
+	assert.Equal(t, http.StatusBadRequest, do(t, h, http.MethodDelete, "/keys", &resp))
+	assert.Equal(t, "pattern is required", resp.Error)
+
+	// Deleting every key must be asked for
+	assert.Equal(t, http.StatusBadRequest, do(t, h, http.MethodDelete, "/keys?pattern=**", &resp))
+	assert.Equal(t, http.StatusOK, do(t, h, http.MethodGet, "/keys", &page))
+	assert.Equal(t, []string{"roles:0"}, page.Keys)
+
+	t.Run("through decorators", func(t *testing.T) {
+		h := NewHandler(breaker.New(c, nil), &Options{Token: testToken})
+		assert.Equal(t, http.StatusOK, do(t, h, http.MethodGet, "/keys", &page))
+		assert.Equal(t, []string{"roles:0"}, page.Keys)
+	})
+
+	t.Run("not supported", func(t *testing.T) {
+		h := NewHandler(bareCache{c}, &Options{Token: testToken})
+		assert.Equal(t, http.StatusNotImplemented, do(t, h, http.MethodGet, "/keys", &resp))
+		assert.Equal(t, http.StatusNotImplemented, do(t, h, http.MethodDelete, "/keys?pattern=*&all=true", &resp))
+		assert.Equal(t, http.StatusNotImplemented, do(t, h, http.MethodGet, "/stats", &resp))
+	})
+
+	assert.Equal(t, http.StatusOK, do(t, h, http.MethodDelete, "/keys?pattern=*&all=true", &deleted))
+	assert.Equal(t, 1, deleted.Deleted)
+}
+
+func TestHandler_Entry(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCache(t)
+	h := NewHandler(c, &Options{Token: testToken}).(*handler)
+	now := time.Now()
+	h.now = func() time.Time { return now }
+
+	// An entry as stored by the GORM plugin
+	query := "users:1f0e3dad:SELECT * FROM `users` WHERE id = 1"
+	assert.NoError(t, c.Set(ctx, query, []byte(`{"Dest":{"ID":1,"Name":"admin"},"RowsAffected":1}`), 0))
+	assert.NoError(t, c.Set(ctx, "text", []byte("plain text"), 0))
+	assert.NoError(t, c.Set(ctx, "binary", []byte{0xff, 0x00}, 0))
+	assert.NoError(t, c.Set(ctx, "struct", map[string]int{"n": 1}, 0))
+
+	var entry entryResponse
+	assert.Equal(t, http.StatusOK, do(t, h, http.MethodGet, "/entry?key="+url.QueryEscape(query), &entry))
+	assert.Equal(t, query, entry.Key)
+	assert.Equal(t, "[]uint8", entry.Type)
+	assert.Equal(t, "json", entry.Encoding)
+	assert.JSONEq(t, `{"Dest":{"ID":1,"Name":"admin"},"RowsAffected":1}`, string(entry.Value))
+	if assert.NotNil(t, entry.Size) {
+		assert.Equal(t, int64(49), *entry.Size)
+	}
+	assert.NotNil(t, entry.ExpiresAt)
+	assert.Equal(t, "1m0s", entry.TTL)
+
+	tests := []struct {
+		key, encoding, value string
+	}{
+		{"text", "text", `"plain text"`},
+		{"binary", "base64", `"/wA="`},
+		{"struct", "json", `{"n":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			var entry entryResponse
+			assert.Equal(t, http.StatusOK, do(t, h, http.MethodGet, "/entry?key="+tt.key, &entry))
+			assert.Equal(t, tt.encoding, entry.Encoding)
+			assert.JSONEq(t, tt.value, string(entry.Value))
+		})
+	}
+
+	var resp errorResponse
+	assert.Equal(t, http.StatusNotFound, do(t, h, http.MethodGet, "/entry?key=missing", &resp))
+	assert.Equal(t, http.StatusBadRequest, do(t, h, http.MethodGet, "/entry", &resp))
+
+	assert.Equal(t, http.StatusNoContent, do(t, h, http.MethodDelete, "/entry?key=text", nil))
+	assert.Equal(t, http.StatusNotFound, do(t, h, http.MethodGet, "/entry?key=text", &resp))
+
+	t.Run("without inspector", func(t *testing.T) {
+		h := NewHandler(bareCache{c}, &Options{Token: testToken})
+		var entry entryResponse
+		assert.Equal(t, http.StatusOK, do(t, h, http.MethodGet, "/entry?key=struct", &entry))
+		assert.Nil(t, entry.Size)
+		assert.Nil(t, entry.ExpiresAt)
+		assert.JSONEq(t, `{"n":1}`, string(entry.Value))
+		assert.Equal(t, http.StatusNotFound, do(t, h, http.MethodGet, "/entry?key=missing", &resp))
+	})
+}
+
+func TestHandler_InvalidateTables(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCache(t)
+	for _, key := range []string{"users:1f0e3dad:q1", "users:2c4a1b9e:q2", "roles:1f0e3dad:q1", "user_roles:1f0e3dad:q1"} {
+		assert.NoError(t, c.Set(ctx, key, []byte("{}"), 0))
+	}
+	h := NewHandler(c, &Options{Token: testToken})
+
+	var deleted deletedResponse
+	assert.Equal(t, http.StatusOK, do(t, h, http.MethodPost, "/tables/invalidate?table=users&table=roles", &deleted))
+	assert.Equal(t, 3, deleted.Deleted)
+
+	var page keysResponse
+	assert.Equal(t, http.StatusOK, do(t, h, http.MethodGet, "/keys", &page))
+	assert.Equal(t, []string{"user_roles:1f0e3dad:q1"}, page.Keys)
+
+	var resp errorResponse
+	assert.Equal(t, http.StatusBadRequest, do(t, h, http.MethodPost, "/tables/invalidate", &resp))
+}
+
+func TestHandler_Stats(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCache(t)
+	assert.NoError(t, c.Set(ctx, "key", "value", 0))
+	_, _ = c.Get(ctx, "key")
+	_, _ = c.Get(ctx, "missing")
+	h := NewHandler(c, &Options{Token: testToken})
+
+	var stats statsResponse
+	assert.Equal(t, http.StatusOK, do(t, h, http.MethodGet, "/stats", &stats))
+	assert.Equal(t, statsResponse{Hits: 1, Misses: 1, Sets: 1, Entries: 1, HitRatio: 0.5}, stats)
+}
+
+func TestHandler_ReadOnly(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCache(t)
+	assert.NoError(t, c.Set(ctx, "key", "value", 0))
+	h := NewHandler(c, &Options{Token: testToken, ReadOnly: true})
+
+	var resp errorResponse
+	assert.Equal(t, http.StatusForbidden, do(t, h, http.MethodDelete, "/entry?key=key", &resp))
+	assert.Equal(t, http.StatusForbidden, do(t, h, http.MethodDelete, "/keys?pattern=*", &resp))
+	assert.Equal(t, http.StatusForbidden, do(t, h, http.MethodPost, "/tables/invalidate?table=users", &resp))
+
+	var entry entryResponse
+	assert.Equal(t, http.StatusOK, do(t, h, http.MethodGet, "/entry?key=key", &entry))
+	assert.JSONEq(t, `"value"`, string(entry.Value))
+}
+
+// failingCache fails every deletion
+type failingCache struct {
+	cache.Cache
+}
+
+func (failingCache) Delete(context.Context, string) error {
+	return errors.New("backend down")
+}
+
+func TestHandler_Logger(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCache(t)
+	assert.NoError(t, c.Set(ctx, "key", "value", 0))
+
+	var logs bytes.Buffer
+	h := NewHandler(c, &Options{Token: testToken, Logger: log.New(&logs, "", 0)})
+	assert.Equal(t, http.StatusNoContent, do(t, h, http.MethodDelete, "/entry?key=key", nil))
+	assert.Equal(t, "admin: deleted key \"key\"\n", logs.String())
+
+	logs.Reset()
+	h = NewHandler(failingCache{c}, &Options{Token: testToken, Logger: log.New(&logs, "", 0)})
+	assert.Equal(t, http.StatusInternalServerError, do(t, h, http.MethodDelete, "/entry?key=key", nil))
+	assert.Equal(t, "admin: backend down\n", logs.String())
+
+	// Nothing is logged without a logger
+	h = NewHandler(failingCache{c}, &Options{Token: testToken})
+	assert.Equal(t, http.StatusInternalServerError, do(t, h, http.MethodDelete, "/entry?key=key", nil))
+}
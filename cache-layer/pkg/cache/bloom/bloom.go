@@ -0,0 +1,106 @@
+// Package bloom implements cache.Filter with Bloom filters kept in process or
+// in Redis. A filter holding every existing key lets lookups of keys that
+// never exist, such as IDs scanned by bots, be answered without touching the
+// cache backend's miss path or the database.
+package bloom
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"time"
+
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+)
+
+// Source feeds every existing key to add; it is used to rebuild a filter
+type Source func(ctx context.Context, add func(keys ...string) error) error
+
+// Filter is a Bloom filter that can be rebuilt from its source
+type Filter interface {
+	cache.Filter
+
+	// Rebuild replaces the filter contents with the keys of source. Keys
+	// added while the rebuild is running are kept.
+	Rebuild(ctx context.Context, source Source) error
+}
+
+// Options represents the configuration options for a Bloom filter
+type Options struct {
+	// ExpectedItems is the number of keys the filter is sized for (defaults to 100000)
+	ExpectedItems uint64
+	// FalsePositiveRate is the target rate at ExpectedItems keys (defaults to 0.01)
+	FalsePositiveRate float64
+}
+
+// params holds the size of a filter derived from its options
+type params struct {
+	bits   uint64 // m
+	hashes uint64 // k
+}
+
+func newParams(options *Options) params {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.ExpectedItems == 0 {
+		opts.ExpectedItems = 100000
+	}
+	if opts.FalsePositiveRate <= 0 || opts.FalsePositiveRate >= 1 {
+		opts.FalsePositiveRate = 0.01
+	}
+
+	// m = -n ln p / (ln 2)^2, k = m/n ln 2
+	n := float64(opts.ExpectedItems)
+	m := math.Ceil(-n * math.Log(opts.FalsePositiveRate) / (math.Ln2 * math.Ln2))
+	k := math.Round(m / n * math.Ln2)
+	return params{
+		bits:   uint64(max(m, 64)),
+		hashes: uint64(max(k, 1)),
+	}
+}
+
+// locations returns the bit positions of key. Hashes are derived from a
+// single FNV-1a hash (Kirsch-Mitzenmacher) and are stable across processes,
+// which the Redis filter relies on.
+func (p params) locations(key string) []uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	h1 := h.Sum64()
+	h2 := mix(h1) | 1
+
+	locs := make([]uint64, p.hashes)
+	for i := range locs {
+		locs[i] = (h1 + uint64(i)*h2) % p.bits
+	}
+	return locs
+}
+
+// mix is the splitmix64 finalizer
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// RebuildEvery rebuilds f from source every interval until ctx is done,
+// reporting failed rebuilds to onError if it is set
+func RebuildEvery(ctx context.Context, f Filter, source Source, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := f.Rebuild(ctx, source); err != nil && ctx.Err() == nil && onError != nil {
+				onError(err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
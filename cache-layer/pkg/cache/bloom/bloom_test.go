@@ -0,0 +1,183 @@
+package bloom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParams(t *testing.T) {
+	// 1M items at 1% need ~9.6M bits and 7 hashes
+	p := newParams(&Options{ExpectedItems: 1000000, FalsePositiveRate: 0.01})
+	assert.InDelta(t, 9585059, p.bits, 1)
+	assert.Equal(t, uint64(7), p.hashes)
+
+	p = newParams(nil)
+	assert.Equal(t, newParams(&Options{ExpectedItems: 100000, FalsePositiveRate: 0.01}), p)
+}
+
+func keysOf(prefix string, n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%s:%d", prefix, i)
+	}
+	return keys
+}
+
+// testFilter checks the behaviour shared by every Filter implementation
+func testFilter(t *testing.T, newFilter func(*Options) Filter) {
+	ctx := context.Background()
+
+	t.Run("no false negatives and a bounded false positive rate", func(t *testing.T) {
+		f := newFilter(&Options{ExpectedItems: 2000, FalsePositiveRate: 0.01})
+		assert.NoError(t, f.Rebuild(ctx, func(ctx context.Context, add func(keys ...string) error) error {
+			return nil
+		}))
+
+		members := keysOf("users", 2000)
+		assert.NoError(t, f.Add(ctx, members...))
+		for _, key := range members {
+			ok, err := f.MayContain(ctx, key)
+			assert.NoError(t, err)
+			assert.True(t, ok, key)
+		}
+
+		falsePositives := 0
+		for _, key := range keysOf("missing", 2000) {
+			ok, err := f.MayContain(ctx, key)
+			assert.NoError(t, err)
+			if ok {
+				falsePositives++
+			}
+		}
+		assert.Less(t, falsePositives, 60)
+	})
+
+	t.Run("rebuild replaces the contents", func(t *testing.T) {
+		f := newFilter(nil)
+		assert.NoError(t, f.Add(ctx, "users:1", "users:2"))
+
+		assert.NoError(t, f.Rebuild(ctx, func(ctx context.Context, add func(keys ...string) error) error {
+			// Keys added during a rebuild survive it
+			assert.NoError(t, f.Add(ctx, "users:4"))
+			return add("users:2", "users:3")
+		}))
+
+		for key, want := range map[string]bool{"users:1": false, "users:2": true, "users:3": true, "users:4": true} {
+			ok, err := f.MayContain(ctx, key)
+			assert.NoError(t, err)
+			assert.Equal(t, want, ok, key)
+		}
+	})
+
+	t.Run("failed rebuild keeps the contents", func(t *testing.T) {
+		f := newFilter(nil)
+		assert.NoError(t, f.Add(ctx, "users:1"))
+
+		errSource := errors.New("database unavailable")
+		err := f.Rebuild(ctx, func(ctx context.Context, add func(keys ...string) error) error {
+			return errSource
+		})
+		assert.ErrorIs(t, err, errSource)
+
+		ok, err := f.MayContain(ctx, "users:1")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+}
+
+func TestMemory(t *testing.T) {
+	testFilter(t, func(options *Options) Filter { return NewMemory(options) })
+}
+
+func TestRedis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	n := 0
+	testFilter(t, func(options *Options) Filter {
+		n++
+		return NewRedis(client, fmt.Sprintf("bloom:%d", n), options)
+	})
+
+	empty := func(ctx context.Context, add func(keys ...string) error) error { return nil }
+
+	t.Run("shared between instances", func(t *testing.T) {
+		ctx := context.Background()
+		a := NewRedis(client, "bloom:shared", nil)
+		b := NewRedis(client, "bloom:shared", nil)
+		assert.NoError(t, a.Rebuild(ctx, empty))
+
+		assert.NoError(t, a.Add(ctx, "users:1"))
+		ok, err := b.MayContain(ctx, "users:1")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		ok, err = b.MayContain(ctx, "users:2")
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("adds of other instances survive a rebuild", func(t *testing.T) {
+		ctx := context.Background()
+		a := NewRedis(client, "bloom:concurrent", nil)
+		b := NewRedis(client, "bloom:concurrent", nil)
+
+		assert.NoError(t, a.Rebuild(ctx, func(ctx context.Context, add func(keys ...string) error) error {
+			assert.NoError(t, b.Add(ctx, "users:2"))
+			return add("users:1")
+		}))
+
+		for _, key := range []string{"users:1", "users:2"} {
+			ok, err := b.MayContain(ctx, key)
+			assert.NoError(t, err)
+			assert.True(t, ok, key)
+		}
+		assert.Equal(t, []string{"bloom:concurrent"}, keysMatching(mr, "bloom:concurrent*"))
+		assert.Equal(t, time.Duration(0), mr.TTL("bloom:concurrent"))
+	})
+
+	t.Run("deleted bitmap rules nothing out", func(t *testing.T) {
+		ctx := context.Background()
+		f := NewRedis(client, "bloom:flushed", nil)
+		assert.NoError(t, f.Rebuild(ctx, func(ctx context.Context, add func(keys ...string) error) error {
+			return add("users:1")
+		}))
+
+		// e.g. FLUSHDB by a cache sharing the database
+		mr.Del("bloom:flushed")
+		assert.NoError(t, f.Add(ctx, "users:2"))
+		assert.False(t, mr.Exists("bloom:flushed"))
+
+		ok, err := f.MayContain(ctx, "users:1")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("failed rebuild leaves no bitmap behind", func(t *testing.T) {
+		ctx := context.Background()
+		f := NewRedis(client, "bloom:failed", nil)
+		err := f.Rebuild(ctx, func(ctx context.Context, add func(keys ...string) error) error {
+			return errors.New("database unavailable")
+		})
+		assert.Error(t, err)
+		assert.Empty(t, keysMatching(mr, "bloom:failed*"))
+	})
+}
+
+func keysMatching(mr *miniredis.Miniredis, pattern string) []string {
+	var keys []string
+	for _, key := range mr.Keys() {
+		if ok, _ := path.Match(pattern, key); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,93 @@
+package bloom
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory is an in-process Bloom filter. It only learns about keys added in
+// this process, so it must not guard rows that other processes create.
+type Memory struct {
+	params    params
+	rebuildMu sync.Mutex // serializes rebuilds
+
+	mu    sync.RWMutex
+	words []uint64
+	// added collects keys added while a rebuild is running
+	added      []string
+	rebuilding bool
+}
+
+// NewMemory creates an empty in-process Bloom filter
+func NewMemory(options *Options) *Memory {
+	p := newParams(options)
+	return &Memory{
+		params: p,
+		words:  make([]uint64, (p.bits+63)/64),
+	}
+}
+
+// Add implements cache.Filter
+func (f *Memory) Add(ctx context.Context, keys ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, key := range keys {
+		f.set(f.words, key)
+	}
+	if f.rebuilding {
+		f.added = append(f.added, keys...)
+	}
+	return nil
+}
+
+// MayContain implements cache.Filter
+func (f *Memory) MayContain(ctx context.Context, key string) (bool, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	for _, loc := range f.params.locations(key) {
+		if f.words[loc/64]&(1<<(loc%64)) == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Rebuild implements Filter. The current contents keep answering lookups
+// until the new ones are complete.
+func (f *Memory) Rebuild(ctx context.Context, source Source) error {
+	f.rebuildMu.Lock()
+	defer f.rebuildMu.Unlock()
+
+	f.mu.Lock()
+	f.rebuilding = true
+	f.added = nil
+	f.mu.Unlock()
+
+	words := make([]uint64, len(f.words))
+	err := source(ctx, func(keys ...string) error {
+		for _, key := range keys {
+			f.set(words, key)
+		}
+		return ctx.Err()
+	})
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rebuilding = false
+	if err == nil {
+		for _, key := range f.added {
+			f.set(words, key)
+		}
+		f.words = words
+	}
+	f.added = nil
+	return err
+}
+
+func (f *Memory) set(words []uint64, key string) {
+	for _, loc := range f.params.locations(key) {
+		words[loc/64] |= 1 << (loc % 64)
+	}
+}
@@ -0,0 +1,171 @@
+package bloom
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// rebuildTTL bounds how long the bitmap of an abandoned rebuild, such as one
+// whose process crashed, is kept and written to
+const rebuildTTL = time.Hour
+
+// addScript sets the bits of ARGV in the bitmap KEYS[1] and in every bitmap
+// being rebuilt, listed in the set KEYS[2]. A missing bitmap, e.g. one
+// removed by FLUSHDB, is left missing rather than recreated with only the
+// new bits, which would rule out every other key.
+var addScript = redis.NewScript(`
+local targets = {}
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	table.insert(targets, KEYS[1])
+end
+for _, tmp in ipairs(redis.call('SMEMBERS', KEYS[2])) do
+	if redis.call('EXISTS', tmp) == 1 then
+		table.insert(targets, tmp)
+	else
+		redis.call('SREM', KEYS[2], tmp)
+	end
+end
+for _, target in ipairs(targets) do
+	for _, loc in ipairs(ARGV) do
+		redis.call('SETBIT', target, loc, 1)
+	end
+end
+return #targets
+`)
+
+// Redis is a Bloom filter stored as a bitmap in a Redis string, so that it is
+// shared by every instance of the application. While the bitmap is missing,
+// before the first rebuild or after it was deleted, MayContain reports every
+// key as possibly present.
+type Redis struct {
+	client *redis.Client
+	key    string
+	params params
+
+	rebuildMu sync.Mutex // serializes rebuilds
+}
+
+// NewRedis creates a Bloom filter stored under key. Filters sharing a key
+// must be created with the same options.
+func NewRedis(client *redis.Client, key string, options *Options) *Redis {
+	return &Redis{
+		client: client,
+		key:    key,
+		params: newParams(options),
+	}
+}
+
+// Add implements cache.Filter. Keys are also added to the bitmaps being
+// rebuilt by any instance, so that they survive the rebuild.
+func (f *Redis) Add(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	var locs []interface{}
+	for _, key := range keys {
+		for _, loc := range f.params.locations(key) {
+			locs = append(locs, loc)
+		}
+	}
+	return addScript.Run(ctx, f.client, []string{f.key, f.rebuildsKey()}, locs...).Err()
+}
+
+// MayContain implements cache.Filter
+func (f *Redis) MayContain(ctx context.Context, key string) (bool, error) {
+	pipe := f.client.Pipeline()
+	exists := pipe.Exists(ctx, f.key)
+	locs := f.params.locations(key)
+	cmds := make([]*redis.IntCmd, len(locs))
+	for i, loc := range locs {
+		cmds[i] = pipe.GetBit(ctx, f.key, int64(loc))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+
+	if exists.Val() == 0 {
+		return true, nil
+	}
+	for _, cmd := range cmds {
+		if cmd.Val() == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Rebuild implements Filter. The new bitmap is filled under a key of its own
+// and renamed over the current one, so lookups never see a partial filter.
+// The key is registered for the duration of the rebuild, so that keys added
+// meanwhile by any instance are written to it as well.
+func (f *Redis) Rebuild(ctx context.Context, source Source) error {
+	f.rebuildMu.Lock()
+	defer f.rebuildMu.Unlock()
+
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return err
+	}
+	tmp := f.key + ":rebuild:" + hex.EncodeToString(id[:])
+
+	// Allocating the full bitmap up front also makes sure the key exists
+	// for RENAME when the source is empty
+	pipe := f.client.TxPipeline()
+	pipe.SetBit(ctx, tmp, int64(f.params.bits-1), 0)
+	pipe.PExpire(ctx, tmp, rebuildTTL)
+	pipe.SAdd(ctx, f.rebuildsKey(), tmp)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	if err := source(ctx, func(keys ...string) error {
+		return f.setBits(ctx, keys, tmp)
+	}); err != nil {
+		f.discard(context.WithoutCancel(ctx), tmp)
+		return err
+	}
+
+	pipe = f.client.TxPipeline()
+	pipe.SRem(ctx, f.rebuildsKey(), tmp)
+	pipe.Rename(ctx, tmp, f.key)
+	pipe.Persist(ctx, f.key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		f.discard(context.WithoutCancel(ctx), tmp)
+		return err
+	}
+	return nil
+}
+
+// discard drops the bitmap of a failed rebuild
+func (f *Redis) discard(ctx context.Context, tmp string) {
+	pipe := f.client.TxPipeline()
+	pipe.SRem(ctx, f.rebuildsKey(), tmp)
+	pipe.Del(ctx, tmp)
+	_, _ = pipe.Exec(ctx)
+}
+
+func (f *Redis) setBits(ctx context.Context, keys []string, target string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	pipe := f.client.Pipeline()
+	for _, key := range keys {
+		for _, loc := range f.params.locations(key) {
+			pipe.SetBit(ctx, target, int64(loc), 1)
+		}
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// rebuildsKey is the set of bitmaps currently being rebuilt
+func (f *Redis) rebuildsKey() string {
+	return f.key + ":rebuilds"
+}
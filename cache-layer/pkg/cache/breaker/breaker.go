@@ -0,0 +1,332 @@
+// Package breaker wraps a cache.Cache with a circuit breaker so that a slow or
+// unavailable backend (typically Redis) degrades into cache misses instead of
+// blocking every request for the full client timeout.
+package breaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+)
+
+// ErrOpen is returned when the breaker rejects a call without reaching the backend
+var ErrOpen = errors.New("cache: circuit breaker is open")
+
+// State represents the state of the circuit breaker
+type State int
+
+const (
+	// StateClosed lets every call through to the backend
+	StateClosed State = iota
+	// StateOpen rejects every call until OpenTimeout has elapsed
+	StateOpen
+	// StateHalfOpen lets a limited number of probe calls through
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes a single state transition of the breaker
+type Event struct {
+	From State
+	To   State
+	At   time.Time
+	// Err is the backend error that caused the transition, if any
+	Err error
+}
+
+// Options represents the configuration options for a circuit breaker
+type Options struct {
+	// FailureThreshold is the number of consecutive failures that opens the breaker
+	FailureThreshold int
+	// SuccessThreshold is the number of successful probes that closes a half-open breaker
+	SuccessThreshold int
+	// OpenTimeout is how long the breaker stays open before allowing a probe
+	OpenTimeout time.Duration
+	// HalfOpenMaxCalls is the number of concurrent probes allowed while half-open
+	HalfOpenMaxCalls int
+	// Timeout bounds each backend call; 0 leaves the caller's context untouched
+	Timeout time.Duration
+	// FailOpen turns rejected calls and backend errors into cache misses
+	// (Get returns cache.ErrNotFound, Set returns nil) so that callers such
+	// as the GORM plugin fall straight through to the database. Delete and
+	// Clear errors are always returned, since swallowing a failed
+	// invalidation would leave stale entries behind
+	FailOpen bool
+	// OnStateChange is called after every state transition
+	OnStateChange func(Event)
+	// OnError, if set, is called with every backend error, including those
+	// hidden by FailOpen
+	OnError func(error)
+}
+
+// Breaker is a cache.Cache decorator guarding another cache.Cache
+type Breaker struct {
+	next    cache.Cache
+	options Options
+	now     func() time.Time
+
+	mu        sync.Mutex
+	state     State
+	failures  int
+	successes int
+	probes    int
+	openedAt  time.Time
+	// generation is bumped on every transition so that outcomes of calls
+	// admitted in an earlier state are ignored
+	generation uint64
+}
+
+// New wraps next with a circuit breaker
+func New(next cache.Cache, options *Options) *Breaker {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = 5
+	}
+	if opts.SuccessThreshold <= 0 {
+		opts.SuccessThreshold = 1
+	}
+	if opts.OpenTimeout <= 0 {
+		opts.OpenTimeout = 10 * time.Second
+	}
+	if opts.HalfOpenMaxCalls <= 0 {
+		opts.HalfOpenMaxCalls = 1
+	}
+
+	return &Breaker{
+		next:    next,
+		options: opts,
+		now:     time.Now,
+	}
+}
+
+// State returns the current state of the breaker
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.options.OpenTimeout {
+		return StateHalfOpen
+	}
+	return b.state
+}
+
+func (b *Breaker) Get(ctx context.Context, key string) (interface{}, error) {
+	var value interface{}
+	err := b.do(ctx, func(ctx context.Context) error {
+		var err error
+		value, err = b.next.Get(ctx, key)
+		return err
+	})
+	if err != nil {
+		if b.options.FailOpen && !errors.Is(err, cache.ErrNotFound) {
+			return nil, cache.ErrNotFound
+		}
+		return nil, err
+	}
+	return value, nil
+}
+
+func (b *Breaker) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	err := b.do(ctx, func(ctx context.Context) error {
+		return b.next.Set(ctx, key, value, ttl)
+	})
+	if err != nil && b.options.FailOpen {
+		return nil
+	}
+	return err
+}
+
+func (b *Breaker) Delete(ctx context.Context, key string) error {
+	return b.do(ctx, func(ctx context.Context) error {
+		return b.next.Delete(ctx, key)
+	})
+}
+
+func (b *Breaker) Clear(ctx context.Context) error {
+	return b.do(ctx, func(ctx context.Context) error {
+		return b.next.Clear(ctx)
+	})
+}
+
+// Stats implements cache.StatsProvider when the wrapped cache does. It
+// bypasses the breaker, so slow stats queries never trip it
+func (b *Breaker) Stats(ctx context.Context) (cache.Stats, error) {
+	provider, ok := b.next.(cache.StatsProvider)
+	if !ok {
+		return cache.Stats{}, cache.ErrNotSupported
+	}
+	return provider.Stats(ctx)
+}
+
+// Keys implements cache.KeyLister when the wrapped cache does. Like Stats,
+// it bypasses the breaker: a slow listing must not trip it, and a failure
+// must not be hidden as an empty page.
+func (b *Breaker) Keys(ctx context.Context, pattern, cursor string, limit int) (cache.KeyPage, error) {
+	lister, ok := b.next.(cache.KeyLister)
+	if !ok {
+		return cache.KeyPage{}, cache.ErrNotSupported
+	}
+	return lister.Keys(ctx, pattern, cursor, limit)
+}
+
+// Inspect implements cache.Inspector when the wrapped cache does. It
+// bypasses the breaker like Stats.
+func (b *Breaker) Inspect(ctx context.Context, key string) (cache.EntryInfo, error) {
+	inspector, ok := b.next.(cache.Inspector)
+	if !ok {
+		return cache.EntryInfo{}, cache.ErrNotSupported
+	}
+	return inspector.Inspect(ctx, key)
+}
+
+func (b *Breaker) Close() error {
+	return b.next.Close()
+}
+
+// do runs fn through the breaker and records its outcome
+func (b *Breaker) do(ctx context.Context, fn func(context.Context) error) error {
+	generation, err := b.acquire()
+	if err != nil {
+		return err
+	}
+
+	callCtx := ctx
+	if b.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, b.options.Timeout)
+		defer cancel()
+	}
+
+	err = fn(callCtx)
+	switch {
+	case err == nil, errors.Is(err, cache.ErrNotFound):
+		b.record(generation, nil)
+	case ctx.Err() != nil:
+		// The caller gave up; that says nothing about the backend
+		b.release(generation)
+	default:
+		b.record(generation, err)
+		if b.options.OnError != nil {
+			b.options.OnError(err)
+		}
+	}
+	return err
+}
+
+// acquire decides whether a call may reach the backend and returns the
+// generation it was admitted in
+func (b *Breaker) acquire() (uint64, error) {
+	b.mu.Lock()
+
+	switch b.state {
+	case StateOpen:
+		if b.now().Sub(b.openedAt) < b.options.OpenTimeout {
+			b.mu.Unlock()
+			return 0, ErrOpen
+		}
+		ev := b.transition(StateHalfOpen, nil)
+		b.probes++
+		generation := b.generation
+		b.mu.Unlock()
+		b.emit(ev)
+		return generation, nil
+	case StateHalfOpen:
+		if b.probes >= b.options.HalfOpenMaxCalls {
+			b.mu.Unlock()
+			return 0, ErrOpen
+		}
+		b.probes++
+	}
+
+	generation := b.generation
+	b.mu.Unlock()
+	return generation, nil
+}
+
+// release gives back a half-open probe slot without recording an outcome
+func (b *Breaker) release(generation uint64) {
+	b.mu.Lock()
+	if generation == b.generation && b.state == StateHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+	b.mu.Unlock()
+}
+
+// record updates the breaker with the outcome of a backend call admitted in
+// the given generation
+func (b *Breaker) record(generation uint64, err error) {
+	b.mu.Lock()
+	if generation != b.generation {
+		// The breaker changed state while the call was in flight
+		b.mu.Unlock()
+		return
+	}
+
+	var ev *Event
+	switch b.state {
+	case StateClosed:
+		if err == nil {
+			b.failures = 0
+			break
+		}
+		b.failures++
+		if b.failures >= b.options.FailureThreshold {
+			ev = b.transition(StateOpen, err)
+		}
+	case StateHalfOpen:
+		if b.probes > 0 {
+			b.probes--
+		}
+		if err != nil {
+			ev = b.transition(StateOpen, err)
+			break
+		}
+		b.successes++
+		if b.successes >= b.options.SuccessThreshold {
+			ev = b.transition(StateClosed, nil)
+		}
+	}
+
+	b.mu.Unlock()
+	b.emit(ev)
+}
+
+// transition moves the breaker to a new state; callers must hold b.mu
+func (b *Breaker) transition(to State, err error) *Event {
+	ev := &Event{From: b.state, To: to, At: b.now(), Err: err}
+
+	b.state = to
+	b.generation++
+	b.failures = 0
+	b.successes = 0
+	b.probes = 0
+	if to == StateOpen {
+		b.openedAt = ev.At
+	}
+	return ev
+}
+
+// emit delivers a transition event outside of the breaker lock
+func (b *Breaker) emit(ev *Event) {
+	if ev != nil && b.options.OnStateChange != nil {
+		b.options.OnStateChange(*ev)
+	}
+}
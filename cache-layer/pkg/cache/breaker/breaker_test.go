@@ -0,0 +1,244 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+var errBackend = errors.New("backend unavailable")
+
+// flakyCache wraps a cache and fails every call while down is set
+type flakyCache struct {
+	cache.Cache
+	mu    sync.Mutex
+	down  bool
+	calls int
+}
+
+func (f *flakyCache) setDown(down bool) {
+	f.mu.Lock()
+	f.down = down
+	f.mu.Unlock()
+}
+
+func (f *flakyCache) check() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.down {
+		return errBackend
+	}
+	return nil
+}
+
+func (f *flakyCache) Get(ctx context.Context, key string) (interface{}, error) {
+	if err := f.check(); err != nil {
+		return nil, err
+	}
+	return f.Cache.Get(ctx, key)
+}
+
+func (f *flakyCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	if err := f.check(); err != nil {
+		return err
+	}
+	return f.Cache.Set(ctx, key, value, ttl)
+}
+
+func newFlaky() *flakyCache {
+	return &flakyCache{Cache: memory.NewGoCacheWrapper(&cache.Options{DefaultTTL: time.Minute})}
+}
+
+// fakeClock is a manually advanced clock for the breaker
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestBreaker_StateTransitions(t *testing.T) {
+	ctx := context.Background()
+	backend := newFlaky()
+	clock := &fakeClock{t: time.Now()}
+
+	var events []Event
+	b := New(backend, &Options{
+		FailureThreshold: 3,
+		SuccessThreshold: 2,
+		OpenTimeout:      time.Second,
+		OnStateChange:    func(ev Event) { events = append(events, ev) },
+	})
+	b.now = clock.now
+
+	t.Run("opens after consecutive failures", func(t *testing.T) {
+		backend.setDown(true)
+		for i := 0; i < 3; i++ {
+			_, err := b.Get(ctx, "key")
+			assert.ErrorIs(t, err, errBackend)
+		}
+		assert.Equal(t, StateOpen, b.State())
+		assert.Len(t, events, 1)
+		assert.Equal(t, StateClosed, events[0].From)
+		assert.Equal(t, StateOpen, events[0].To)
+		assert.ErrorIs(t, events[0].Err, errBackend)
+	})
+
+	t.Run("rejects calls while open", func(t *testing.T) {
+		calls := backend.calls
+		_, err := b.Get(ctx, "key")
+		assert.ErrorIs(t, err, ErrOpen)
+		assert.Equal(t, calls, backend.calls)
+	})
+
+	t.Run("failed probe reopens", func(t *testing.T) {
+		clock.advance(time.Second)
+		assert.Equal(t, StateHalfOpen, b.State())
+
+		_, err := b.Get(ctx, "key")
+		assert.ErrorIs(t, err, errBackend)
+		assert.Equal(t, StateOpen, b.State())
+	})
+
+	t.Run("successful probes close", func(t *testing.T) {
+		backend.setDown(false)
+		clock.advance(time.Second)
+
+		assert.NoError(t, b.Set(ctx, "key", "value", 0))
+		assert.Equal(t, StateHalfOpen, b.State())
+
+		val, err := b.Get(ctx, "key")
+		assert.NoError(t, err)
+		assert.Equal(t, "value", val)
+		assert.Equal(t, StateClosed, b.State())
+
+		last := events[len(events)-1]
+		assert.Equal(t, StateHalfOpen, last.From)
+		assert.Equal(t, StateClosed, last.To)
+	})
+
+	t.Run("misses are not failures", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			_, err := b.Get(ctx, "missing")
+			assert.ErrorIs(t, err, cache.ErrNotFound)
+		}
+		assert.Equal(t, StateClosed, b.State())
+	})
+}
+
+func TestBreaker_FailOpen(t *testing.T) {
+	ctx := context.Background()
+	backend := newFlaky()
+	backend.setDown(true)
+
+	var errs []error
+	b := New(backend, &Options{
+		FailureThreshold: 1,
+		OpenTimeout:      time.Hour,
+		FailOpen:         true,
+		OnError:          func(err error) { errs = append(errs, err) },
+	})
+
+	// First call fails at the backend, second is rejected by the open breaker
+	for i := 0; i < 2; i++ {
+		val, err := b.Get(ctx, "key")
+		assert.ErrorIs(t, err, cache.ErrNotFound)
+		assert.Nil(t, val)
+	}
+	assert.Equal(t, StateOpen, b.State())
+	assert.Equal(t, 1, backend.calls)
+	assert.Equal(t, []error{errBackend}, errs)
+
+	assert.NoError(t, b.Set(ctx, "key", "value", 0))
+
+	// A failed invalidation must not pass for a successful one
+	assert.ErrorIs(t, b.Delete(ctx, "key"), ErrOpen)
+	assert.ErrorIs(t, b.Clear(ctx), ErrOpen)
+}
+
+func TestBreaker_StaleOutcomesAreIgnored(t *testing.T) {
+	ctx := context.Background()
+	backend := newFlaky()
+	clock := &fakeClock{t: time.Now()}
+
+	b := New(backend, &Options{FailureThreshold: 1, OpenTimeout: time.Second})
+	b.now = clock.now
+
+	// A call admitted while closed is still in flight when the breaker opens
+	generation, err := b.acquire()
+	assert.NoError(t, err)
+
+	backend.setDown(true)
+	_, err = b.Get(ctx, "key")
+	assert.ErrorIs(t, err, errBackend)
+	assert.Equal(t, StateOpen, b.State())
+
+	// The breaker recovers through a successful probe
+	backend.setDown(false)
+	clock.advance(time.Second)
+	_, err = b.Get(ctx, "missing")
+	assert.ErrorIs(t, err, cache.ErrNotFound)
+	assert.Equal(t, StateClosed, b.State())
+
+	// The late failure of the old call must not reopen it
+	b.record(generation, errBackend)
+	assert.Equal(t, StateClosed, b.State())
+}
+
+// statsCache adds a cache.StatsProvider, cache.KeyLister and cache.Inspector
+// to a flakyCache
+type statsCache struct {
+	*flakyCache
+}
+
+func (s statsCache) Stats(ctx context.Context) (cache.Stats, error) {
+	return cache.Stats{Entries: 1}, nil
+}
+
+func (s statsCache) Keys(ctx context.Context, pattern, cursor string, limit int) (cache.KeyPage, error) {
+	return cache.KeyPage{Keys: []string{"key"}}, nil
+}
+
+func (s statsCache) Inspect(ctx context.Context, key string) (cache.EntryInfo, error) {
+	return cache.EntryInfo{Key: key}, nil
+}
+
+func TestBreaker_IntrospectionBypassesBreaker(t *testing.T) {
+	backend := newFlaky()
+	backend.setDown(true)
+
+	b := New(statsCache{backend}, &Options{FailureThreshold: 1, OpenTimeout: time.Hour})
+	_, err := b.Get(context.Background(), "key")
+	assert.ErrorIs(t, err, errBackend)
+	assert.Equal(t, StateOpen, b.State())
+
+	stats, err := b.Stats(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), stats.Entries)
+
+	page, err := b.Keys(context.Background(), "*", "", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"key"}, page.Keys)
+	info, err := b.Inspect(context.Background(), "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "key", info.Key)
+}
+
+func TestBreaker_CallerCancellationIsNotAFailure(t *testing.T) {
+	backend := newFlaky()
+	backend.setDown(true)
+
+	b := New(backend, &Options{FailureThreshold: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := b.Get(ctx, "key")
+	assert.ErrorIs(t, err, errBackend)
+	assert.Equal(t, StateClosed, b.State())
+}
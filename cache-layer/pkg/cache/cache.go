@@ -13,6 +13,9 @@ type Options struct {
 	MaxTTL time.Duration
 	// MaxSize is the maximum number of entries in the cache
 	MaxSize int64
+	// CleanupInterval is how often expired entries are removed by backends
+	// with a janitor; zero uses the backend's default
+	CleanupInterval time.Duration
 }
 
 // Entry represents a single cache entry
@@ -0,0 +1,170 @@
+// Package cachetest provides the contract tests every cache.Cache backend
+// is expected to pass.
+package cachetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+// Suite describes the backend under test
+type Suite struct {
+	// New creates an empty cache with the given options
+	New func(t *testing.T, options *cache.Options) cache.Cache
+	// Sleep lets d pass for the backend; defaults to time.Sleep. Backends
+	// with a fake clock, such as miniredis, advance it instead.
+	Sleep func(d time.Duration)
+	// Bounded tells whether the backend enforces Options.MaxSize
+	Bounded bool
+	// Unit is the TTL resolution of the backend, which the TTL tests are
+	// scaled to; defaults to 10ms. Memcached, for one, counts in seconds.
+	Unit time.Duration
+}
+
+// Run runs the contract tests against the backend
+func Run(t *testing.T, s Suite) {
+	if s.Sleep == nil {
+		s.Sleep = time.Sleep
+	}
+	if s.Unit == 0 {
+		s.Unit = 10 * time.Millisecond
+	}
+	u := s.Unit
+	ctx := context.Background()
+	newCache := func(t *testing.T, options *cache.Options) cache.Cache {
+		c := s.New(t, options)
+		t.Cleanup(func() { _ = c.Close() })
+		return c
+	}
+	long := &cache.Options{DefaultTTL: time.Hour, MaxTTL: time.Hour}
+
+	t.Run("set and get", func(t *testing.T) {
+		c := newCache(t, long)
+		assert.NoError(t, c.Set(ctx, "key", "value", 0))
+		assertHit(t, c, "key", "value")
+
+		assert.NoError(t, c.Set(ctx, "key", "other", 0))
+		assertHit(t, c, "key", "other")
+	})
+
+	t.Run("miss", func(t *testing.T) {
+		c := newCache(t, long)
+		assertMiss(t, c, "missing")
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		c := newCache(t, long)
+		assert.NoError(t, c.Set(ctx, "key", "value", 0))
+		assert.NoError(t, c.Delete(ctx, "key"))
+		assertMiss(t, c, "key")
+
+		assert.NoError(t, c.Delete(ctx, "missing"))
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		c := newCache(t, long)
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, c.Set(ctx, fmt.Sprintf("key%d", i), "value", 0))
+		}
+		assert.NoError(t, c.Clear(ctx))
+		for i := 0; i < 3; i++ {
+			assertMiss(t, c, fmt.Sprintf("key%d", i))
+		}
+
+		// The cache is still usable afterwards
+		assert.NoError(t, c.Set(ctx, "key", "value", 0))
+		assertHit(t, c, "key", "value")
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		c := newCache(t, &cache.Options{DefaultTTL: 5 * u, MaxTTL: time.Hour})
+		assert.NoError(t, c.Set(ctx, "default", "value", 0))
+		assert.NoError(t, c.Set(ctx, "short", "value", 2*u))
+		assert.NoError(t, c.Set(ctx, "long", "value", time.Minute))
+
+		s.Sleep(3 * u)
+		assertMiss(t, c, "short")
+		assertHit(t, c, "default", "value")
+
+		s.Sleep(4 * u)
+		assertMiss(t, c, "default")
+		assertHit(t, c, "long", "value")
+	})
+
+	t.Run("max ttl", func(t *testing.T) {
+		c := newCache(t, &cache.Options{DefaultTTL: time.Hour, MaxTTL: 5 * u})
+		assert.NoError(t, c.Set(ctx, "explicit", "value", time.Hour))
+		assert.NoError(t, c.Set(ctx, "default", "value", 0))
+		assertHit(t, c, "explicit", "value")
+
+		s.Sleep(7 * u)
+		assertMiss(t, c, "explicit")
+		assertMiss(t, c, "default")
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		c := newCache(t, long)
+		assert.NoError(t, c.Set(ctx, "key", "value", 0))
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err := c.Get(cancelled, "key")
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.ErrorIs(t, c.Set(cancelled, "key", "other", 0), context.Canceled)
+		assert.ErrorIs(t, c.Delete(cancelled, "key"), context.Canceled)
+		assert.ErrorIs(t, c.Clear(cancelled), context.Canceled)
+
+		// Nothing was changed
+		assertHit(t, c, "key", "value")
+	})
+
+	if !s.Bounded {
+		return
+	}
+
+	t.Run("max size", func(t *testing.T) {
+		c := newCache(t, &cache.Options{DefaultTTL: time.Hour, MaxTTL: time.Hour, MaxSize: 3})
+		for i := 0; i < 10; i++ {
+			assert.NoError(t, c.Set(ctx, fmt.Sprintf("key%d", i), "value", 0))
+			// The entry just written is never the one evicted
+			assertHit(t, c, fmt.Sprintf("key%d", i), "value")
+		}
+
+		present := 0
+		for i := 0; i < 10; i++ {
+			if v, _ := c.Get(ctx, fmt.Sprintf("key%d", i)); v != nil {
+				present++
+			}
+		}
+		assert.Equal(t, 3, present)
+
+		// Overwriting an entry of a full cache evicts nothing
+		assert.NoError(t, c.Set(ctx, "key9", "other", 0))
+		assertHit(t, c, "key8", "value")
+		assertHit(t, c, "key9", "other")
+	})
+}
+
+func assertHit(t *testing.T, c cache.Cache, key string, want interface{}) {
+	t.Helper()
+	v, err := c.Get(context.Background(), key)
+	assert.NoError(t, err, key)
+	assert.Equal(t, want, v, key)
+}
+
+// assertMiss accepts both ways backends report misses: a nil value with
+// either cache.ErrNotFound or no error
+func assertMiss(t *testing.T, c cache.Cache, key string) {
+	t.Helper()
+	v, err := c.Get(context.Background(), key)
+	if err != nil && !errors.Is(err, cache.ErrNotFound) {
+		t.Errorf("Get(%q): unexpected error %v", key, err)
+	}
+	assert.Nil(t, v, key)
+}
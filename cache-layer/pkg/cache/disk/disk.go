@@ -0,0 +1,549 @@
+// Package disk implements a persistent cache.Cache on bbolt, for large,
+// rarely changing values that should survive restarts and outgrow memory.
+package disk
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrClosed is returned by the operations of a closed cache
+var ErrClosed = errors.New("disk: cache closed")
+
+var (
+	entriesBucket = []byte("entries") // key -> header + value
+	expiryBucket  = []byte("expiry")  // expiration + key -> nothing
+	orderBucket   = []byte("order")   // sequence -> key, oldest first
+	metaBucket    = []byte("meta")
+
+	countKey = []byte("count")
+	bytesKey = []byte("bytes")
+)
+
+const (
+	// headerSize is the size of the entry header: expiration (unix nanos,
+	// 0 for none), insertion sequence and value kind
+	headerSize = 17
+
+	kindJSON byte = 0
+	kindRaw  byte = 1
+
+	// minCompactSize is the file size below which compaction isn't worth it
+	minCompactSize = 1 << 20
+)
+
+// Options represents the configuration options for a disk cache
+type Options struct {
+	// Options holds the TTLs, the maximum number of entries (0 means
+	// unlimited) and the interval of the expiry sweep (defaults to 1 minute)
+	cache.Options
+	// MaxBytes is the maximum total size of keys and values (0 means
+	// unlimited). The file is somewhat larger because of bbolt's page
+	// overhead, and keeps the space of removed entries until compaction.
+	MaxBytes int64
+	// CompactRatio is the fraction of the file that must be free space for
+	// a sweep to compact it (defaults to 0.5, negative disables compaction)
+	CompactRatio float64
+}
+
+// Cache is a cache.Cache stored in a single bbolt file
+type Cache struct {
+	path    string
+	options Options
+
+	// mu guards db, which Compact replaces
+	mu sync.RWMutex
+	db *bolt.DB
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+
+	hits, misses, sets, deletes, evictions atomic.Uint64
+	now                                    func() time.Time
+}
+
+// New opens or creates the cache stored in the file at path
+func New(path string, options *Options) (*Cache, error) {
+	if options == nil {
+		options = &Options{Options: cache.Options{DefaultTTL: time.Hour}}
+	}
+	c := &Cache{
+		path:    path,
+		options: *options,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		now:     time.Now,
+	}
+	if c.options.CleanupInterval <= 0 {
+		c.options.CleanupInterval = time.Minute
+	}
+	if c.options.CompactRatio == 0 {
+		c.options.CompactRatio = 0.5
+	}
+
+	db, err := openDB(path)
+	if err != nil {
+		return nil, err
+	}
+	c.db = db
+
+	go c.sweeper()
+	return c, nil
+}
+
+func openDB(path string) (*bolt.DB, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{entriesBucket, expiryBucket, orderBucket, metaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func (c *Cache) Get(ctx context.Context, key string) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.db == nil {
+		return nil, ErrClosed
+	}
+
+	var kind byte
+	var data []byte
+	err := c.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(entriesBucket).Get([]byte(key))
+		if v == nil {
+			return cache.ErrNotFound
+		}
+		// Expired entries stay until the next sweep
+		if expires := int64(binary.BigEndian.Uint64(v)); expires != 0 && expires <= c.now().UnixNano() {
+			return cache.ErrNotFound
+		}
+		kind = v[16]
+		data = bytes.Clone(v[headerSize:])
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, cache.ErrNotFound) {
+			c.misses.Add(1)
+		}
+		return nil, err
+	}
+
+	c.hits.Add(1)
+	if kind == kindRaw {
+		return data, nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (c *Cache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// []byte values, such as the GORM plugin's, are stored as they are
+	kind, data := kindRaw, []byte(nil)
+	if raw, ok := value.([]byte); ok {
+		data = raw
+	} else {
+		var err error
+		kind = kindJSON
+		if data, err = json.Marshal(value); err != nil {
+			return err
+		}
+	}
+
+	if ttl == 0 {
+		ttl = c.options.DefaultTTL
+	}
+	if c.options.MaxTTL > 0 && (ttl > c.options.MaxTTL || ttl <= 0) {
+		ttl = c.options.MaxTTL
+	}
+	var expires int64
+	if ttl > 0 {
+		expires = c.now().Add(ttl).UnixNano()
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.db == nil {
+		return ErrClosed
+	}
+
+	// An entry that can never fit is not cached at all, and the value it
+	// replaces is dropped as well
+	size := int64(len(key) + headerSize + len(data))
+	if c.options.MaxBytes > 0 && size > c.options.MaxBytes {
+		return c.db.Update(func(tx *bolt.Tx) error {
+			_, err := removeEntry(tx, []byte(key))
+			return err
+		})
+	}
+
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		entries, order := tx.Bucket(entriesBucket), tx.Bucket(orderBucket)
+		count, total := readMeta(tx)
+
+		k := []byte(key)
+		if old := entries.Get(k); old != nil {
+			if err := removeIndexes(tx, k, old); err != nil {
+				return err
+			}
+			count--
+			total -= int64(len(k) + len(old))
+		}
+
+		// Make room, oldest first
+		cur := order.Cursor()
+		for victim, k := cur.First(); victim != nil; victim, k = cur.First() {
+			fits := (c.options.MaxSize <= 0 || count < c.options.MaxSize) &&
+				(c.options.MaxBytes <= 0 || total+size <= c.options.MaxBytes)
+			if fits {
+				break
+			}
+			// Keys returned by bbolt are only valid until the next write
+			victimKey := bytes.Clone(k)
+			old := entries.Get(victimKey)
+			if err := removeIndexes(tx, victimKey, old); err != nil {
+				return err
+			}
+			if err := entries.Delete(victimKey); err != nil {
+				return err
+			}
+			count--
+			total -= int64(len(victimKey) + len(old))
+			c.evictions.Add(1)
+		}
+
+		seq, err := order.NextSequence()
+		if err != nil {
+			return err
+		}
+		v := make([]byte, headerSize, headerSize+len(data))
+		binary.BigEndian.PutUint64(v, uint64(expires))
+		binary.BigEndian.PutUint64(v[8:], seq)
+		v[16] = kind
+		v = append(v, data...)
+
+		if err := entries.Put(k, v); err != nil {
+			return err
+		}
+		if err := order.Put(u64(seq), k); err != nil {
+			return err
+		}
+		if expires != 0 {
+			if err := tx.Bucket(expiryBucket).Put(expiryKey(expires, k), nil); err != nil {
+				return err
+			}
+		}
+		return writeMeta(tx, count+1, total+size)
+	})
+	if err != nil {
+		return err
+	}
+	c.sets.Add(1)
+	return nil
+}
+
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.db == nil {
+		return ErrClosed
+	}
+
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		_, err := removeEntry(tx, []byte(key))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	c.deletes.Add(1)
+	return nil
+}
+
+func (c *Cache) Clear(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.db == nil {
+		return ErrClosed
+	}
+
+	// The freed pages are reclaimed by the next compaction
+	return c.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{entriesBucket, expiryBucket, orderBucket, metaBucket} {
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+			if _, err := tx.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Close stops the sweep and closes the file
+func (c *Cache) Close() error {
+	c.once.Do(func() { close(c.stop) })
+	<-c.done
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.db == nil {
+		return nil
+	}
+	err := c.db.Close()
+	c.db = nil
+	return err
+}
+
+// Stats implements cache.StatsProvider
+func (c *Cache) Stats(ctx context.Context) (cache.Stats, error) {
+	if err := ctx.Err(); err != nil {
+		return cache.Stats{}, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.db == nil {
+		return cache.Stats{}, ErrClosed
+	}
+
+	var count int64
+	err := c.db.View(func(tx *bolt.Tx) error {
+		count, _ = readMeta(tx)
+		return nil
+	})
+	return cache.Stats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Sets:      c.sets.Load(),
+		Deletes:   c.deletes.Load(),
+		Evictions: c.evictions.Load(),
+		Entries:   count,
+	}, err
+}
+
+// sweeper removes expired entries every CleanupInterval, and compacts the
+// file when enough of it is free
+func (c *Cache) sweeper() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.options.CleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			// Errors are retried on the next tick
+			_, _ = c.sweep()
+			if c.shouldCompact() {
+				_ = c.Compact()
+			}
+		}
+	}
+}
+
+// sweepBatch is the number of expired entries removed per transaction, so
+// that writers don't wait on one long sweep
+const sweepBatch = 1000
+
+// sweep removes the expired entries and returns how many there were
+func (c *Cache) sweep() (int, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.db == nil {
+		return 0, ErrClosed
+	}
+
+	now := uint64(c.now().UnixNano())
+	removed := 0
+	for {
+		n := 0
+		err := c.db.Update(func(tx *bolt.Tx) error {
+			cur := tx.Bucket(expiryBucket).Cursor()
+			for k, _ := cur.First(); k != nil && n < sweepBatch; k, _ = cur.First() {
+				if binary.BigEndian.Uint64(k) > now {
+					break
+				}
+				// removeEntry also drops k from the expiry index
+				ok, err := removeEntry(tx, bytes.Clone(k[8:]))
+				if err != nil {
+					return err
+				}
+				if !ok {
+					if err := cur.Delete(); err != nil {
+						return err
+					}
+				}
+				n++
+			}
+			return nil
+		})
+		removed += n
+		c.evictions.Add(uint64(n))
+		if err != nil || n < sweepBatch {
+			return removed, err
+		}
+	}
+}
+
+func (c *Cache) shouldCompact() bool {
+	if c.options.CompactRatio < 0 {
+		return false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.db == nil {
+		return false
+	}
+
+	var size int64
+	_ = c.db.View(func(tx *bolt.Tx) error {
+		size = tx.Size()
+		return nil
+	})
+	free := int64(c.db.Stats().FreeAlloc)
+	return size >= minCompactSize && float64(free) >= c.options.CompactRatio*float64(size)
+}
+
+// Compact rewrites the file without the space of removed entries. Calls wait
+// while it runs.
+func (c *Cache) Compact() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.db == nil {
+		return ErrClosed
+	}
+
+	tmp := c.path + ".compact"
+	_ = os.Remove(tmp)
+	dst, err := bolt.Open(tmp, 0o600, nil)
+	if err != nil {
+		return err
+	}
+	if err := bolt.Compact(dst, c.db, 64<<20); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := c.db.Close(); err != nil {
+		return err
+	}
+	c.db = nil
+	if err := os.Rename(tmp, c.path); err != nil {
+		return errors.Join(err, c.reopen())
+	}
+	return c.reopen()
+}
+
+// reopen opens the file again after compaction; callers must hold c.mu
+func (c *Cache) reopen() error {
+	db, err := openDB(c.path)
+	if err != nil {
+		return err
+	}
+	c.db = db
+	return nil
+}
+
+// removeEntry deletes key and its index records, reporting whether it existed
+func removeEntry(tx *bolt.Tx, key []byte) (bool, error) {
+	entries := tx.Bucket(entriesBucket)
+	old := entries.Get(key)
+	if old == nil {
+		return false, nil
+	}
+	if err := removeIndexes(tx, key, old); err != nil {
+		return false, err
+	}
+	size := int64(len(key) + len(old))
+	if err := entries.Delete(key); err != nil {
+		return false, err
+	}
+
+	count, total := readMeta(tx)
+	return true, writeMeta(tx, count-1, total-size)
+}
+
+// removeIndexes deletes the index records of the entry v of key
+func removeIndexes(tx *bolt.Tx, key, v []byte) error {
+	if expires := int64(binary.BigEndian.Uint64(v)); expires != 0 {
+		if err := tx.Bucket(expiryBucket).Delete(expiryKey(expires, key)); err != nil {
+			return err
+		}
+	}
+	return tx.Bucket(orderBucket).Delete(v[8:16])
+}
+
+func readMeta(tx *bolt.Tx) (count, total int64) {
+	meta := tx.Bucket(metaBucket)
+	if v := meta.Get(countKey); v != nil {
+		count = int64(binary.BigEndian.Uint64(v))
+	}
+	if v := meta.Get(bytesKey); v != nil {
+		total = int64(binary.BigEndian.Uint64(v))
+	}
+	return count, total
+}
+
+func writeMeta(tx *bolt.Tx, count, total int64) error {
+	meta := tx.Bucket(metaBucket)
+	if err := meta.Put(countKey, u64(uint64(count))); err != nil {
+		return err
+	}
+	return meta.Put(bytesKey, u64(uint64(total)))
+}
+
+func expiryKey(expires int64, key []byte) []byte {
+	return append(u64(uint64(expires)), key...)
+}
+
+func u64(n uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, n)
+	return b
+}
@@ -0,0 +1,218 @@
+package disk
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/cachetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCache(t *testing.T, options *Options) *Cache {
+	c, err := New(filepath.Join(t.TempDir(), "cache.db"), options)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func TestDiskCache_Contract(t *testing.T) {
+	cachetest.Run(t, cachetest.Suite{
+		New: func(t *testing.T, options *cache.Options) cache.Cache {
+			return newTestCache(t, &Options{Options: *options})
+		},
+		Bounded: true,
+	})
+}
+
+func TestDiskCache_Values(t *testing.T) {
+	c := newTestCache(t, nil)
+	ctx := context.Background()
+
+	payload := []byte(`{"Dest":{"Name":"admin"},"RowsAffected":1}`)
+	assert.NoError(t, c.Set(ctx, "raw", payload, 0))
+	assert.NoError(t, c.Set(ctx, "json", map[string]interface{}{"n": float64(1)}, 0))
+
+	val, err := c.Get(ctx, "raw")
+	assert.NoError(t, err)
+	assert.Equal(t, payload, val)
+
+	val, err = c.Get(ctx, "json")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"n": float64(1)}, val)
+
+	_, err = c.Get(ctx, "missing")
+	assert.ErrorIs(t, err, cache.ErrNotFound)
+}
+
+func TestDiskCache_Persistence(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache.db")
+
+	c, err := New(path, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Set(ctx, "key", []byte("value"), 0))
+	assert.NoError(t, c.Close())
+
+	_, err = c.Get(ctx, "key")
+	assert.ErrorIs(t, err, ErrClosed)
+
+	c, err = New(path, nil)
+	assert.NoError(t, err)
+	defer c.Close()
+
+	val, err := c.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("value"), val)
+
+	stats, err := c.Stats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), stats.Entries)
+}
+
+func TestDiskCache_Sweep(t *testing.T) {
+	c := newTestCache(t, nil)
+	ctx := context.Background()
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	for i := 0; i < 2500; i++ {
+		assert.NoError(t, c.Set(ctx, fmt.Sprintf("short%d", i), "value", time.Minute))
+	}
+	assert.NoError(t, c.Set(ctx, "long", "value", 2*time.Hour))
+	assert.NoError(t, c.Set(ctx, "forever", "value", -1))
+
+	now = now.Add(time.Hour)
+	removed, err := c.sweep()
+	assert.NoError(t, err)
+	assert.Equal(t, 2500, removed)
+
+	stats, err := c.Stats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), stats.Entries)
+	assert.Equal(t, uint64(2500), stats.Evictions)
+
+	_, err = c.Get(ctx, "long")
+	assert.NoError(t, err)
+	_, err = c.Get(ctx, "forever")
+	assert.NoError(t, err)
+
+	t.Run("background", func(t *testing.T) {
+		c := newTestCache(t, &Options{Options: cache.Options{CleanupInterval: 10 * time.Millisecond}})
+		assert.NoError(t, c.Set(ctx, "key", "value", 20*time.Millisecond))
+
+		assert.Eventually(t, func() bool {
+			stats, err := c.Stats(ctx)
+			return err == nil && stats.Entries == 0
+		}, time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestDiskCache_MaxBytes(t *testing.T) {
+	// Each entry takes 4 bytes of key, 17 of header and 100 of value
+	c := newTestCache(t, &Options{MaxBytes: 3 * 121})
+	ctx := context.Background()
+	value := []byte(strings.Repeat("x", 100))
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, c.Set(ctx, fmt.Sprintf("key%d", i), value, 0))
+	}
+	for i := 0; i < 2; i++ {
+		_, err := c.Get(ctx, fmt.Sprintf("key%d", i))
+		assert.ErrorIs(t, err, cache.ErrNotFound)
+	}
+	for i := 2; i < 5; i++ {
+		_, err := c.Get(ctx, fmt.Sprintf("key%d", i))
+		assert.NoError(t, err)
+	}
+
+	// Values that can never fit are not stored, and evict nothing
+	assert.NoError(t, c.Set(ctx, "big", []byte(strings.Repeat("x", 1000)), 0))
+	_, err := c.Get(ctx, "big")
+	assert.ErrorIs(t, err, cache.ErrNotFound)
+
+	stats, err := c.Stats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), stats.Entries)
+	assert.Equal(t, uint64(2), stats.Evictions)
+
+	// Overwriting with such a value drops the old one
+	assert.NoError(t, c.Set(ctx, "key4", []byte(strings.Repeat("x", 1000)), 0))
+	_, err = c.Get(ctx, "key4")
+	assert.ErrorIs(t, err, cache.ErrNotFound)
+
+	stats, err = c.Stats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), stats.Entries)
+}
+
+func TestDiskCache_Compact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+	c, err := New(path, nil)
+	assert.NoError(t, err)
+	defer c.Close()
+	ctx := context.Background()
+
+	value := []byte(strings.Repeat("x", 4096))
+	for i := 0; i < 1000; i++ {
+		assert.NoError(t, c.Set(ctx, fmt.Sprintf("key%d", i), value, 0))
+	}
+	for i := 1; i < 1000; i++ {
+		assert.NoError(t, c.Delete(ctx, fmt.Sprintf("key%d", i)))
+	}
+	assert.True(t, c.shouldCompact())
+
+	before, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Compact())
+	after, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Less(t, after.Size(), before.Size()/10)
+	assert.False(t, c.shouldCompact())
+
+	// The cache keeps working on the new file
+	val, err := c.Get(ctx, "key0")
+	assert.NoError(t, err)
+	assert.Equal(t, value, val)
+	assert.NoError(t, c.Set(ctx, "key1", value, 0))
+}
+
+func TestDiskCache_Introspection(t *testing.T) {
+	c := newTestCache(t, nil)
+	ctx := context.Background()
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, c.Set(ctx, fmt.Sprintf("users:%d", i), []byte("payload"), 0))
+	}
+	assert.NoError(t, c.Set(ctx, "roles:0", map[string]interface{}{"name": "admin"}, time.Minute))
+	assert.NoError(t, c.Set(ctx, "users:expired", "value", time.Second))
+	now = now.Add(2 * time.Second)
+
+	page, err := c.Keys(ctx, "users:*", "", 3)
+	assert.NoError(t, err)
+	assert.Equal(t, cache.KeyPage{Keys: []string{"users:0", "users:1", "users:2"}, Cursor: "users:2"}, page)
+	page, err = c.Keys(ctx, "users:*", page.Cursor, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, cache.KeyPage{Keys: []string{"users:3", "users:4"}}, page)
+
+	info, err := c.Inspect(ctx, "roles:0")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"name": "admin"}, info.Value)
+	assert.Equal(t, int64(len(`{"name":"admin"}`)), info.Size)
+	assert.Equal(t, now.Add(58*time.Second).UnixNano(), info.ExpiresAt.UnixNano())
+
+	info, err = c.Inspect(ctx, "users:0")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("payload"), info.Value)
+	assert.Equal(t, time.Hour, info.ExpiresAt.Sub(now)+2*time.Second)
+
+	_, err = c.Inspect(ctx, "users:expired")
+	assert.ErrorIs(t, err, cache.ErrNotFound)
+}
@@ -0,0 +1,97 @@
+package disk
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Keys implements cache.KeyLister; the cursor is the last key returned
+func (c *Cache) Keys(ctx context.Context, pattern, cursor string, limit int) (cache.KeyPage, error) {
+	if err := ctx.Err(); err != nil {
+		return cache.KeyPage{}, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.db == nil {
+		return cache.KeyPage{}, ErrClosed
+	}
+
+	page := cache.KeyPage{Keys: []string{}}
+	now := c.now().UnixNano()
+	err := c.db.View(func(tx *bolt.Tx) error {
+		cur := tx.Bucket(entriesBucket).Cursor()
+		k, v := cur.First()
+		if cursor != "" {
+			k, v = cur.Seek([]byte(cursor))
+			if k != nil && string(k) == cursor {
+				k, v = cur.Next()
+			}
+		}
+		for ; k != nil; k, v = cur.Next() {
+			if expires := int64(binary.BigEndian.Uint64(v)); expires != 0 && expires <= now {
+				continue
+			}
+			if !cache.Match(pattern, string(k)) {
+				continue
+			}
+			if limit > 0 && len(page.Keys) == limit {
+				page.Cursor = page.Keys[len(page.Keys)-1]
+				break
+			}
+			page.Keys = append(page.Keys, string(k))
+		}
+		return nil
+	})
+	return page, err
+}
+
+// Inspect implements cache.Inspector
+func (c *Cache) Inspect(ctx context.Context, key string) (cache.EntryInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return cache.EntryInfo{}, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.db == nil {
+		return cache.EntryInfo{}, ErrClosed
+	}
+
+	var expires int64
+	var kind byte
+	var data []byte
+	err := c.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(entriesBucket).Get([]byte(key))
+		if v == nil {
+			return cache.ErrNotFound
+		}
+		expires = int64(binary.BigEndian.Uint64(v))
+		if expires != 0 && expires <= c.now().UnixNano() {
+			return cache.ErrNotFound
+		}
+		kind = v[16]
+		data = bytes.Clone(v[headerSize:])
+		return nil
+	})
+	if err != nil {
+		return cache.EntryInfo{}, err
+	}
+
+	info := cache.EntryInfo{Key: key, Value: data, Size: int64(len(data))}
+	if expires != 0 {
+		info.ExpiresAt = time.Unix(0, expires)
+	}
+	if kind == kindJSON {
+		var value interface{}
+		if err := json.Unmarshal(data, &value); err != nil {
+			return cache.EntryInfo{}, err
+		}
+		info.Value = value
+	}
+	return info, nil
+}
@@ -4,5 +4,6 @@ import "errors"
 
 // Common errors
 var (
-	ErrNotFound = errors.New("cache: key not found")
+	ErrNotFound     = errors.New("cache: key not found")
+	ErrNotSupported = errors.New("cache: operation not supported")
 )
@@ -0,0 +1,40 @@
+package cache
+
+// EvictionReason tells why an entry left the cache
+type EvictionReason int
+
+const (
+	// EvictCapacity means the entry was evicted to make room for another
+	EvictCapacity EvictionReason = iota
+	// EvictExpired means the entry outlived its TTL
+	EvictExpired
+	// EvictDeleted means the entry was removed with Delete
+	EvictDeleted
+	// EvictCleared means the entry was removed with Clear
+	EvictCleared
+)
+
+func (r EvictionReason) String() string {
+	switch r {
+	case EvictCapacity:
+		return "capacity"
+	case EvictExpired:
+		return "expired"
+	case EvictDeleted:
+		return "deleted"
+	case EvictCleared:
+		return "cleared"
+	default:
+		return "unknown"
+	}
+}
+
+// EvictFunc is called for every entry that leaves a cache
+type EvictFunc func(key string, value interface{}, reason EvictionReason)
+
+// EvictNotifier is implemented by caches that report evictions. Callbacks
+// run asynchronously, in the order the evictions happened, and never while
+// the cache holds a lock.
+type EvictNotifier interface {
+	OnEvict(fn EvictFunc)
+}
@@ -0,0 +1,102 @@
+package gorm
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CacheTTLer can be implemented by a model to set the default TTL of cached
+// queries returning it, e.g. to keep reference tables cached longer
+type CacheTTLer interface {
+	CacheTTL() time.Duration
+}
+
+type noCacheKey struct{}
+type ttlKey struct{}
+type cacheKeyKey struct{}
+
+// NoCache returns a context whose queries bypass the cache entirely
+func NoCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheKey{}, true)
+}
+
+// WithTTL returns a context whose query results are cached for ttl
+func WithTTL(ctx context.Context, ttl time.Duration) context.Context {
+	return context.WithValue(ctx, ttlKey{}, ttl)
+}
+
+// WithKey returns a context whose query results are cached under key
+// instead of the key derived from the SQL statement
+func WithKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, cacheKeyKey{}, key)
+}
+
+// SkipCache is the scope equivalent of NoCache: db.Scopes(SkipCache())
+func SkipCache() func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db.Statement.Context = NoCache(db.Statement.Context)
+		return db
+	}
+}
+
+// CacheTTL is the scope equivalent of WithTTL: db.Scopes(CacheTTL(time.Minute))
+func CacheTTL(ttl time.Duration) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db.Statement.Context = WithTTL(db.Statement.Context, ttl)
+		return db
+	}
+}
+
+// CacheKey is the scope equivalent of WithKey: db.Scopes(CacheKey("roles:all"))
+func CacheKey(key string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db.Statement.Context = WithKey(db.Statement.Context, key)
+		return db
+	}
+}
+
+// isNoCache reports whether caching is disabled for ctx
+func isNoCache(ctx context.Context) bool {
+	skip, _ := ctx.Value(noCacheKey{}).(bool)
+	return skip
+}
+
+// keyFor returns the custom cache key from ctx, or the default key
+func keyFor(ctx context.Context, key string) string {
+	if custom, ok := ctx.Value(cacheKeyKey{}).(string); ok && custom != "" {
+		return custom
+	}
+	return key
+}
+
+// ttlFor returns the TTL for caching dest: the ctx override first, then the
+// model default, and 0 (the cache default) otherwise
+func ttlFor(ctx context.Context, dest interface{}) time.Duration {
+	if ttl, ok := ctx.Value(ttlKey{}).(time.Duration); ok {
+		return ttl
+	}
+	if model, ok := modelOf(dest).(CacheTTLer); ok {
+		return model.CacheTTL()
+	}
+	return 0
+}
+
+// modelOf returns a zero value of the model type behind dest, which may be a
+// (pointer to a) model, or a (pointer to a) slice or array of models
+func modelOf(dest interface{}) interface{} {
+	if dest == nil {
+		return nil
+	}
+
+	t := reflect.TypeOf(dest)
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	return reflect.New(t).Interface()
+}
@@ -0,0 +1,122 @@
+package gorm
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/memory"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// ReferenceModel is a model with its own default cache TTL
+type ReferenceModel struct {
+	ID   uint `gorm:"primarykey"`
+	Name string
+}
+
+func (ReferenceModel) CacheTTL() time.Duration { return time.Hour }
+
+// recordingCache remembers the key and TTL of every Set
+type recordingCache struct {
+	cache.Cache
+	mu   sync.Mutex
+	sets map[string]time.Duration
+}
+
+func (r *recordingCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	r.mu.Lock()
+	r.sets[key] = ttl
+	r.mu.Unlock()
+	return r.Cache.Set(ctx, key, value, ttl)
+}
+
+func (r *recordingCache) reset() {
+	r.mu.Lock()
+	r.sets = make(map[string]time.Duration)
+	r.mu.Unlock()
+}
+
+func setupControlDB(t *testing.T) (*gorm.DB, *recordingCache) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&TestModel{}, &ReferenceModel{}))
+	assert.NoError(t, db.Create(&TestModel{Name: "volatile"}).Error)
+	assert.NoError(t, db.Create(&ReferenceModel{Name: "reference"}).Error)
+
+	rec := &recordingCache{
+		Cache: memory.NewGoCacheWrapper(&cache.Options{DefaultTTL: time.Minute}),
+		sets:  make(map[string]time.Duration),
+	}
+	assert.NoError(t, WithGormCache(db, rec))
+	return db, rec
+}
+
+func TestPerQueryCacheControl(t *testing.T) {
+	db, rec := setupControlDB(t)
+	ctx := context.Background()
+
+	t.Run("default TTL", func(t *testing.T) {
+		rec.reset()
+		var models []TestModel
+		assert.NoError(t, db.WithContext(ctx).Find(&models).Error)
+		assert.Len(t, rec.sets, 1)
+		for _, ttl := range rec.sets {
+			assert.Equal(t, time.Duration(0), ttl)
+		}
+	})
+
+	t.Run("NoCache skips get and store", func(t *testing.T) {
+		rec.reset()
+		var models []TestModel
+		assert.NoError(t, db.WithContext(NoCache(ctx)).Where("name = ?", "volatile").Find(&models).Error)
+		assert.Len(t, models, 1)
+		assert.Empty(t, rec.sets)
+	})
+
+	t.Run("SkipCache scope", func(t *testing.T) {
+		rec.reset()
+		var models []TestModel
+		assert.NoError(t, db.Scopes(SkipCache()).Where("id > ?", 0).Find(&models).Error)
+		assert.Len(t, models, 1)
+		assert.Empty(t, rec.sets)
+	})
+
+	t.Run("WithTTL overrides TTL", func(t *testing.T) {
+		rec.reset()
+		var model TestModel
+		assert.NoError(t, db.WithContext(WithTTL(ctx, 42*time.Second)).First(&model).Error)
+		assert.Len(t, rec.sets, 1)
+		for _, ttl := range rec.sets {
+			assert.Equal(t, 42*time.Second, ttl)
+		}
+	})
+
+	t.Run("model default TTL", func(t *testing.T) {
+		rec.reset()
+		var models []ReferenceModel
+		assert.NoError(t, db.WithContext(ctx).Find(&models).Error)
+		assert.Len(t, rec.sets, 1)
+		for _, ttl := range rec.sets {
+			assert.Equal(t, time.Hour, ttl)
+		}
+	})
+
+	t.Run("WithKey stores under custom key", func(t *testing.T) {
+		rec.reset()
+		var models []ReferenceModel
+		assert.NoError(t, db.Scopes(CacheKey("reference:all")).Where("id > ?", 0).Find(&models).Error)
+		// The custom key is still prefixed with the schema of the model
+		assert.Contains(t, rec.sets, schemaPrefix(t, db, &ReferenceModel{})+"reference:all")
+
+		// A different statement with the same key is served from the cache
+		var cached []ReferenceModel
+		assert.NoError(t, db.WithContext(WithKey(ctx, "reference:all")).Where("id < ?", 0).Find(&cached).Error)
+		assert.Len(t, cached, 1)
+		assert.Equal(t, "reference", cached[0].Name)
+	})
+}
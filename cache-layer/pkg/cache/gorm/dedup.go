@@ -0,0 +1,251 @@
+package gorm
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+)
+
+// DedupStats holds the counters of a Deduplicator
+type DedupStats struct {
+	// Executed is the number of queries that reached the database
+	Executed uint64
+	// Coalesced is the number of queries served by another in-flight query
+	Coalesced uint64
+}
+
+// inflight is a query currently being executed on behalf of several callers
+type inflight struct {
+	done      chan struct{}
+	followers int
+
+	// Set by the leader before done is closed
+	result       reflect.Value
+	rowsAffected int64
+	err          error
+	// abandoned is set when the leader's outcome must not be shared
+	abandoned bool
+}
+
+// Deduplicator is a GORM plugin that coalesces identical concurrent queries.
+// Queries are identified by their normalized SQL, arguments and destination
+// type; the first caller runs the query and every other caller receives a
+// deep copy of its result.
+type Deduplicator struct {
+	mu    sync.Mutex
+	calls map[string]*inflight
+
+	executed  atomic.Uint64
+	coalesced atomic.Uint64
+}
+
+// NewDeduplicator creates a new query deduplicator
+func NewDeduplicator() *Deduplicator {
+	return &Deduplicator{
+		calls: make(map[string]*inflight),
+	}
+}
+
+// Name implements gorm.Plugin
+func (d *Deduplicator) Name() string {
+	return "cache-layer:dedup"
+}
+
+// Initialize implements gorm.Plugin by wrapping the "gorm:query" callback
+func (d *Deduplicator) Initialize(db *gorm.DB) error {
+	query := db.Callback().Query().Get("gorm:query")
+	return db.Callback().Query().Replace("gorm:query", d.wrap(query))
+}
+
+// Stats returns a snapshot of the deduplicator counters
+func (d *Deduplicator) Stats() DedupStats {
+	return DedupStats{
+		Executed:  d.executed.Load(),
+		Coalesced: d.coalesced.Load(),
+	}
+}
+
+func (d *Deduplicator) wrap(next func(*gorm.DB)) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Error != nil || db.DryRun {
+			next(db)
+			return
+		}
+
+		dest := reflect.ValueOf(db.Statement.Dest)
+		if dest.Kind() != reflect.Ptr || dest.IsNil() {
+			next(db)
+			return
+		}
+
+		callbacks.BuildQuerySQL(db)
+		if db.Error != nil {
+			return
+		}
+		key := queryKey(db)
+
+		for {
+			d.mu.Lock()
+			call, ok := d.calls[key]
+			if !ok {
+				call = &inflight{done: make(chan struct{})}
+				d.calls[key] = call
+				d.mu.Unlock()
+				d.executed.Add(1)
+				d.lead(db, key, call, dest, next)
+				return
+			}
+			call.followers++
+			d.mu.Unlock()
+			d.coalesced.Add(1)
+
+			// A follower gives up waiting when its own context is done,
+			// while the leader keeps running for the others
+			select {
+			case <-call.done:
+			case <-db.Statement.Context.Done():
+				_ = db.AddError(db.Statement.Context.Err())
+				return
+			}
+			if call.abandoned {
+				// The leader's result says nothing about this caller's query
+				continue
+			}
+			if call.err != nil {
+				_ = db.AddError(call.err)
+				return
+			}
+			deepCopy(dest.Elem(), call.result.Elem())
+			db.Statement.RowsAffected = call.rowsAffected
+			return
+		}
+	}
+}
+
+// lead runs the query on behalf of every follower of call. The call is
+// released even if next panics, so that followers never wait forever.
+func (d *Deduplicator) lead(db *gorm.DB, key string, call *inflight, dest reflect.Value, next func(*gorm.DB)) {
+	completed := false
+	defer func() {
+		d.mu.Lock()
+		delete(d.calls, key)
+		followers := call.followers
+		d.mu.Unlock()
+
+		// The leader's destination belongs to its caller once we return,
+		// so followers copy from a private snapshot instead
+		if followers > 0 {
+			// Followers retry on their own when the leader panicked or its
+			// caller's context ended the query
+			call.abandoned = !completed || (db.Error != nil && db.Statement.Context.Err() != nil)
+			call.err = db.Error
+			call.rowsAffected = db.Statement.RowsAffected
+			if !call.abandoned && call.err == nil {
+				call.result = reflect.New(dest.Elem().Type())
+				deepCopy(call.result.Elem(), dest.Elem())
+			}
+		}
+		close(call.done)
+	}()
+
+	next(db)
+	completed = true
+}
+
+// queryKey identifies a query by its normalized SQL, arguments and destination type
+func queryKey(db *gorm.DB) string {
+	var b strings.Builder
+	b.WriteString(strings.Join(strings.Fields(db.Statement.SQL.String()), " "))
+	for _, v := range db.Statement.Vars {
+		v = argValue(v)
+		fmt.Fprintf(&b, "|%T:%v", v, v)
+	}
+	fmt.Fprintf(&b, "|%s", reflect.TypeOf(db.Statement.Dest))
+	return b.String()
+}
+
+// argValue resolves a query argument to the value sent to the database, so
+// that pointer arguments are keyed by what they point to rather than by address
+func argValue(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	for {
+		if !rv.IsValid() || (rv.Kind() == reflect.Ptr && rv.IsNil()) {
+			return nil
+		}
+		if valuer, ok := rv.Interface().(driver.Valuer); ok {
+			if value, err := valuer.Value(); err == nil {
+				return value
+			}
+		}
+		if rv.Kind() != reflect.Ptr {
+			return rv.Interface()
+		}
+		rv = rv.Elem()
+	}
+}
+
+// deepCopy copies src into dst without sharing pointers, slices or maps
+func deepCopy(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			dst.Set(reflect.Zero(src.Type()))
+			return
+		}
+		v := reflect.New(src.Type().Elem())
+		deepCopy(v.Elem(), src.Elem())
+		dst.Set(v)
+	case reflect.Slice:
+		if src.IsNil() {
+			dst.Set(reflect.Zero(src.Type()))
+			return
+		}
+		v := reflect.MakeSlice(src.Type(), src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			deepCopy(v.Index(i), src.Index(i))
+		}
+		dst.Set(v)
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			deepCopy(dst.Index(i), src.Index(i))
+		}
+	case reflect.Map:
+		if src.IsNil() {
+			dst.Set(reflect.Zero(src.Type()))
+			return
+		}
+		v := reflect.MakeMapWithSize(src.Type(), src.Len())
+		iter := src.MapRange()
+		for iter.Next() {
+			elem := reflect.New(src.Type().Elem()).Elem()
+			deepCopy(elem, iter.Value())
+			v.SetMapIndex(iter.Key(), elem)
+		}
+		dst.Set(v)
+	case reflect.Interface:
+		if src.IsNil() {
+			dst.Set(reflect.Zero(src.Type()))
+			return
+		}
+		elem := reflect.New(src.Elem().Type()).Elem()
+		deepCopy(elem, src.Elem())
+		dst.Set(elem)
+	case reflect.Struct:
+		// Copy everything (including unexported fields such as those of
+		// time.Time), then replace exported references with copies
+		dst.Set(src)
+		for i := 0; i < src.NumField(); i++ {
+			if dst.Field(i).CanSet() {
+				deepCopy(dst.Field(i), src.Field(i))
+			}
+		}
+	default:
+		dst.Set(src)
+	}
+}
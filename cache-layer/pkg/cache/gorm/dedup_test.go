@@ -0,0 +1,238 @@
+package gorm
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/memory"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupDedupDB(t *testing.T, dedup *Deduplicator) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "dedup.db")), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&Role{}, &Member{}))
+
+	role := &Role{Name: "Admin"}
+	assert.NoError(t, db.Create(role).Error)
+	for _, name := range []string{"admin", "root"} {
+		assert.NoError(t, db.Create(&Member{Name: name, RoleID: role.ID}).Error)
+	}
+
+	// Slow down every database query so that concurrent callers overlap
+	query := db.Callback().Query().Get("gorm:query")
+	assert.NoError(t, db.Callback().Query().Replace("gorm:query", func(db *gorm.DB) {
+		time.Sleep(50 * time.Millisecond)
+		query(db)
+	}))
+
+	memCache := memory.NewGoCacheWrapper(&cache.Options{DefaultTTL: time.Minute})
+	assert.NoError(t, WithGormCacheOptions(db, memCache, &Options{Deduplicator: dedup}))
+	return db
+}
+
+func TestDeduplicator_CoalescesConcurrentQueries(t *testing.T) {
+	dedup := NewDeduplicator()
+	db := setupDedupDB(t, dedup)
+
+	const callers = 10
+	results := make([][]Member, callers)
+	errs := make([]error, callers)
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			errs[i] = db.Order("id").Find(&results[i]).Error
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	for i := 0; i < callers; i++ {
+		assert.NoError(t, errs[i])
+		assert.Len(t, results[i], 2)
+		assert.Equal(t, "admin", results[i][0].Name)
+	}
+
+	stats := dedup.Stats()
+	assert.Equal(t, uint64(callers), stats.Executed+stats.Coalesced)
+	assert.Greater(t, stats.Coalesced, uint64(0))
+
+	// Every caller owns its result
+	results[0][0].Name = "mutated"
+	for i := 1; i < callers; i++ {
+		assert.Equal(t, "admin", results[i][0].Name)
+	}
+}
+
+func TestDeduplicator_DistinctQueriesAreNotCoalesced(t *testing.T) {
+	dedup := NewDeduplicator()
+	db := setupDedupDB(t, dedup)
+
+	var wg sync.WaitGroup
+	names := []string{"admin", "root"}
+	results := make([][]Member, len(names))
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			assert.NoError(t, db.Find(&results[i], "name = ?", name).Error)
+		}(i, name)
+	}
+	wg.Wait()
+
+	assert.Equal(t, uint64(0), dedup.Stats().Coalesced)
+	assert.Equal(t, "admin", results[0][0].Name)
+	assert.Equal(t, "root", results[1][0].Name)
+}
+
+func TestDeduplicator_FollowerContext(t *testing.T) {
+	dedup := NewDeduplicator()
+	db := setupDedupDB(t, dedup)
+
+	var leader []Member
+	done := make(chan error)
+	go func() { done <- db.Order("id").Find(&leader).Error }()
+	time.Sleep(10 * time.Millisecond)
+
+	// The follower stops waiting for the slow leader once its deadline passes
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var follower []Member
+	assert.ErrorIs(t, db.WithContext(ctx).Order("id").Find(&follower).Error, context.DeadlineExceeded)
+	assert.Empty(t, follower)
+
+	assert.NoError(t, <-done)
+	assert.Len(t, leader, 2)
+	assert.Equal(t, uint64(1), dedup.Stats().Coalesced)
+}
+
+func TestDeduplicator_LeaderContext(t *testing.T) {
+	dedup := NewDeduplicator()
+	db := setupDedupDB(t, dedup)
+
+	// The leader's caller gives up while its query is running
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		var leader []Member
+		done <- db.WithContext(ctx).Order("id").Find(&leader).Error
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	// The follower runs the query itself instead of inheriting that error
+	var follower []Member
+	assert.NoError(t, db.Order("id").Find(&follower).Error)
+	assert.Len(t, follower, 2)
+	assert.ErrorIs(t, <-done, context.DeadlineExceeded)
+	assert.Equal(t, uint64(2), dedup.Stats().Executed)
+}
+
+func TestDeduplicator_LeaderPanic(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "panic.db")), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&Member{}))
+	assert.NoError(t, db.Create(&Member{Name: "admin"}).Error)
+
+	var panicked sync.Once
+	query := db.Callback().Query().Get("gorm:query")
+	assert.NoError(t, db.Callback().Query().Replace("gorm:query", func(db *gorm.DB) {
+		time.Sleep(50 * time.Millisecond)
+		panicked.Do(func() { panic("driver bug") })
+		query(db)
+	}))
+	assert.NoError(t, db.Use(NewDeduplicator()))
+
+	done := make(chan interface{})
+	go func() {
+		defer func() { done <- recover() }()
+		var leader []Member
+		_ = db.Find(&leader).Error
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var follower []Member
+	assert.NoError(t, db.WithContext(ctx).Find(&follower).Error)
+	assert.Len(t, follower, 1)
+	assert.Equal(t, "driver bug", <-done)
+}
+
+func TestQueryKey(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "key.db")), &gorm.Config{})
+	assert.NoError(t, err)
+
+	key := func(arg interface{}) string {
+		var members []Member
+		stmt := db.Session(&gorm.Session{DryRun: true}).Find(&members, "id = ?", arg)
+		return queryKey(stmt)
+	}
+
+	a, b, c := 1, 1, 2
+	assert.Equal(t, key(&a), key(&b), "pointer arguments are keyed by value")
+	assert.Equal(t, key(1), key(&a))
+	assert.NotEqual(t, key(&a), key(&c))
+	assert.NotEqual(t, key(1), key("1"))
+	assert.Equal(t, key(sql.NullInt64{Int64: 1, Valid: true}), key(int64(1)))
+	assert.Equal(t, key((*int)(nil)), key(nil))
+}
+
+func TestWithGormCacheOptions_Easer(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "easer.db")), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&TestModel{}))
+	assert.NoError(t, db.Create(&TestModel{Name: "eased"}).Error)
+
+	memCache := memory.NewGoCacheWrapper(&cache.Options{DefaultTTL: time.Minute})
+	assert.NoError(t, WithGormCacheOptions(db, memCache, &Options{Easer: true}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var models []TestModel
+			assert.NoError(t, db.Find(&models).Error)
+			assert.Len(t, models, 1)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestDeepCopy(t *testing.T) {
+	deletedAt := time.Now()
+	src := []Member{{
+		ID:        1,
+		Name:      "admin",
+		CreatedAt: deletedAt,
+		DeletedAt: gorm.DeletedAt{Time: deletedAt, Valid: true},
+		Role:      &Role{ID: 1, Name: "Admin"},
+	}}
+
+	var dst []Member
+	deepCopy(reflect.ValueOf(&dst).Elem(), reflect.ValueOf(src))
+
+	assert.Equal(t, src, dst)
+	assert.NotSame(t, &src[0], &dst[0])
+	assert.NotSame(t, src[0].Role, dst[0].Role)
+
+	m := map[string]interface{}{"ids": []int{1, 2}}
+	var mc map[string]interface{}
+	deepCopy(reflect.ValueOf(&mc).Elem(), reflect.ValueOf(m))
+	mc["ids"].([]int)[0] = 42
+	assert.Equal(t, 1, m["ids"].([]int)[0])
+}
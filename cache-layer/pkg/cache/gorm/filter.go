@@ -0,0 +1,182 @@
+package gorm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FilterKey returns the filter key of the row of table with the given primary key
+func FilterKey(table string, id interface{}) string {
+	return fmt.Sprintf("%s:%v", table, id)
+}
+
+// FilterSource returns every primary key of the given models as filter keys,
+// for use as a bloom.Source when rebuilding a filter from the database
+func FilterSource(db *gorm.DB, models ...interface{}) func(ctx context.Context, add func(keys ...string) error) error {
+	return func(ctx context.Context, add func(keys ...string) error) error {
+		for _, model := range models {
+			stmt := &gorm.Statement{DB: db}
+			if err := stmt.Parse(model); err != nil {
+				return err
+			}
+			pk := stmt.Schema.PrioritizedPrimaryField
+			if pk == nil {
+				return fmt.Errorf("%s has no primary key", stmt.Schema.Table)
+			}
+
+			rows, err := db.WithContext(ctx).Model(model).Select(pk.DBName).Rows()
+			if err != nil {
+				return err
+			}
+			if err := addRows(rows, stmt.Schema.Table, add); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// addRows adds the primary keys read from rows in batches
+func addRows(rows *sql.Rows, table string, add func(keys ...string) error) error {
+	defer rows.Close()
+
+	keys := make([]string, 0, 1000)
+	for rows.Next() {
+		var id interface{}
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		if b, ok := id.([]byte); ok {
+			id = string(b)
+		}
+		keys = append(keys, FilterKey(table, id))
+
+		if len(keys) == cap(keys) {
+			if err := add(keys...); err != nil {
+				return err
+			}
+			keys = keys[:0]
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return add(keys...)
+}
+
+// filterGuard is a GORM plugin that answers primary key lookups of rows the
+// filter rules out with gorm.ErrRecordNotFound, without querying the database
+type filterGuard struct {
+	filter cache.Filter
+}
+
+func (g *filterGuard) Name() string {
+	return "cache-layer:filter"
+}
+
+// Initialize wraps "gorm:query" and adds the keys of created rows to the filter
+func (g *filterGuard) Initialize(db *gorm.DB) error {
+	query := db.Callback().Query().Get("gorm:query")
+	if err := db.Callback().Query().Replace("gorm:query", g.wrap(query)); err != nil {
+		return err
+	}
+	return db.Callback().Create().After("gorm:create").Register("cache-layer:filter_add", g.add)
+}
+
+func (g *filterGuard) wrap(next func(*gorm.DB)) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Error == nil && !db.DryRun && !isNoCache(db.Statement.Context) {
+			if key, ok := lookupKey(db.Statement); ok {
+				// A failing filter must not make existing rows unreachable
+				if found, err := g.filter.MayContain(db.Statement.Context, key); err == nil && !found {
+					_ = db.AddError(gorm.ErrRecordNotFound)
+					return
+				}
+			}
+		}
+		next(db)
+	}
+}
+
+func (g *filterGuard) add(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil || stmt.Schema.PrioritizedPrimaryField == nil {
+		return
+	}
+	pk := stmt.Schema.PrioritizedPrimaryField
+
+	var keys []string
+	addRow := func(row reflect.Value) {
+		if id, zero := pk.ValueOf(stmt.Context, row); !zero {
+			keys = append(keys, FilterKey(stmt.Schema.Table, id))
+		}
+	}
+	switch rv := reflect.Indirect(stmt.ReflectValue); rv.Kind() {
+	case reflect.Struct:
+		addRow(rv)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			addRow(reflect.Indirect(rv.Index(i)))
+		}
+	}
+
+	if len(keys) > 0 {
+		// The row exists now; leaving it out would hide it until the next rebuild
+		if err := g.filter.Add(stmt.Context, keys...); err != nil {
+			_ = db.AddError(fmt.Errorf("failed to add created rows to the cache filter: %w", err))
+		}
+	}
+}
+
+// lookupKey returns the filter key of a First/Take/Last query on a single
+// primary key, such as db.First(&user, id)
+func lookupKey(stmt *gorm.Statement) (string, bool) {
+	if !stmt.RaiseErrorOnNotFound || stmt.Schema == nil || stmt.Schema.PrioritizedPrimaryField == nil {
+		return "", false
+	}
+
+	c, ok := stmt.Clauses["WHERE"]
+	if !ok {
+		return "", false
+	}
+	where, ok := c.Expression.(clause.Where)
+	if !ok {
+		return "", false
+	}
+
+	pk := stmt.Schema.PrioritizedPrimaryField.DBName
+	isPrimary := func(column interface{}) bool {
+		switch col := column.(type) {
+		case clause.Column:
+			return col.Name == clause.PrimaryKey || col.Name == pk
+		case string:
+			return col == pk
+		}
+		return false
+	}
+
+	// Conditions are ANDed, e.g. with the soft delete one, so a primary key
+	// condition alone decides that no row can match, unless one is ORed
+	key, found := "", false
+	for _, e := range where.Exprs {
+		switch expr := e.(type) {
+		case clause.OrConditions:
+			return "", false
+		case clause.IN:
+			if isPrimary(expr.Column) && len(expr.Values) == 1 {
+				key, found = FilterKey(stmt.Schema.Table, expr.Values[0]), true
+			}
+		case clause.Eq:
+			if isPrimary(expr.Column) {
+				key, found = FilterKey(stmt.Schema.Table, expr.Value), true
+			}
+		}
+	}
+	return key, found
+}
@@ -0,0 +1,100 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/bloom"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/memory"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupFilterDB(t *testing.T) (*gorm.DB, *bloom.Memory, *atomic.Int64) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "filter.db")), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&Role{}, &Member{}))
+
+	role := &Role{Name: "Admin"}
+	assert.NoError(t, db.Create(role).Error)
+	assert.NoError(t, db.Create(&Member{Name: "admin", RoleID: role.ID}).Error)
+
+	// Count the queries that reach the database
+	queries := &atomic.Int64{}
+	query := db.Callback().Query().Get("gorm:query")
+	assert.NoError(t, db.Callback().Query().Replace("gorm:query", func(db *gorm.DB) {
+		queries.Add(1)
+		query(db)
+	}))
+
+	filter := bloom.NewMemory(nil)
+	assert.NoError(t, filter.Rebuild(context.Background(), FilterSource(db, &Role{}, &Member{})))
+
+	memCache := memory.NewGoCacheWrapper(&cache.Options{DefaultTTL: time.Minute})
+	assert.NoError(t, WithGormCacheOptions(db, memCache, &Options{Filter: filter}))
+	queries.Store(0)
+	return db, filter, queries
+}
+
+func TestFilterGuard(t *testing.T) {
+	db, filter, queries := setupFilterDB(t)
+	ctx := context.Background()
+
+	t.Run("source adds existing rows", func(t *testing.T) {
+		for _, key := range []string{"roles:1", "members:1"} {
+			ok, err := filter.MayContain(ctx, key)
+			assert.NoError(t, err)
+			assert.True(t, ok, key)
+		}
+	})
+
+	t.Run("unknown ids never reach the database", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			var m Member
+			err := db.First(&m, 999999).Error
+			assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+		}
+		assert.Equal(t, int64(0), queries.Load())
+	})
+
+	t.Run("existing ids are queried", func(t *testing.T) {
+		var m Member
+		assert.NoError(t, db.Preload("Role").First(&m, 1).Error)
+		assert.Equal(t, "admin", m.Name)
+		assert.Equal(t, "Admin", m.Role.Name)
+	})
+
+	t.Run("created rows are added", func(t *testing.T) {
+		members := []Member{{Name: "root", RoleID: 1}, {Name: "guest", RoleID: 1}}
+		assert.NoError(t, db.Create(&members).Error)
+
+		for _, created := range members {
+			var m Member
+			assert.NoError(t, db.First(&m, created.ID).Error)
+			assert.Equal(t, created.Name, m.Name)
+		}
+	})
+
+	t.Run("other queries are not filtered", func(t *testing.T) {
+		// Rows inserted behind the filter's back are still found by non-key lookups
+		assert.NoError(t, db.Exec("INSERT INTO members (name, role_id) VALUES ('hidden', 1)").Error)
+		queries.Store(0)
+
+		var m Member
+		assert.NoError(t, db.Where("name = ?", "hidden").First(&m).Error)
+		var all []Member
+		assert.NoError(t, db.Find(&all, []uint{m.ID}).Error)
+		assert.Len(t, all, 1)
+		assert.Equal(t, int64(2), queries.Load())
+
+		// NoCache bypasses the filter as well
+		assert.ErrorIs(t, db.First(&Member{}, m.ID).Error, gorm.ErrRecordNotFound)
+		assert.NoError(t, db.WithContext(NoCache(ctx)).First(&Member{}, m.ID).Error)
+	})
+}
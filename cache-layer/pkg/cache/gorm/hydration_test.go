@@ -0,0 +1,153 @@
+package gorm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-gorm/caches/v4"
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/memory"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// Role and Member mirror the user/role domain models
+type Role struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+	Name      string
+}
+
+type Member struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+	Name      string
+	RoleID    uint
+	Role      *Role
+}
+
+func setupHydrationDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&Role{}, &Member{}))
+
+	memCache, err := memory.New(&cache.Options{DefaultTTL: time.Minute, MaxTTL: time.Minute, MaxSize: 100})
+	assert.NoError(t, err)
+	assert.NoError(t, WithGormCache(db, memCache))
+
+	role := &Role{Name: "Admin"}
+	assert.NoError(t, db.Create(role).Error)
+	assert.NoError(t, db.Create(&Member{Name: "admin", RoleID: role.ID}).Error)
+	assert.NoError(t, db.Create(&Member{Name: "gone", RoleID: role.ID}).Error)
+	assert.NoError(t, db.Where("name = ?", "gone").Delete(&Member{}).Error)
+	return db
+}
+
+func TestTypedHydration(t *testing.T) {
+	db := setupHydrationDB(t)
+
+	t.Run("preloaded associations", func(t *testing.T) {
+		var first []Member
+		assert.NoError(t, db.Preload("Role").Find(&first, "name = ?", "admin").Error)
+		assert.Len(t, first, 1)
+
+		// Raw statements do not invalidate the cache, so a stale name proves a hit
+		assert.NoError(t, db.Exec("UPDATE members SET name = ?", "renamed").Error)
+
+		var cached []Member
+		assert.NoError(t, db.Preload("Role").Find(&cached, "name = ?", "admin").Error)
+		assert.Len(t, cached, 1)
+		assert.Equal(t, "admin", cached[0].Name)
+		if assert.NotNil(t, cached[0].Role) {
+			assert.Equal(t, "Admin", cached[0].Role.Name)
+			assert.Equal(t, first[0].Role.ID, cached[0].Role.ID)
+		}
+		assert.True(t, first[0].CreatedAt.Equal(cached[0].CreatedAt))
+	})
+
+	t.Run("soft deleted records keep DeletedAt", func(t *testing.T) {
+		var first []Member
+		assert.NoError(t, db.Unscoped().Where("deleted_at IS NOT NULL").Find(&first).Error)
+		assert.Len(t, first, 1)
+
+		var cached []Member
+		assert.NoError(t, db.Unscoped().Where("deleted_at IS NOT NULL").Find(&cached).Error)
+		assert.Len(t, cached, 1)
+		assert.True(t, cached[0].DeletedAt.Valid)
+		assert.True(t, first[0].DeletedAt.Time.Equal(cached[0].DeletedAt.Time))
+	})
+}
+
+func TestGormCacher_LegacyEntries(t *testing.T) {
+	memCache := memory.NewGoCacheWrapper(&cache.Options{DefaultTTL: time.Minute})
+	cacher := &gormCacher{cache: memCache}
+	ctx := context.Background()
+
+	// Entries stored by the previous implementation were decoded JSON values
+	legacy := map[string]interface{}{
+		"Dest":         map[string]interface{}{"ID": float64(1), "Name": "legacy"},
+		"RowsAffected": float64(1),
+	}
+	assert.NoError(t, memCache.Set(ctx, "legacy", legacy, 0))
+
+	result, err := cacher.Get(ctx, "legacy", &caches.Query[any]{Dest: &TestModel{}})
+	assert.NoError(t, err)
+	if assert.NotNil(t, result) {
+		assert.Equal(t, "legacy", result.Dest.(*TestModel).Name)
+		assert.Equal(t, int64(1), result.RowsAffected)
+	}
+}
+
+func BenchmarkGormCacher_Hit(b *testing.B) {
+	memCache, _ := memory.New(&cache.Options{DefaultTTL: time.Minute, MaxTTL: time.Minute, MaxSize: 100})
+	cacher := &gormCacher{cache: memCache}
+	ctx := context.Background()
+
+	members := make([]Member, 50)
+	for i := range members {
+		members[i] = Member{
+			ID:        uint(i + 1),
+			CreatedAt: time.Now(),
+			Name:      fmt.Sprintf("member-%d", i),
+			RoleID:    1,
+			Role:      &Role{ID: 1, CreatedAt: time.Now(), Name: "Admin"},
+		}
+	}
+	if err := cacher.Store(ctx, "members", &caches.Query[any]{Dest: &members, RowsAffected: 50}); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var dest []Member
+		if _, err := cacher.Get(ctx, "members", &caches.Query[any]{Dest: &dest}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGormCacher_Store(b *testing.B) {
+	memCache, _ := memory.New(&cache.Options{DefaultTTL: time.Minute, MaxTTL: time.Minute, MaxSize: 100})
+	cacher := &gormCacher{cache: memCache}
+	ctx := context.Background()
+
+	members := make([]Member, 50)
+	for i := range members {
+		members[i] = Member{ID: uint(i + 1), CreatedAt: time.Now(), Name: fmt.Sprintf("member-%d", i)}
+	}
+	q := &caches.Query[any]{Dest: &members, RowsAffected: 50}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := cacher.Store(ctx, "members", q); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
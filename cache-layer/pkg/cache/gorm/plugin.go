@@ -11,15 +11,57 @@ import (
 	"gorm.io/gorm"
 )
 
+// Options represents the configuration options for the GORM cache plugin
+type Options struct {
+	// Easer lets the caches plugin run identical concurrent queries only once.
+	// Results are shared between callers without copying.
+	Easer bool
+	// Deduplicator, if set, coalesces identical in-flight queries on a cache
+	// miss and hands every caller its own deep copy of the result
+	Deduplicator *Deduplicator
+	// Filter, if set, is consulted on a cache miss of a primary key lookup
+	// such as db.First(&user, id); rows it rules out are reported as
+	// gorm.ErrRecordNotFound without querying the database. Created rows
+	// are added to it automatically. It must be filled, e.g. with
+	// FilterSource, before it is used.
+	Filter cache.Filter
+}
+
 // WithGormCache applies the cache plugin to the GORM DB instance
 func WithGormCache(db *gorm.DB, cache cache.Cache) error {
+	return WithGormCacheOptions(db, cache, nil)
+}
+
+// WithGormCacheOptions applies the cache plugin to the GORM DB instance
+// using the given options
+func WithGormCacheOptions(db *gorm.DB, cache cache.Cache, options *Options) error {
+	if options == nil {
+		options = &Options{}
+	}
+
+	// The deduplicator must wrap "gorm:query" before the cache plugin does,
+	// so that it only sees the queries that missed the cache
+	if options.Deduplicator != nil {
+		if err := db.Use(options.Deduplicator); err != nil {
+			return err
+		}
+	}
+
+	// The filter is checked right after a cache miss, before deduplication
+	if options.Filter != nil {
+		if err := db.Use(&filterGuard{filter: options.Filter}); err != nil {
+			return err
+		}
+	}
+
 	// Create cache plugin with configuration
 	cachePlugin := &caches.Caches{
 		Conf: &caches.Config{
 			Cacher: &gormCacher{
-				cache: cache,
+				cache:   cache,
+				schemas: &schemaKeys{db: db},
 			},
-			Easer: false,
+			Easer: options.Easer,
 		},
 	}
 
@@ -30,15 +72,27 @@ func WithGormCache(db *gorm.DB, cache cache.Cache) error {
 // gormCacher implements the caches.Cacher interface
 type gormCacher struct {
 	cache cache.Cache
+	// schemas, if set, prefixes keys with the schema of the cached model
+	schemas *schemaKeys
 }
 
 // Get retrieves a value from the cache
 func (c *gormCacher) Get(ctx context.Context, key string, q *caches.Query[any]) (*caches.Query[any], error) {
+	if isNoCache(ctx) {
+		return nil, nil
+	}
+
 	if q == nil {
 		q = &caches.Query[any]{}
 	}
 
-	value, err := c.cache.Get(ctx, key)
+	// Hydrating into the caller's typed destination keeps types such as
+	// gorm.DeletedAt and preloaded associations intact
+	if q.Dest == nil || reflect.ValueOf(q.Dest).Kind() != reflect.Ptr {
+		return nil, fmt.Errorf("q.Dest must be a non-nil pointer for cache hydration")
+	}
+
+	value, err := c.cache.Get(ctx, c.schemas.key(keyFor(ctx, key), q.Dest))
 
 	if err == cache.ErrNotFound {
 		return nil, nil // cache miss
@@ -52,41 +106,41 @@ func (c *gormCacher) Get(ctx context.Context, key string, q *caches.Query[any])
 		return nil, nil
 	}
 
-	// Convert the cached value back to Query
-	data, err := json.Marshal(value)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal cached value: %w", err)
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		// Entries written before queries were stored as encoded bytes
+		data, err = json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal cached value: %w", err)
+		}
 	}
 
-	if err := json.Unmarshal(data, q); err != nil {
+	if err := q.Unmarshal(data); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal cached value: %w", err)
 	}
 
-	if q.Dest == nil || reflect.ValueOf(q.Dest).Kind() != reflect.Ptr {
-		return nil, fmt.Errorf("q.Dest must be a non-nil pointer for cache hydration")
-	}
-
 	return q, nil
 }
 
 // Store stores a value in the cache
 func (c *gormCacher) Store(ctx context.Context, key string, val *caches.Query[any]) error {
-	if val == nil {
+	if val == nil || isNoCache(ctx) {
 		return nil
 	}
 
-	// Convert Query to a storable value
-	data, err := json.Marshal(val)
+	// The encoded query is stored as is and decoded once on Get
+	data, err := val.Marshal()
 	if err != nil {
 		return fmt.Errorf("failed to marshal query: %w", err)
 	}
 
-	var value interface{}
-	if err := json.Unmarshal(data, &value); err != nil {
-		return fmt.Errorf("failed to unmarshal query: %w", err)
-	}
-
-	return c.cache.Set(ctx, key, value, 0) // TTL is managed by the cache implementation
+	// A TTL of 0 leaves it to the cache implementation's default
+	return c.cache.Set(ctx, c.schemas.key(keyFor(ctx, key), val.Dest), data, ttlFor(ctx, val.Dest))
 }
 
 // Invalidate invalidates the cache
@@ -0,0 +1,116 @@
+package gorm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// schemaKeys prefixes the cache keys of queries with the table and a
+// fingerprint of the schema of their model, so that entries cached by a
+// build with a different model are never decoded into the new one
+type schemaKeys struct {
+	db       *gorm.DB
+	prefixes sync.Map // reflect.Type -> string
+}
+
+// key returns key prefixed for the model behind dest. Queries whose
+// destination isn't a model, such as counts, keep their key as it is.
+func (k *schemaKeys) key(key string, dest interface{}) string {
+	if k == nil {
+		return key
+	}
+	model := modelOf(dest)
+	if model == nil {
+		return key
+	}
+
+	t := reflect.TypeOf(model)
+	if prefix, ok := k.prefixes.Load(t); ok {
+		return prefix.(string) + key
+	}
+
+	prefix := ""
+	stmt := &gorm.Statement{DB: k.db}
+	if err := stmt.Parse(model); err == nil {
+		prefix = SchemaPrefix(stmt.Schema)
+	}
+	k.prefixes.Store(t, prefix)
+	return prefix + key
+}
+
+// SchemaPrefix returns the prefix of the cache keys of queries returning
+// models of s: its table and a fingerprint of its fields and those of its
+// associations, e.g. "users:1f0e3dad:"
+func SchemaPrefix(s *schema.Schema) string {
+	h := sha256.New()
+	writeSchema(h, s, make(map[*schema.Schema]bool))
+	return s.Table + ":" + hex.EncodeToString(h.Sum(nil)[:4]) + ":"
+}
+
+// TablePattern returns the cache.Match pattern of the keys of queries
+// returning models of table, whatever their schema version
+func TablePattern(table string) string {
+	var b strings.Builder
+	for _, r := range table {
+		if r == '*' || r == '?' || r == '[' || r == ']' || r == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String() + ":*"
+}
+
+// InvalidateTables deletes the cached queries returning models of the given
+// tables and returns how many there were. c must implement cache.KeyLister.
+// Queries that don't return models, such as counts, are left alone.
+func InvalidateTables(ctx context.Context, c cache.Cache, tables ...string) (int, error) {
+	deleted := 0
+	for _, table := range tables {
+		n, err := cache.DeleteMatching(ctx, c, TablePattern(table))
+		deleted += n
+		if err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// writeSchema writes what decoding a cached s depends on: the name, column,
+// type and JSON name of its fields, in name order so that moving a field
+// changes nothing, followed by the schemas of its associations
+func writeSchema(w interface{ Write([]byte) (int, error) }, s *schema.Schema, seen map[*schema.Schema]bool) {
+	if seen[s] {
+		fmt.Fprintf(w, "ref %s\n", s.Table)
+		return
+	}
+	seen[s] = true
+
+	fields := make([]string, 0, len(s.Fields))
+	for _, f := range s.Fields {
+		fields = append(fields, strings.Join([]string{
+			f.Name, f.DBName, string(f.DataType), f.FieldType.String(), f.Tag.Get("json"),
+		}, " "))
+	}
+	sort.Strings(fields)
+	fmt.Fprintf(w, "table %s\n%s\n", s.Table, strings.Join(fields, "\n"))
+
+	names := make([]string, 0, len(s.Relationships.Relations))
+	for name := range s.Relationships.Relations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "relation %s\n", name)
+		writeSchema(w, s.Relationships.Relations[name].FieldSchema, seen)
+	}
+}
@@ -0,0 +1,175 @@
+package gorm
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/memory"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// Successive versions of the same "accounts" model
+type accountV1 struct {
+	ID   uint `gorm:"primarykey"`
+	Name string
+}
+
+type accountReordered struct {
+	Name string
+	ID   uint `gorm:"primarykey"`
+}
+
+type accountV2 struct {
+	ID    uint `gorm:"primarykey"`
+	Name  string
+	Email string
+}
+
+type accountRetyped struct {
+	ID   uint `gorm:"primarykey"`
+	Name []byte
+}
+
+type accountRenamed struct {
+	ID   uint   `gorm:"primarykey"`
+	Name string `json:"name"`
+}
+
+func (accountV1) TableName() string        { return "accounts" }
+func (accountReordered) TableName() string { return "accounts" }
+func (accountV2) TableName() string        { return "accounts" }
+func (accountRetyped) TableName() string   { return "accounts" }
+func (accountRenamed) TableName() string   { return "accounts" }
+
+// A member whose role gains a field
+type roleV2 struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+	Name      string
+	Color     string
+}
+
+type memberV2 struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+	Name      string
+	RoleID    uint
+	Role      *roleV2
+}
+
+func (roleV2) TableName() string   { return "roles" }
+func (memberV2) TableName() string { return "members" }
+
+func schemaPrefix(t *testing.T, db *gorm.DB, model interface{}) string {
+	t.Helper()
+	stmt := &gorm.Statement{DB: db}
+	assert.NoError(t, stmt.Parse(model))
+	return SchemaPrefix(stmt.Schema)
+}
+
+func TestSchemaPrefix(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+
+	v1 := schemaPrefix(t, db, &accountV1{})
+	assert.True(t, strings.HasPrefix(v1, "accounts:"), v1)
+	assert.Len(t, v1, len("accounts:")+8+1)
+	assert.Equal(t, v1, schemaPrefix(t, db, &accountV1{}))
+
+	t.Run("moved field", func(t *testing.T) {
+		assert.Equal(t, v1, schemaPrefix(t, db, &accountReordered{}))
+	})
+
+	t.Run("changed fields", func(t *testing.T) {
+		prefixes := map[string]bool{v1: true}
+		for _, model := range []interface{}{&accountV2{}, &accountRetyped{}, &accountRenamed{}} {
+			prefix := schemaPrefix(t, db, model)
+			assert.True(t, strings.HasPrefix(prefix, "accounts:"), prefix)
+			assert.False(t, prefixes[prefix], "%T", model)
+			prefixes[prefix] = true
+		}
+	})
+
+	t.Run("changed association", func(t *testing.T) {
+		assert.NotEqual(t, schemaPrefix(t, db, &Member{}), schemaPrefix(t, db, &memberV2{}))
+	})
+}
+
+func TestSchemaKeys(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&accountV1{}))
+	assert.NoError(t, db.Create(&accountV1{Name: "admin"}).Error)
+
+	rec := &recordingCache{
+		Cache: memory.NewGoCacheWrapper(&cache.Options{DefaultTTL: time.Minute}),
+		sets:  make(map[string]time.Duration),
+	}
+	assert.NoError(t, WithGormCache(db, rec))
+	ctx := context.Background()
+
+	var before []accountV1
+	assert.NoError(t, db.WithContext(ctx).Find(&before).Error)
+	assert.Len(t, rec.sets, 1)
+	for key := range rec.sets {
+		assert.True(t, strings.HasPrefix(key, schemaPrefix(t, db, &accountV1{})), key)
+	}
+
+	// A deploy adds a column; the same query must not return the entry
+	// cached for the old model with a zero Email
+	assert.NoError(t, db.AutoMigrate(&accountV2{}))
+	assert.NoError(t, db.Exec("UPDATE accounts SET email = ?", "admin@example.com").Error)
+
+	rec.reset()
+	var after []accountV2
+	assert.NoError(t, db.WithContext(ctx).Find(&after).Error)
+	if assert.Len(t, after, 1) {
+		assert.Equal(t, "admin@example.com", after[0].Email)
+	}
+	assert.Len(t, rec.sets, 1)
+	for key := range rec.sets {
+		assert.True(t, strings.HasPrefix(key, schemaPrefix(t, db, &accountV2{})), key)
+	}
+}
+
+func TestInvalidateTables(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&accountV1{}, &ReferenceModel{}))
+	assert.NoError(t, db.Create(&accountV1{Name: "admin"}).Error)
+	assert.NoError(t, db.Create(&ReferenceModel{Name: "reference"}).Error)
+
+	memCache, err := memory.New(&cache.Options{DefaultTTL: time.Minute, MaxTTL: time.Minute})
+	assert.NoError(t, err)
+	assert.NoError(t, WithGormCache(db, memCache))
+	ctx := context.Background()
+
+	var accounts []accountV1
+	assert.NoError(t, db.WithContext(ctx).Find(&accounts).Error)
+	var account accountV1
+	assert.NoError(t, db.WithContext(ctx).First(&account, accounts[0].ID).Error)
+	var references []ReferenceModel
+	assert.NoError(t, db.WithContext(ctx).Find(&references).Error)
+
+	deleted, err := InvalidateTables(ctx, memCache, "accounts")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+
+	page, err := memCache.(cache.KeyLister).Keys(ctx, "", "", 0)
+	assert.NoError(t, err)
+	if assert.Len(t, page.Keys, 1) {
+		assert.True(t, strings.HasPrefix(page.Keys[0], "reference_models:"), page.Keys[0])
+	}
+
+	assert.Equal(t, `a\*b\?c\\\[d\]:*`, TablePattern(`a*b?c\[d]`))
+	// Caches that can't list their keys can only be cleared
+	_, err = InvalidateTables(ctx, &recordingCache{Cache: memCache}, "accounts")
+	assert.ErrorIs(t, err, cache.ErrNotSupported)
+}
@@ -0,0 +1,154 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// WarmupFunc runs one named warm-up query against the cached db. arg is one
+// of the values configured for the query, or "" when it has none.
+type WarmupFunc func(db *gorm.DB, arg string) error
+
+// WarmupTask selects a registered query and the arguments to run it with;
+// the query runs once per argument, or once when Args is empty
+type WarmupTask struct {
+	Name string
+	Args []string
+}
+
+// WarmupOptions represents the configuration options of a warm-up run
+type WarmupOptions struct {
+	// Concurrency is the maximum number of queries running at once (defaults to 4)
+	Concurrency int
+	// Timeout bounds the whole run; 0 leaves the caller's context untouched
+	Timeout time.Duration
+}
+
+// WarmupFailure describes a single warm-up query that failed
+type WarmupFailure struct {
+	Name string
+	Arg  string
+	Err  error
+}
+
+func (f WarmupFailure) Error() string {
+	if f.Arg == "" {
+		return fmt.Sprintf("%s: %v", f.Name, f.Err)
+	}
+	return fmt.Sprintf("%s(%s): %v", f.Name, f.Arg, f.Err)
+}
+
+// WarmupReport summarizes a warm-up run
+type WarmupReport struct {
+	Duration  time.Duration
+	Succeeded int
+	Failures  []WarmupFailure
+}
+
+// Err joins every failure into a single error, or returns nil if there were none
+func (r *WarmupReport) Err() error {
+	errs := make([]error, len(r.Failures))
+	for i, f := range r.Failures {
+		errs[i] = f
+	}
+	return errors.Join(errs...)
+}
+
+// Warmer holds the named queries that can be used to warm up the cache
+type Warmer struct {
+	queries map[string]WarmupFunc
+}
+
+// NewWarmer creates a warmer without any registered queries
+func NewWarmer() *Warmer {
+	return &Warmer{
+		queries: make(map[string]WarmupFunc),
+	}
+}
+
+// Register adds a named query, replacing any query of the same name
+func (w *Warmer) Register(name string, fn WarmupFunc) {
+	w.queries[name] = fn
+}
+
+// Names returns the registered query names in sorted order
+func (w *Warmer) Names() []string {
+	names := make([]string, 0, len(w.queries))
+	for name := range w.queries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Run executes the tasks through db, which must have the cache plugin applied,
+// so that their results are stored in the cache. Every failure, including
+// unknown query names, is collected in the report instead of stopping the run.
+func (w *Warmer) Run(ctx context.Context, db *gorm.DB, tasks []WarmupTask, options *WarmupOptions) *WarmupReport {
+	opts := WarmupOptions{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 4
+	}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	report := &WarmupReport{}
+
+	var mu sync.Mutex
+	record := func(name, arg string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			report.Failures = append(report.Failures, WarmupFailure{Name: name, Arg: arg, Err: err})
+		} else {
+			report.Succeeded++
+		}
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, opts.Concurrency)
+	for _, task := range tasks {
+		fn, ok := w.queries[task.Name]
+		if !ok {
+			record(task.Name, "", errors.New("unknown warm-up query"))
+			continue
+		}
+
+		args := task.Args
+		if len(args) == 0 {
+			args = []string{""}
+		}
+		for _, arg := range args {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				record(task.Name, arg, ctx.Err())
+				continue
+			}
+
+			wg.Add(1)
+			go func(name, arg string) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				record(name, arg, fn(db.WithContext(ctx), arg))
+			}(task.Name, arg)
+		}
+	}
+	wg.Wait()
+
+	report.Duration = time.Since(start)
+	return report
+}
@@ -0,0 +1,103 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestWarmer_Run(t *testing.T) {
+	db := setupHydrationDB(t)
+
+	warmer := NewWarmer()
+	warmer.Register("roles.all", func(db *gorm.DB, _ string) error {
+		var roles []Role
+		return db.Order("id").Find(&roles).Error
+	})
+	warmer.Register("members.by_name", func(db *gorm.DB, name string) error {
+		var members []Member
+		return db.Where("name = ?", name).Find(&members).Error
+	})
+	warmer.Register("broken", func(db *gorm.DB, _ string) error {
+		return errors.New("boom")
+	})
+	assert.Equal(t, []string{"broken", "members.by_name", "roles.all"}, warmer.Names())
+
+	report := warmer.Run(context.Background(), db, []WarmupTask{
+		{Name: "roles.all"},
+		{Name: "members.by_name", Args: []string{"admin", "nobody"}},
+		{Name: "broken"},
+		{Name: "missing"},
+	}, nil)
+
+	assert.Equal(t, 3, report.Succeeded)
+	assert.Len(t, report.Failures, 2)
+	assert.Greater(t, report.Duration, time.Duration(0))
+	assert.ErrorContains(t, report.Err(), "broken: boom")
+	assert.ErrorContains(t, report.Err(), "missing: unknown warm-up query")
+
+	// Raw statements do not invalidate the cache, so stale names prove the queries were warmed
+	assert.NoError(t, db.Exec("UPDATE roles SET name = ?", "renamed").Error)
+	assert.NoError(t, db.Exec("UPDATE members SET name = ?", "renamed").Error)
+
+	var roles []Role
+	assert.NoError(t, db.Order("id").Find(&roles).Error)
+	assert.Equal(t, "Admin", roles[0].Name)
+
+	var members []Member
+	assert.NoError(t, db.Where("name = ?", "admin").Find(&members).Error)
+	assert.Len(t, members, 1)
+	assert.Equal(t, "admin", members[0].Name)
+}
+
+func TestWarmer_BoundedConcurrency(t *testing.T) {
+	db := setupHydrationDB(t)
+
+	var running, peak atomic.Int32
+	warmer := NewWarmer()
+	warmer.Register("slow", func(db *gorm.DB, _ string) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	})
+
+	report := warmer.Run(context.Background(), db, []WarmupTask{
+		{Name: "slow", Args: []string{"1", "2", "3", "4", "5", "6", "7", "8"}},
+	}, &WarmupOptions{Concurrency: 2})
+
+	assert.Equal(t, 8, report.Succeeded)
+	assert.Empty(t, report.Failures)
+	assert.Equal(t, int32(2), peak.Load())
+}
+
+func TestWarmer_Timeout(t *testing.T) {
+	db := setupHydrationDB(t)
+
+	warmer := NewWarmer()
+	warmer.Register("blocking", func(db *gorm.DB, _ string) error {
+		<-db.Statement.Context.Done()
+		return db.Statement.Context.Err()
+	})
+
+	report := warmer.Run(context.Background(), db, []WarmupTask{
+		{Name: "blocking", Args: []string{"1", "2", "3"}},
+	}, &WarmupOptions{Concurrency: 1, Timeout: 20 * time.Millisecond})
+
+	assert.Equal(t, 0, report.Succeeded)
+	assert.Len(t, report.Failures, 3)
+	for _, f := range report.Failures {
+		assert.ErrorIs(t, f.Err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,287 @@
+// Package hotkey wraps a cache.Cache (typically Redis) with hot-key detection.
+// Access frequencies are sampled into a count-min sketch; keys whose estimate
+// crosses a threshold are served from short-lived in-process copies, taking
+// the load of a handful of popular keys off a single Redis shard.
+package hotkey
+
+import (
+	"context"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+)
+
+// HotKey is a key currently detected as hot
+type HotKey struct {
+	Key string
+	// Count is the estimated number of reads in the current window
+	Count uint32
+}
+
+// Options represents the configuration options for hot-key detection
+type Options struct {
+	// Threshold is the estimated number of reads within a window that makes a key hot (defaults to 100)
+	Threshold uint32
+	// Window is how often the sketch counters are halved (defaults to 10 seconds)
+	Window time.Duration
+	// LocalTTL bounds how stale a local copy can be (defaults to 1 second)
+	LocalTTL time.Duration
+	// MaxHotKeys is the maximum number of keys tracked as hot (defaults to 64)
+	MaxHotKeys int
+	// SampleRate is the share of reads recorded in the sketch (defaults to 1)
+	SampleRate float64
+	// Width and Depth size the count-min sketch (default to 4096 and 4)
+	Width int
+	Depth int
+}
+
+// hotEntry is a hot key and, once read, its local copy
+type hotEntry struct {
+	count     uint32
+	value     interface{}
+	expiresAt time.Time
+	// version is bumped by every write so that a read racing with it does not
+	// bring back the old value
+	version uint64
+}
+
+// Cache is a cache.Cache decorator that replicates hot keys in process
+type Cache struct {
+	next    cache.Cache
+	options Options
+	now     func() time.Time
+
+	mu        sync.Mutex
+	sketch    *sketch
+	hot       map[string]*hotEntry
+	decayedAt time.Time
+
+	localHits atomic.Uint64
+}
+
+// New wraps next with hot-key detection
+func New(next cache.Cache, options *Options) *Cache {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Threshold == 0 {
+		opts.Threshold = 100
+	}
+	if opts.Window <= 0 {
+		opts.Window = 10 * time.Second
+	}
+	if opts.LocalTTL <= 0 {
+		opts.LocalTTL = time.Second
+	}
+	if opts.MaxHotKeys <= 0 {
+		opts.MaxHotKeys = 64
+	}
+	if opts.SampleRate <= 0 || opts.SampleRate > 1 {
+		opts.SampleRate = 1
+	}
+	if opts.Width <= 0 {
+		opts.Width = 4096
+	}
+	if opts.Depth <= 0 {
+		opts.Depth = 4
+	}
+
+	return &Cache{
+		next:      next,
+		options:   opts,
+		now:       time.Now,
+		sketch:    newSketch(opts.Width, opts.Depth),
+		hot:       make(map[string]*hotEntry),
+		decayedAt: time.Now(),
+	}
+}
+
+func (c *Cache) Get(ctx context.Context, key string) (interface{}, error) {
+	entry, version, value, ok := c.record(key)
+	if ok {
+		c.localHits.Add(1)
+		return value, nil
+	}
+
+	value, err := c.next.Get(ctx, key)
+	if err != nil || value == nil || entry == nil {
+		return value, err
+	}
+
+	c.mu.Lock()
+	if c.hot[key] == entry && entry.version == version {
+		entry.value = value
+		entry.expiresAt = c.now().Add(c.options.LocalTTL)
+	}
+	c.mu.Unlock()
+	return value, nil
+}
+
+func (c *Cache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	c.drop(key)
+	return c.next.Set(ctx, key, value, ttl)
+}
+
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	c.drop(key)
+	return c.next.Delete(ctx, key)
+}
+
+func (c *Cache) Clear(ctx context.Context) error {
+	c.mu.Lock()
+	for _, entry := range c.hot {
+		entry.value = nil
+		entry.version++
+	}
+	c.mu.Unlock()
+	return c.next.Clear(ctx)
+}
+
+func (c *Cache) Close() error {
+	return c.next.Close()
+}
+
+// HotKeys returns the keys currently detected as hot, hottest first
+func (c *Cache) HotKeys() []HotKey {
+	c.mu.Lock()
+	c.maybeDecay()
+	keys := make([]HotKey, 0, len(c.hot))
+	for key, entry := range c.hot {
+		keys = append(keys, HotKey{Key: key, Count: entry.count})
+	}
+	c.mu.Unlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Count != keys[j].Count {
+			return keys[i].Count > keys[j].Count
+		}
+		return keys[i].Key < keys[j].Key
+	})
+	return keys
+}
+
+// Stats implements cache.StatsProvider when the wrapped cache does, counting
+// reads served from local copies as hits
+func (c *Cache) Stats(ctx context.Context) (cache.Stats, error) {
+	provider, ok := c.next.(cache.StatsProvider)
+	if !ok {
+		return cache.Stats{}, cache.ErrNotSupported
+	}
+
+	stats, err := provider.Stats(ctx)
+	if err != nil {
+		return stats, err
+	}
+	stats.Hits += c.localHits.Load()
+	return stats, nil
+}
+
+// Keys implements cache.KeyLister when the wrapped cache does
+func (c *Cache) Keys(ctx context.Context, pattern, cursor string, limit int) (cache.KeyPage, error) {
+	lister, ok := c.next.(cache.KeyLister)
+	if !ok {
+		return cache.KeyPage{}, cache.ErrNotSupported
+	}
+	return lister.Keys(ctx, pattern, cursor, limit)
+}
+
+// Inspect implements cache.Inspector when the wrapped cache does. Local
+// copies are not consulted, so it shows the shared entry.
+func (c *Cache) Inspect(ctx context.Context, key string) (cache.EntryInfo, error) {
+	inspector, ok := c.next.(cache.Inspector)
+	if !ok {
+		return cache.EntryInfo{}, cache.ErrNotSupported
+	}
+	return inspector.Inspect(ctx, key)
+}
+
+// record counts a read of key. For a hot key it returns its entry and version,
+// along with the local copy if that is still fresh.
+func (c *Cache) record(key string) (*hotEntry, uint64, interface{}, bool) {
+	sampled := c.options.SampleRate >= 1 || rand.Float64() < c.options.SampleRate
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maybeDecay()
+
+	entry, hot := c.hot[key]
+	if sampled {
+		count := c.sketch.add(key, uint32(1/c.options.SampleRate))
+		if hot {
+			entry.count = count
+		} else if count >= c.options.Threshold {
+			entry = c.promote(key, count)
+			hot = entry != nil
+		}
+	}
+
+	if !hot {
+		return nil, 0, nil, false
+	}
+	if entry.value != nil && c.now().Before(entry.expiresAt) {
+		return entry, entry.version, entry.value, true
+	}
+	return entry, entry.version, nil, false
+}
+
+// promote marks key as hot, displacing the coldest hot key when the set is
+// full; callers must hold c.mu
+func (c *Cache) promote(key string, count uint32) *hotEntry {
+	if len(c.hot) >= c.options.MaxHotKeys {
+		coldest, coldestCount := "", count
+		for k, e := range c.hot {
+			if e.count < coldestCount {
+				coldest, coldestCount = k, e.count
+			}
+		}
+		if coldest == "" {
+			return nil
+		}
+		delete(c.hot, coldest)
+	}
+
+	entry := &hotEntry{count: count}
+	c.hot[key] = entry
+	return entry
+}
+
+// maybeDecay halves the sketch once per window and demotes keys that are no
+// longer hot; callers must hold c.mu
+func (c *Cache) maybeDecay() {
+	windows := c.now().Sub(c.decayedAt) / c.options.Window
+	if windows <= 0 {
+		return
+	}
+	c.decayedAt = c.decayedAt.Add(windows * c.options.Window)
+
+	if windows >= 32 {
+		// Every counter would have been halved down to zero
+		c.sketch = newSketch(c.options.Width, c.options.Depth)
+	} else {
+		for i := time.Duration(0); i < windows; i++ {
+			c.sketch.decay()
+		}
+	}
+
+	for key, entry := range c.hot {
+		entry.count = c.sketch.estimate(key)
+		if entry.count < c.options.Threshold {
+			delete(c.hot, key)
+		}
+	}
+}
+
+// drop discards the local copy of key so that the next read goes to the backend
+func (c *Cache) drop(key string) {
+	c.mu.Lock()
+	if entry, ok := c.hot[key]; ok {
+		entry.value = nil
+		entry.version++
+	}
+	c.mu.Unlock()
+}
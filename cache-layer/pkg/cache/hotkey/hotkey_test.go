@@ -0,0 +1,179 @@
+package hotkey
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingCache counts the reads that reach the wrapped cache
+type countingCache struct {
+	cache.Cache
+	gets atomic.Int64
+}
+
+func (c *countingCache) Get(ctx context.Context, key string) (interface{}, error) {
+	c.gets.Add(1)
+	return c.Cache.Get(ctx, key)
+}
+
+// fakeClock is a manually advanced clock
+type fakeClock struct{ t time.Time }
+
+func (f *fakeClock) now() time.Time          { return f.t }
+func (f *fakeClock) advance(d time.Duration) { f.t = f.t.Add(d) }
+
+func newTestCache(t *testing.T, options *Options) (*Cache, *countingCache, *fakeClock) {
+	backend := &countingCache{Cache: memory.NewGoCacheWrapper(&cache.Options{DefaultTTL: time.Minute})}
+	clock := &fakeClock{t: time.Unix(0, 0)}
+
+	c := New(backend, options)
+	c.now = clock.now
+	c.decayedAt = clock.t
+	t.Cleanup(func() { _ = c.Close() })
+	return c, backend, clock
+}
+
+func TestSketch(t *testing.T) {
+	s := newSketch(1024, 4)
+	for i := 0; i < 100; i++ {
+		s.add("hot", 1)
+	}
+	for i := 0; i < 1000; i++ {
+		s.add(fmt.Sprintf("cold-%d", i), 1)
+	}
+
+	// Count-min sketches never undercount
+	assert.GreaterOrEqual(t, s.estimate("hot"), uint32(100))
+	assert.Less(t, s.estimate("hot"), uint32(110))
+	assert.Less(t, s.estimate("unseen"), uint32(10))
+
+	s.decay()
+	assert.GreaterOrEqual(t, s.estimate("hot"), uint32(50))
+	assert.Less(t, s.estimate("hot"), uint32(55))
+}
+
+func TestCache_ServesHotKeysLocally(t *testing.T) {
+	ctx := context.Background()
+	c, backend, clock := newTestCache(t, &Options{Threshold: 10, LocalTTL: time.Second})
+
+	assert.NoError(t, c.Set(ctx, "admin", "value", time.Minute))
+	assert.NoError(t, c.Set(ctx, "guest", "value", time.Minute))
+
+	// The 10th read makes the key hot and fills the local copy
+	for i := 0; i < 10; i++ {
+		v, err := c.Get(ctx, "admin")
+		assert.NoError(t, err)
+		assert.Equal(t, "value", v)
+	}
+	_, _ = c.Get(ctx, "guest")
+	assert.Equal(t, int64(11), backend.gets.Load())
+
+	for i := 0; i < 100; i++ {
+		v, err := c.Get(ctx, "admin")
+		assert.NoError(t, err)
+		assert.Equal(t, "value", v)
+	}
+	assert.Equal(t, int64(11), backend.gets.Load())
+
+	hot := c.HotKeys()
+	assert.Len(t, hot, 1)
+	assert.Equal(t, "admin", hot[0].Key)
+	assert.GreaterOrEqual(t, hot[0].Count, uint32(110))
+
+	t.Run("local copies expire", func(t *testing.T) {
+		clock.advance(2 * time.Second)
+		_, _ = c.Get(ctx, "admin")
+		assert.Equal(t, int64(12), backend.gets.Load())
+		_, _ = c.Get(ctx, "admin")
+		assert.Equal(t, int64(12), backend.gets.Load())
+	})
+
+	t.Run("writes drop local copies", func(t *testing.T) {
+		assert.NoError(t, c.Set(ctx, "admin", "updated", time.Minute))
+		v, err := c.Get(ctx, "admin")
+		assert.NoError(t, err)
+		assert.Equal(t, "updated", v)
+
+		assert.NoError(t, c.Delete(ctx, "admin"))
+		_, err = c.Get(ctx, "admin")
+		assert.ErrorIs(t, err, cache.ErrNotFound)
+
+		assert.NoError(t, c.Set(ctx, "admin", "again", time.Minute))
+		_, _ = c.Get(ctx, "admin")
+		assert.NoError(t, c.Clear(ctx))
+		_, err = c.Get(ctx, "admin")
+		assert.ErrorIs(t, err, cache.ErrNotFound)
+	})
+}
+
+func TestCache_KeysCoolDown(t *testing.T) {
+	ctx := context.Background()
+	c, _, clock := newTestCache(t, &Options{Threshold: 10, Window: time.Second})
+
+	for i := 0; i < 15; i++ {
+		_, _ = c.Get(ctx, "admin")
+	}
+	assert.Len(t, c.HotKeys(), 1)
+
+	// Halved to 7, below the threshold
+	clock.advance(time.Second)
+	assert.Empty(t, c.HotKeys())
+
+	clock.advance(time.Hour)
+	assert.Empty(t, c.HotKeys())
+	assert.Equal(t, uint32(0), c.sketch.estimate("admin"))
+}
+
+func TestCache_MaxHotKeys(t *testing.T) {
+	ctx := context.Background()
+	c, _, _ := newTestCache(t, &Options{Threshold: 5, MaxHotKeys: 2})
+
+	read := func(key string, n int) {
+		for i := 0; i < n; i++ {
+			_, _ = c.Get(ctx, key)
+		}
+	}
+	read("a", 20)
+	read("b", 10)
+	// c is not hotter than any tracked key yet
+	read("c", 5)
+	assert.Equal(t, []string{"a", "b"}, keys(c.HotKeys()))
+
+	// Once it is, it displaces the coldest one
+	read("c", 10)
+	assert.Equal(t, []string{"a", "c"}, keys(c.HotKeys()))
+}
+
+func TestCache_Stats(t *testing.T) {
+	ctx := context.Background()
+	c := New(memory.NewGoCacheWrapper(&cache.Options{DefaultTTL: time.Minute}), &Options{Threshold: 1})
+	defer c.Close()
+
+	assert.NoError(t, c.Set(ctx, "k", "v", time.Minute))
+	for i := 0; i < 5; i++ {
+		_, _ = c.Get(ctx, "k")
+	}
+
+	// One read reached the backend, four were served locally
+	stats, err := c.Stats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5), stats.Hits)
+
+	_, err = New(&countingCache{Cache: memory.NewGoCacheWrapper(&cache.Options{})}, nil).Stats(ctx)
+	assert.ErrorIs(t, err, cache.ErrNotSupported)
+}
+
+func keys(hot []HotKey) []string {
+	out := make([]string, len(hot))
+	for i, h := range hot {
+		out[i] = h.Key
+	}
+	return out
+}
@@ -0,0 +1,68 @@
+package hotkey
+
+import (
+	"hash/maphash"
+	"math"
+)
+
+// sketch is a count-min sketch estimating how often each key was seen.
+// Estimates never undercount; collisions can only make a key look hotter.
+// It is not safe for concurrent use.
+type sketch struct {
+	seed     maphash.Seed
+	width    uint64
+	counters [][]uint32
+}
+
+func newSketch(width, depth int) *sketch {
+	counters := make([][]uint32, depth)
+	for i := range counters {
+		counters[i] = make([]uint32, width)
+	}
+	return &sketch{
+		seed:     maphash.MakeSeed(),
+		width:    uint64(width),
+		counters: counters,
+	}
+}
+
+// add records n occurrences of key and returns its new estimate
+func (s *sketch) add(key string, n uint32) uint32 {
+	h1, h2 := s.hash(key)
+	estimate := uint32(math.MaxUint32)
+	for i, row := range s.counters {
+		idx := (h1 + uint64(i)*h2) % s.width
+		if row[idx] > math.MaxUint32-n {
+			row[idx] = math.MaxUint32
+		} else {
+			row[idx] += n
+		}
+		estimate = min(estimate, row[idx])
+	}
+	return estimate
+}
+
+// estimate returns how often key was seen
+func (s *sketch) estimate(key string) uint32 {
+	h1, h2 := s.hash(key)
+	estimate := uint32(math.MaxUint32)
+	for i, row := range s.counters {
+		estimate = min(estimate, row[(h1+uint64(i)*h2)%s.width])
+	}
+	return estimate
+}
+
+// decay halves every counter so that old accesses fade out
+func (s *sketch) decay() {
+	for _, row := range s.counters {
+		for i := range row {
+			row[i] >>= 1
+		}
+	}
+}
+
+// hash derives the row indexes from a single 64-bit hash (Kirsch-Mitzenmacher)
+func (s *sketch) hash(key string) (uint64, uint64) {
+	h := maphash.String(s.seed, key)
+	return h, h>>32 | 1
+}
@@ -0,0 +1,366 @@
+// Package httpcache provides net/http middleware that caches full responses
+// in any cache.Cache.
+package httpcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+)
+
+// DefaultMaxBodySize is the size of the largest response cached by default
+const DefaultMaxBodySize = 1 << 20
+
+// KeyFunc returns the cache key of a request; requests with an empty key
+// are not cached
+type KeyFunc func(r *http.Request) string
+
+// DefaultKey identifies a request by its host and URI
+func DefaultKey(r *http.Request) string {
+	return "http:" + r.Host + r.URL.RequestURI()
+}
+
+// Options represents the configuration options for Middleware
+type Options struct {
+	// TTL applies to responses without max-age or s-maxage (0 uses the
+	// cache's default TTL)
+	TTL time.Duration
+	// Key identifies the cached response of a request (defaults to
+	// DefaultKey). Requests with an Authorization header are only cached
+	// with a custom Key, since the default one doesn't tell users apart.
+	Key KeyFunc
+	// MaxBodySize is the size of the largest response cached; larger ones
+	// are streamed to the client (defaults to DefaultMaxBodySize)
+	MaxBodySize int
+}
+
+// entry is a cached response, or with Vary set, the list of request headers
+// that select the cached variant
+type entry struct {
+	Status int         `json:"status,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+	Stored time.Time   `json:"stored,omitempty"`
+	Vary   []string    `json:"vary,omitempty"`
+}
+
+// cacheableStatus lists the statuses cached by default (RFC 9110 15.1)
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusGone:                 true,
+}
+
+// uncachedHeaders are never stored with a response
+var uncachedHeaders = []string{"Age", "Connection", "Keep-Alive", "Transfer-Encoding", "X-Cache"}
+
+// Middleware caches the responses of GET requests in c and serves GET and
+// HEAD requests from it. It honours Cache-Control and Vary, answers
+// If-None-Match with 304 Not Modified, and adds an ETag to responses that
+// lack one. Successful unsafe requests, such as POST or DELETE, drop the
+// cached responses of their URL, see invalidate. Use one Middleware per
+// route to give routes their own TTL and key; it works with http.Handle as
+// well as with routers such as gorilla/mux.
+func Middleware(c cache.Cache, options *Options) func(http.Handler) http.Handler {
+	return newHandler(c, options).wrap
+}
+
+func newHandler(c cache.Cache, options *Options) *handler {
+	h := &handler{cache: c, now: time.Now}
+	if options != nil {
+		h.options = *options
+	}
+	if h.options.Key == nil {
+		h.options.Key = DefaultKey
+		h.defaultKey = true
+	}
+	if h.options.MaxBodySize <= 0 {
+		h.options.MaxBodySize = DefaultMaxBodySize
+	}
+	return h
+}
+
+type handler struct {
+	cache      cache.Cache
+	options    Options
+	defaultKey bool
+
+	now func() time.Time
+}
+
+func (h *handler) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.serve(w, r, next)
+	})
+}
+
+func (h *handler) serve(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	key := h.options.Key(r)
+	if key == "" {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		// Authenticated responses may be private, but authenticated writes
+		// still invalidate below
+		if h.defaultKey && r.Header.Get("Authorization") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+	case http.MethodOptions, http.MethodTrace:
+		next.ServeHTTP(w, r)
+		return
+	default:
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		if sw.status < 400 {
+			h.invalidate(r.Context(), key, r)
+		}
+		return
+	}
+
+	directives := parseCacheControl(r.Header.Get("Cache-Control"))
+	if directives.has("no-store") {
+		next.ServeHTTP(w, r)
+		return
+	}
+	if !directives.has("no-cache") && directives["max-age"] != "0" {
+		if e := h.lookup(r.Context(), key, r); e != nil {
+			h.writeHit(w, r, e)
+			return
+		}
+	}
+
+	rec := &recorder{w: w, status: http.StatusOK, maxBody: h.options.MaxBodySize}
+	next.ServeHTTP(rec, r)
+	if rec.passthrough {
+		return
+	}
+	if !rec.wroteHeader {
+		rec.WriteHeader(http.StatusOK)
+	}
+
+	header := w.Header()
+	if rec.status == http.StatusOK && header.Get("Etag") == "" {
+		sum := sha256.Sum256(rec.body)
+		header.Set("Etag", `"`+hex.EncodeToString(sum[:8])+`"`)
+	}
+	if r.Method == http.MethodGet {
+		h.store(r, key, rec)
+	}
+
+	header.Set("X-Cache", "MISS")
+	if rec.status == http.StatusOK && matchesETag(r.Header.Get("If-None-Match"), header.Get("Etag")) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(rec.status)
+	_, _ = w.Write(rec.body)
+}
+
+// invalidate drops the cached responses of the URL of r, whose key is key.
+// Deleting key hides its Vary variants, which are deleted as well when c
+// implements cache.KeyLister. With DefaultKey, the responses to the same
+// path with any query string are deleted too, which also needs a
+// cache.KeyLister. Other URLs, such as the collection a resource belongs
+// to, keep their responses until they expire.
+func (h *handler) invalidate(ctx context.Context, key string, r *http.Request) {
+	_ = h.cache.Delete(ctx, key)
+	if !h.defaultKey {
+		_, _ = cache.DeleteMatching(ctx, h.cache, escapePattern(key)+"|*")
+		return
+	}
+
+	path := "http:" + r.Host + r.URL.EscapedPath()
+	if path != key {
+		_ = h.cache.Delete(ctx, path)
+	}
+	_, _ = cache.DeleteMatching(ctx, h.cache, escapePattern(path)+"[?|]*")
+}
+
+// lookup returns the cached response for r, if any; cache errors are misses
+func (h *handler) lookup(ctx context.Context, key string, r *http.Request) *entry {
+	e := h.get(ctx, key)
+	if e != nil && len(e.Vary) > 0 {
+		e = h.get(ctx, variantKey(key, e.Vary, r))
+	}
+	if e == nil || len(e.Vary) > 0 {
+		return nil
+	}
+	return e
+}
+
+func (h *handler) get(ctx context.Context, key string) *entry {
+	v, err := h.cache.Get(ctx, key)
+	if err != nil {
+		return nil
+	}
+	data, ok := v.([]byte)
+	if !ok {
+		return nil
+	}
+
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil
+	}
+	return &e
+}
+
+func (h *handler) writeHit(w http.ResponseWriter, r *http.Request, e *entry) {
+	header := w.Header()
+	for name, values := range e.Header {
+		header[name] = values
+	}
+	header.Set("Age", strconv.Itoa(int(h.now().Sub(e.Stored).Seconds())))
+	header.Set("X-Cache", "HIT")
+
+	if e.Status == http.StatusOK && matchesETag(r.Header.Get("If-None-Match"), header.Get("Etag")) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(e.Status)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(e.Body)
+	}
+}
+
+// store caches the recorded response if its status and headers allow it
+func (h *handler) store(r *http.Request, key string, rec *recorder) {
+	header := rec.w.Header()
+	if !cacheableStatus[rec.status] || header.Get("Set-Cookie") != "" {
+		return
+	}
+
+	directives := parseCacheControl(header.Get("Cache-Control"))
+	if directives.has("no-store") || directives.has("no-cache") || directives.has("private") {
+		return
+	}
+	ttl := h.options.TTL
+	for _, name := range []string{"max-age", "s-maxage"} {
+		if v, ok := directives[name]; ok {
+			seconds, err := strconv.Atoi(v)
+			if err != nil || seconds <= 0 {
+				return
+			}
+			ttl = time.Duration(seconds) * time.Second
+		}
+	}
+
+	vary := varyHeaders(header)
+	for _, name := range vary {
+		if name == "*" {
+			return
+		}
+	}
+
+	stored := header.Clone()
+	for _, name := range uncachedHeaders {
+		stored.Del(name)
+	}
+	e := entry{Status: rec.status, Header: stored, Body: rec.body, Stored: h.now()}
+
+	ctx := r.Context()
+	if len(vary) > 0 {
+		if err := h.set(ctx, key, &entry{Vary: vary}, ttl); err != nil {
+			return
+		}
+		key = variantKey(key, vary, r)
+	}
+	_ = h.set(ctx, key, &e, ttl)
+}
+
+// set stores e; failing to cache it only costs a later miss
+func (h *handler) set(ctx context.Context, key string, e *entry, ttl time.Duration) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return h.cache.Set(ctx, key, data, ttl)
+}
+
+// variantKey returns the key of the variant of key selected by the values
+// of the vary headers of r
+func variantKey(key string, vary []string, r *http.Request) string {
+	values := url.Values{}
+	for _, name := range vary {
+		values[name] = r.Header.Values(name)
+	}
+	return key + "|" + values.Encode()
+}
+
+// varyHeaders returns the canonical, sorted names listed by the Vary headers
+func varyHeaders(header http.Header) []string {
+	var names []string
+	for _, v := range header.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// cacheControl holds the directives of a Cache-Control header
+type cacheControl map[string]string
+
+func parseCacheControl(value string) cacheControl {
+	directives := cacheControl{}
+	for _, part := range strings.Split(value, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name != "" {
+			directives[strings.ToLower(name)] = strings.Trim(arg, `"`)
+		}
+	}
+	return directives
+}
+
+func (c cacheControl) has(name string) bool {
+	_, ok := c[name]
+	return ok
+}
+
+// matchesETag reports whether an If-None-Match header matches etag, using
+// the weak comparison of RFC 9110
+func matchesETag(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" || etag == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// escapePattern escapes the glob characters of cache.Match in s
+func escapePattern(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,287 @@
+package httpcache
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/memory"
+	redisCache "github.com/seokheejang/go/cache-layer/pkg/cache/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingHandler answers with the number of times it was called
+type countingHandler struct {
+	calls  atomic.Int64
+	header http.Header
+	status int
+}
+
+func (h *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n := h.calls.Add(1)
+	for name, values := range h.header {
+		w.Header()[name] = values
+	}
+	if h.status != 0 {
+		w.WriteHeader(h.status)
+	}
+	fmt.Fprintf(w, "call %d lang %s", n, r.Header.Get("Accept-Language"))
+}
+
+func newCache(t *testing.T) cache.Cache {
+	c := memory.NewGoCacheWrapper(&cache.Options{DefaultTTL: time.Minute})
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func do(h http.Handler, method, target string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	for i := 0; i < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Run("hits", func(t *testing.T) {
+		next := &countingHandler{header: http.Header{"Content-Type": {"text/plain"}}}
+		h := newHandler(newCache(t), nil)
+		start := time.Now()
+		h.now = func() time.Time { return start }
+		srv := h.wrap(next)
+
+		rec := do(srv, http.MethodGet, "/users/1")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "MISS", rec.Header().Get("X-Cache"))
+		assert.Equal(t, "call 1 lang ", rec.Body.String())
+
+		h.now = func() time.Time { return start.Add(3 * time.Second) }
+		rec = do(srv, http.MethodGet, "/users/1")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "HIT", rec.Header().Get("X-Cache"))
+		assert.Equal(t, "3", rec.Header().Get("Age"))
+		assert.Equal(t, "text/plain", rec.Header().Get("Content-Type"))
+		assert.Equal(t, "call 1 lang ", rec.Body.String())
+
+		// HEAD is served from the GET response
+		rec = do(srv, http.MethodHead, "/users/1")
+		assert.Equal(t, "HIT", rec.Header().Get("X-Cache"))
+		assert.Empty(t, rec.Body.String())
+
+		// The query string is part of the key
+		assert.Equal(t, "call 2 lang ", do(srv, http.MethodGet, "/users/1?page=2").Body.String())
+		assert.Equal(t, int64(2), next.calls.Load())
+	})
+
+	t.Run("etag", func(t *testing.T) {
+		next := &countingHandler{}
+		srv := Middleware(newCache(t), nil)(next)
+
+		rec := do(srv, http.MethodGet, "/users/1")
+		etag := rec.Header().Get("Etag")
+		assert.Regexp(t, `^"[0-9a-f]{16}"$`, etag)
+
+		// Revalidation works on hits and misses alike
+		rec = do(srv, http.MethodGet, "/users/1", "If-None-Match", `"other", W/`+etag)
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+		assert.Equal(t, etag, rec.Header().Get("Etag"))
+		assert.Empty(t, rec.Body.String())
+
+		rec = do(srv, http.MethodGet, "/users/2", "If-None-Match", "*")
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+		assert.Equal(t, "MISS", rec.Header().Get("X-Cache"))
+
+		rec = do(srv, http.MethodGet, "/users/1", "If-None-Match", `"other"`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		// ETags set by the handler are kept
+		next.header = http.Header{"Etag": {`"v1"`}}
+		rec = do(srv, http.MethodGet, "/users/3")
+		assert.Equal(t, `"v1"`, rec.Header().Get("Etag"))
+		assert.Equal(t, http.StatusNotModified, do(srv, http.MethodGet, "/users/3", "If-None-Match", `"v1"`).Code)
+	})
+
+	t.Run("response cache control", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		c, err := redisCache.New(redis.NewClient(&redis.Options{Addr: mr.Addr()}), &cache.Options{DefaultTTL: time.Minute, MaxTTL: time.Hour})
+		assert.NoError(t, err)
+		defer c.Close()
+		next := &countingHandler{}
+		srv := Middleware(c, &Options{TTL: 10 * time.Second})(next)
+
+		do(srv, http.MethodGet, "/default")
+		assert.Equal(t, 10*time.Second, mr.TTL("http:example.com/default"))
+
+		next.header = http.Header{"Cache-Control": {"public, max-age=60"}}
+		do(srv, http.MethodGet, "/max-age")
+		assert.Equal(t, time.Minute, mr.TTL("http:example.com/max-age"))
+
+		next.header = http.Header{"Cache-Control": {"max-age=60, s-maxage=120"}}
+		do(srv, http.MethodGet, "/s-maxage")
+		assert.Equal(t, 2*time.Minute, mr.TTL("http:example.com/s-maxage"))
+		assert.Equal(t, "HIT", do(srv, http.MethodGet, "/s-maxage").Header().Get("X-Cache"))
+
+		for _, value := range []string{"no-store", "private, max-age=60", "no-cache", "max-age=0"} {
+			next.header = http.Header{"Cache-Control": {value}}
+			do(srv, http.MethodGet, "/uncached")
+			assert.False(t, mr.Exists("http:example.com/uncached"), value)
+		}
+
+		next.header = http.Header{"Set-Cookie": {"session=1"}}
+		do(srv, http.MethodGet, "/cookie")
+		assert.False(t, mr.Exists("http:example.com/cookie"))
+
+		next.header, next.status = nil, http.StatusInternalServerError
+		do(srv, http.MethodGet, "/error")
+		assert.False(t, mr.Exists("http:example.com/error"))
+
+		next.status = http.StatusNotFound
+		do(srv, http.MethodGet, "/missing")
+		rec := do(srv, http.MethodGet, "/missing")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, "HIT", rec.Header().Get("X-Cache"))
+	})
+
+	t.Run("request cache control", func(t *testing.T) {
+		next := &countingHandler{}
+		srv := Middleware(newCache(t), nil)(next)
+		do(srv, http.MethodGet, "/users/1")
+
+		// no-cache fetches a fresh response and caches it
+		assert.Equal(t, "call 2 lang ", do(srv, http.MethodGet, "/users/1", "Cache-Control", "no-cache").Body.String())
+		assert.Equal(t, "call 2 lang ", do(srv, http.MethodGet, "/users/1").Body.String())
+
+		// no-store bypasses the cache entirely
+		assert.Equal(t, "call 3 lang ", do(srv, http.MethodGet, "/users/1", "Cache-Control", "no-store").Body.String())
+		assert.Equal(t, "call 2 lang ", do(srv, http.MethodGet, "/users/1").Body.String())
+	})
+
+	t.Run("vary", func(t *testing.T) {
+		next := &countingHandler{header: http.Header{"Vary": {"accept-language"}}}
+		srv := Middleware(newCache(t), nil)(next)
+
+		assert.Equal(t, "call 1 lang en", do(srv, http.MethodGet, "/", "Accept-Language", "en").Body.String())
+		assert.Equal(t, "call 2 lang ko", do(srv, http.MethodGet, "/", "Accept-Language", "ko").Body.String())
+		assert.Equal(t, "call 1 lang en", do(srv, http.MethodGet, "/", "Accept-Language", "en").Body.String())
+		assert.Equal(t, "call 2 lang ko", do(srv, http.MethodGet, "/", "Accept-Language", "ko").Body.String())
+
+		next.header = http.Header{"Vary": {"*"}}
+		do(srv, http.MethodGet, "/star")
+		assert.Equal(t, "call 4 lang ", do(srv, http.MethodGet, "/star").Body.String())
+	})
+
+	t.Run("unsafe methods invalidate", func(t *testing.T) {
+		next := &countingHandler{}
+		srv := Middleware(newCache(t), nil)(next)
+		do(srv, http.MethodGet, "/users/1")
+
+		next.status = http.StatusForbidden
+		do(srv, http.MethodDelete, "/users/1")
+		assert.Equal(t, "HIT", do(srv, http.MethodGet, "/users/1").Header().Get("X-Cache"))
+
+		next.status = http.StatusNoContent
+		do(srv, http.MethodPut, "/users/1")
+		next.status = 0
+		assert.Equal(t, "call 4 lang ", do(srv, http.MethodGet, "/users/1").Body.String())
+	})
+
+	t.Run("unsafe methods invalidate every variant", func(t *testing.T) {
+		next := &countingHandler{header: http.Header{"Vary": {"Accept-Language"}}}
+		srv := Middleware(newCache(t), nil)(next)
+		for _, target := range []string{"/users", "/users?page=2", "/users/1", "/users2"} {
+			do(srv, http.MethodGet, target, "Accept-Language", "en")
+			do(srv, http.MethodGet, target, "Accept-Language", "ko")
+		}
+
+		do(srv, http.MethodPost, "/users?source=form")
+		for _, target := range []string{"/users", "/users?page=2"} {
+			for _, lang := range []string{"en", "ko"} {
+				assert.Equal(t, "MISS", do(srv, http.MethodGet, target, "Accept-Language", lang).Header().Get("X-Cache"), target+" "+lang)
+			}
+		}
+		// Other paths, even sharing a prefix, are left alone
+		for _, target := range []string{"/users/1", "/users2"} {
+			assert.Equal(t, "HIT", do(srv, http.MethodGet, target, "Accept-Language", "en").Header().Get("X-Cache"), target)
+		}
+	})
+
+	t.Run("authenticated unsafe methods invalidate", func(t *testing.T) {
+		next := &countingHandler{}
+		srv := Middleware(newCache(t), nil)(next)
+		do(srv, http.MethodGet, "/users/1")
+
+		// Authenticated reads bypass the cache
+		assert.Equal(t, "call 2 lang ", do(srv, http.MethodGet, "/users/1", "Authorization", "Bearer t").Body.String())
+		assert.Equal(t, "HIT", do(srv, http.MethodGet, "/users/1").Header().Get("X-Cache"))
+
+		do(srv, http.MethodPatch, "/users/1", "Authorization", "Bearer t")
+		assert.Equal(t, "call 4 lang ", do(srv, http.MethodGet, "/users/1").Body.String())
+	})
+
+	t.Run("per route keys", func(t *testing.T) {
+		next := &countingHandler{}
+		mux := http.NewServeMux()
+		mux.Handle("/users", Middleware(newCache(t), &Options{
+			Key: func(r *http.Request) string {
+				// Tokens identify users, and the tracking parameter is ignored
+				if r.Header.Get("Authorization") == "" {
+					return ""
+				}
+				return "users:" + r.Header.Get("Authorization") + ":" + r.URL.Query().Get("page")
+			},
+		})(next))
+		mux.Handle("/public", Middleware(newCache(t), nil)(next))
+
+		do(mux, http.MethodGet, "/users?page=1&utm=a", "Authorization", "alice")
+		assert.Equal(t, "HIT", do(mux, http.MethodGet, "/users?page=1&utm=b", "Authorization", "alice").Header().Get("X-Cache"))
+		assert.Equal(t, "MISS", do(mux, http.MethodGet, "/users?page=1", "Authorization", "bob").Header().Get("X-Cache"))
+		assert.Empty(t, do(mux, http.MethodGet, "/users?page=1").Header().Get("X-Cache"))
+
+		// The default key doesn't tell users apart, so it skips authorized requests
+		do(mux, http.MethodGet, "/public", "Authorization", "alice")
+		assert.Empty(t, do(mux, http.MethodGet, "/public", "Authorization", "alice").Header().Get("X-Cache"))
+	})
+
+	t.Run("large responses are streamed", func(t *testing.T) {
+		body := strings.Repeat("x", 100)
+		srv := Middleware(newCache(t), &Options{MaxBodySize: 64})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+			for i := 0; i < 10; i++ {
+				_, _ = w.Write([]byte(body[i*10 : (i+1)*10]))
+			}
+		}))
+
+		for i := 0; i < 2; i++ {
+			rec := do(srv, http.MethodGet, "/large")
+			assert.Equal(t, http.StatusAccepted, rec.Code)
+			assert.Equal(t, body, rec.Body.String())
+			assert.Empty(t, rec.Header().Get("X-Cache"))
+		}
+	})
+
+	t.Run("flushed responses are streamed", func(t *testing.T) {
+		next := &countingHandler{}
+		srv := Middleware(newCache(t), nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("event 1\n"))
+			assert.NoError(t, http.NewResponseController(w).Flush())
+			next.ServeHTTP(w, r)
+		}))
+
+		for i := 1; i <= 2; i++ {
+			rec := do(srv, http.MethodGet, "/events")
+			assert.True(t, rec.Flushed)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, fmt.Sprintf("event 1\ncall %d lang ", i), rec.Body.String())
+			assert.Empty(t, rec.Header().Get("X-Cache"))
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package httpcache
+
+import "net/http"
+
+// recorder buffers a response so that it can be cached. Responses larger
+// than maxBody are passed through to the client instead.
+type recorder struct {
+	w           http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        []byte
+	maxBody     int
+	passthrough bool
+}
+
+func (r *recorder) Header() http.Header {
+	return r.w.Header()
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.wroteHeader {
+		return
+	}
+	r.status = status
+	r.wroteHeader = true
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	if !r.wroteHeader {
+		r.WriteHeader(http.StatusOK)
+	}
+	if r.passthrough {
+		return r.w.Write(p)
+	}
+
+	if len(r.body)+len(p) > r.maxBody {
+		if err := r.stream(); err != nil {
+			return 0, err
+		}
+		return r.w.Write(p)
+	}
+	r.body = append(r.body, p...)
+	return len(p), nil
+}
+
+// Flush streams the response to the client, which is then not cached
+func (r *recorder) Flush() {
+	if !r.wroteHeader {
+		r.WriteHeader(http.StatusOK)
+	}
+	if !r.passthrough && r.stream() != nil {
+		return
+	}
+	_ = http.NewResponseController(r.w).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *recorder) Unwrap() http.ResponseWriter {
+	return r.w
+}
+
+// stream switches to passing the response through, writing what was
+// buffered so far
+func (r *recorder) stream() error {
+	r.passthrough = true
+	r.w.WriteHeader(r.status)
+	_, err := r.w.Write(r.body)
+	r.body = nil
+	return err
+}
+
+// statusWriter records the status of a response written through it
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// KeyPage is one page of keys
+type KeyPage struct {
+	Keys []string
+	// Cursor fetches the next page; it is empty once every key was returned
+	Cursor string
+}
+
+// KeyLister is implemented by caches that can enumerate their keys
+type KeyLister interface {
+	// Keys returns a page of about limit keys matching pattern (see Match),
+	// starting at cursor, which is empty for the first page. Keys written
+	// while listing may or may not be returned.
+	Keys(ctx context.Context, pattern, cursor string, limit int) (KeyPage, error)
+}
+
+// EntryInfo describes a cache entry
+type EntryInfo struct {
+	Key   string
+	Value interface{}
+	// Size is the stored size of the value in bytes, or -1 when unknown
+	Size int64
+	// ExpiresAt is zero for entries that never expire
+	ExpiresAt time.Time
+}
+
+// Inspector is implemented by caches that can describe an entry without it
+// counting as a read. Missing entries are reported with ErrNotFound.
+type Inspector interface {
+	Inspect(ctx context.Context, key string) (EntryInfo, error)
+}
+
+// Match reports whether key matches pattern, in which '*' matches any
+// sequence of characters, '?' any single character, '[...]' any character
+// of a class such as [abc], [a-z] or [^0-9], and '\' escapes the next one,
+// as in Redis. An empty pattern matches every key.
+func Match(pattern, key string) bool {
+	if pattern == "" {
+		return true
+	}
+
+	// Backtrack to the last '*' on a mismatch
+	p, k := 0, 0
+	star, starKey := -1, 0
+	for k < len(key) {
+		if p < len(pattern) {
+			switch c := pattern[p]; {
+			case c == '*':
+				star, starKey = p, k
+				p++
+				continue
+			case c == '?':
+				p++
+				k++
+				continue
+			case c == '[':
+				if end, ok := matchClass(pattern, p+1, key[k]); ok {
+					p = end
+					k++
+					continue
+				}
+			case c == '\\' && p+1 < len(pattern):
+				if pattern[p+1] == key[k] {
+					p += 2
+					k++
+					continue
+				}
+			case c == key[k]:
+				p++
+				k++
+				continue
+			}
+		}
+		if star < 0 {
+			return false
+		}
+		starKey++
+		p, k = star+1, starKey
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// matchClass reports whether c belongs to the class starting at pattern[p],
+// just after its '[', and returns the index following its ']'. As in Redis,
+// an unterminated class ends with the pattern.
+func matchClass(pattern string, p int, c byte) (int, bool) {
+	negate := p < len(pattern) && pattern[p] == '^'
+	if negate {
+		p++
+	}
+	match := false
+	for ; p < len(pattern) && pattern[p] != ']'; p++ {
+		switch {
+		case pattern[p] == '\\' && p+1 < len(pattern):
+			p++
+			match = match || pattern[p] == c
+		case p+2 < len(pattern) && pattern[p+1] == '-':
+			lo, hi := pattern[p], pattern[p+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			match = match || (lo <= c && c <= hi)
+			p += 2
+		default:
+			match = match || pattern[p] == c
+		}
+	}
+	if p < len(pattern) {
+		p++
+	}
+	return p, match != negate
+}
+
+// DeleteMatching deletes every key of c matching pattern and returns how
+// many there were. c must implement KeyLister.
+func DeleteMatching(ctx context.Context, c Cache, pattern string) (int, error) {
+	lister, ok := c.(KeyLister)
+	if !ok {
+		return 0, ErrNotSupported
+	}
+
+	deleted, cursor := 0, ""
+	for {
+		page, err := lister.Keys(ctx, pattern, cursor, 1000)
+		if err != nil {
+			return deleted, err
+		}
+		for _, key := range page.Keys {
+			if err := c.Delete(ctx, key); err != nil {
+				return deleted, err
+			}
+			deleted++
+		}
+		if page.Cursor == "" {
+			return deleted, nil
+		}
+		cursor = page.Cursor
+	}
+}
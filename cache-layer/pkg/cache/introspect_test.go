@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, key string
+		want         bool
+	}{
+		{"", "anything", true},
+		{"*", "", true},
+		{"*", "users:1", true},
+		{"users:*", "users:1f0e3dad:SELECT * FROM users", true},
+		{"users:*", "user_roles:1", false},
+		{"*:1", "users:1", true},
+		{"*:1", "users:12", false},
+		{"user?:1", "users:1", true},
+		{"user?:1", "user:1", false},
+		{"a*b*c", "aXXbYYc", true},
+		{"a*b*c", "aXXbYY", false},
+		{"a*b", "abab", true},
+		{`a\*`, "a*", true},
+		{`a\*`, "ab", false},
+		{`a\?`, "a?", true},
+		{"user[sx]:1", "users:1", true},
+		{"user[sx]:1", "usery:1", false},
+		{"users:[0-9]", "users:7", true},
+		{"users:[9-0]", "users:7", true},
+		{"users:[0-9]", "users:a", false},
+		{"users:[^0-9]", "users:a", true},
+		{"users:[^0-9]", "users:7", false},
+		{`[\]]`, "]", true},
+		{`[a\-z]`, "-", true},
+		{`[a\-z]`, "b", false},
+		{"[]a", "a", false},
+		{"*[0-9]", "users:12", true},
+		{`\[a]`, "[a]", true},
+		{`\[a]`, "a", false},
+		{"[ab", "b", true},
+		{"exact", "exact", true},
+		{"exact", "exactly", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.key, func(t *testing.T) {
+			assert.Equal(t, tt.want, Match(tt.pattern, tt.key))
+		})
+	}
+}
+
+// listingCache is a mapCache listing its keys two at a time
+type listingCache struct {
+	mapCache
+}
+
+func (c listingCache) Keys(ctx context.Context, pattern, cursor string, limit int) (KeyPage, error) {
+	var keys []string
+	for key := range c.mapCache {
+		if key > cursor && Match(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	if len(keys) > 2 {
+		return KeyPage{Keys: keys[:2], Cursor: keys[1]}, nil
+	}
+	return KeyPage{Keys: keys}, nil
+}
+
+func TestDeleteMatching(t *testing.T) {
+	ctx := context.Background()
+	c := listingCache{mapCache{}}
+	for _, key := range []string{"users:1", "users:2", "users:3", "users:4", "users:5", "roles:1"} {
+		c.mapCache[key] = "value"
+	}
+
+	deleted, err := DeleteMatching(ctx, c, "users:*")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, deleted)
+	assert.Equal(t, mapCache{"roles:1": "value"}, c.mapCache)
+
+	_, err = DeleteMatching(ctx, mapCache{}, "*")
+	assert.ErrorIs(t, err, ErrNotSupported)
+}
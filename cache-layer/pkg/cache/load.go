@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Filter is a probabilistic set of the keys that exist in the system of
+// record, such as a Bloom filter. MayContain can report false positives but
+// never false negatives, so a false answer means the key does not exist.
+type Filter interface {
+	// Add records keys that now exist
+	Add(ctx context.Context, keys ...string) error
+
+	// MayContain reports whether key might exist
+	MayContain(ctx context.Context, key string) (bool, error)
+}
+
+// LoadFunc loads a value missing from the cache from the system of record
+type LoadFunc func(ctx context.Context) (interface{}, error)
+
+// LoadOptions represents the configuration options for GetOrLoad
+type LoadOptions struct {
+	// TTL is the time-to-live of loaded values (0 uses the cache's default)
+	TTL time.Duration
+	// Filter, if set, is consulted before load so that keys known not to
+	// exist never reach the system of record
+	Filter Filter
+}
+
+// GetOrLoad returns the cached value of key, or calls load on a miss and
+// caches its result. Cache errors are treated as misses, but a done ctx
+// returns ctx.Err() before load is called. It returns ErrNotFound without
+// calling load when the filter rules the key out, or when load returns a
+// nil value.
+func GetOrLoad(ctx context.Context, c Cache, key string, load LoadFunc, options *LoadOptions) (interface{}, error) {
+	if options == nil {
+		options = &LoadOptions{}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if value, err := c.Get(ctx, key); err == nil && value != nil {
+		return value, nil
+	}
+
+	if options.Filter != nil {
+		// A failing filter must not make existing keys unreachable
+		if ok, err := options.Filter.MayContain(ctx, key); err == nil && !ok {
+			return nil, ErrNotFound
+		}
+	}
+
+	// The cache and filter lookups may have outlived the context
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	value, err := load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, ErrNotFound
+	}
+
+	// The value was loaded; failing to cache it only costs a later miss
+	_ = c.Set(ctx, key, value, options.TTL)
+	return value, nil
+}
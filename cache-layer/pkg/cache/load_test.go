@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mapCache is a minimal Cache for testing helpers of this package
+type mapCache map[string]interface{}
+
+func (m mapCache) Get(ctx context.Context, key string) (interface{}, error) {
+	if v, ok := m[key]; ok {
+		return v, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (m mapCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	m[key] = value
+	return nil
+}
+
+func (m mapCache) Delete(ctx context.Context, key string) error {
+	delete(m, key)
+	return nil
+}
+
+func (m mapCache) Clear(ctx context.Context) error {
+	clear(m)
+	return nil
+}
+
+func (m mapCache) Close() error { return nil }
+
+// setFilter is an exact Filter backed by a set
+type setFilter struct {
+	keys map[string]bool
+	err  error
+}
+
+func (f *setFilter) Add(ctx context.Context, keys ...string) error {
+	for _, k := range keys {
+		f.keys[k] = true
+	}
+	return nil
+}
+
+func (f *setFilter) MayContain(ctx context.Context, key string) (bool, error) {
+	return f.keys[key], f.err
+}
+
+func TestGetOrLoad(t *testing.T) {
+	ctx := context.Background()
+	c := mapCache{}
+	loads := 0
+	load := func(v interface{}, err error) LoadFunc {
+		return func(ctx context.Context) (interface{}, error) {
+			loads++
+			return v, err
+		}
+	}
+
+	v, err := GetOrLoad(ctx, c, "users:1", load("admin", nil), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", v)
+	assert.Equal(t, "admin", c["users:1"])
+
+	v, err = GetOrLoad(ctx, c, "users:1", load("other", nil), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", v)
+	assert.Equal(t, 1, loads)
+
+	t.Run("load errors are returned and not cached", func(t *testing.T) {
+		errDB := errors.New("database unavailable")
+		_, err := GetOrLoad(ctx, c, "users:2", load(nil, errDB), nil)
+		assert.ErrorIs(t, err, errDB)
+		assert.NotContains(t, c, "users:2")
+
+		_, err = GetOrLoad(ctx, c, "users:2", load(nil, nil), nil)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		filter := &setFilter{keys: map[string]bool{"users:3": true}}
+		opts := &LoadOptions{Filter: filter}
+		loads = 0
+
+		_, err := GetOrLoad(ctx, c, "users:999999", load("ghost", nil), opts)
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.Equal(t, 0, loads)
+
+		v, err := GetOrLoad(ctx, c, "users:3", load("guest", nil), opts)
+		assert.NoError(t, err)
+		assert.Equal(t, "guest", v)
+		assert.Equal(t, 1, loads)
+
+		// A failing filter falls through to the loader
+		filter.err = errors.New("redis unavailable")
+		v, err = GetOrLoad(ctx, c, "users:4", load("late", nil), opts)
+		assert.NoError(t, err)
+		assert.Equal(t, "late", v)
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		loads = 0
+
+		_, err := GetOrLoad(cancelled, c, "users:1", load("admin", nil), nil)
+		assert.ErrorIs(t, err, context.Canceled)
+		_, err = GetOrLoad(cancelled, c, "users:5", load("new", nil), nil)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 0, loads)
+	})
+}
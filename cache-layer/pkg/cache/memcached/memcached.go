@@ -0,0 +1,291 @@
+// Package memcached implements cache.Cache on one or more memcached servers,
+// spread over a consistent hash ring.
+package memcached
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+)
+
+const (
+	// maxRelativeTTL is the longest expiration memcached reads as relative
+	// to now; longer ones are taken as Unix timestamps
+	maxRelativeTTL = 30 * 24 * time.Hour
+
+	// maxKeyLength is the longest key memcached accepts
+	maxKeyLength = 250
+
+	// flagRaw marks []byte values stored verbatim, the others being JSON
+	flagRaw uint32 = 1
+
+	// DefaultVersionTTL is how long the namespace version is cached by default
+	DefaultVersionTTL = time.Second
+)
+
+// errNoVersion wraps the errors fetching the namespace version
+var errNoVersion = errors.New("memcached: namespace version unavailable")
+
+// Options represents the configuration options for a memcached cache
+type Options struct {
+	cache.Options
+	// Namespace prefixes every key, so that several caches can share the
+	// servers and be cleared independently (defaults to "cache")
+	Namespace string
+	// VersionTTL is how long the namespace version is cached in process
+	// (defaults to DefaultVersionTTL). A Clear by another instance shows
+	// after at most this long.
+	VersionTTL time.Duration
+}
+
+type memcachedCache struct {
+	client  *memcache.Client
+	options Options
+	now     func() time.Time
+
+	// mu guards the cached namespace version, or the error fetching it
+	mu             sync.Mutex
+	version        uint64
+	versionErr     error
+	versionExpires time.Time
+}
+
+// NewClient creates a memcached client placing keys on servers with a
+// consistent hash Ring
+func NewClient(servers ...string) (*memcache.Client, error) {
+	ring, err := NewRing(servers...)
+	if err != nil {
+		return nil, err
+	}
+	return memcache.NewFromSelector(ring), nil
+}
+
+// New creates a new memcached cache instance
+func New(client *memcache.Client, options *Options) (cache.Cache, error) {
+	if options == nil {
+		options = &Options{
+			Options: cache.Options{
+				DefaultTTL: 2 * time.Second,
+				MaxTTL:     5 * time.Second,
+			},
+		}
+	}
+
+	c := &memcachedCache{
+		client:  client,
+		options: *options,
+		now:     time.Now,
+	}
+	if c.options.Namespace == "" {
+		c.options.Namespace = "cache"
+	}
+	if c.options.VersionTTL <= 0 {
+		c.options.VersionTTL = DefaultVersionTTL
+	}
+	return c, nil
+}
+
+func (c *memcachedCache) Get(ctx context.Context, key string) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	k, err := c.key(key)
+	if errors.Is(err, errNoVersion) {
+		// The entries of an unknown version can't be found
+		return nil, cache.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := c.client.Get(k)
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		return nil, cache.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if item.Flags == flagRaw {
+		return item.Value, nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(item.Value, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (c *memcachedCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if ttl == 0 {
+		ttl = c.options.DefaultTTL
+	}
+	if c.options.MaxTTL > 0 && (ttl > c.options.MaxTTL || ttl <= 0) {
+		ttl = c.options.MaxTTL
+	}
+
+	item := &memcache.Item{Expiration: expiration(ttl, c.now())}
+	if raw, ok := value.([]byte); ok {
+		item.Value, item.Flags = raw, flagRaw
+	} else {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		item.Value = data
+	}
+
+	k, err := c.key(key)
+	if err != nil {
+		return err
+	}
+	item.Key = k
+	return c.client.Set(item)
+}
+
+func (c *memcachedCache) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	k, err := c.key(key)
+	if err != nil {
+		return err
+	}
+
+	if err := c.client.Delete(k); err != nil && !errors.Is(err, memcache.ErrCacheMiss) {
+		return err
+	}
+	return nil
+}
+
+// Clear moves the namespace to a new version. memcached cannot delete by
+// prefix, so the entries of the old version are left to expire or be evicted.
+func (c *memcachedCache) Clear(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	version, err := c.client.Increment(c.versionKey(), 1)
+	if errors.Is(err, memcache.ErrCacheMiss) {
+		// Without a version there is nothing to clear
+		version, err = c.fetchVersion()
+	}
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.version, c.versionErr, c.versionExpires = version, nil, c.now().Add(c.options.VersionTTL)
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *memcachedCache) Close() error {
+	return c.client.Close()
+}
+
+// key returns the memcached key of key in the current namespace version.
+// Keys memcached would reject, being too long or containing spaces or
+// control characters, are replaced with their hash.
+func (c *memcachedCache) key(key string) (string, error) {
+	version, err := c.currentVersion()
+	if err != nil {
+		return "", err
+	}
+
+	prefix := c.options.Namespace + ":" + strconv.FormatUint(version, 10) + ":"
+	if len(prefix)+len(key) <= maxKeyLength && legalKey(key) {
+		return prefix + key, nil
+	}
+	sum := sha256.Sum256([]byte(key))
+	return prefix + "#" + hex.EncodeToString(sum[:]), nil
+}
+
+// currentVersion returns the version of the namespace, fetched at most once
+// per VersionTTL so that every operation doesn't wait on the server holding
+// it. Failures are cached as well, so a server that is down isn't asked
+// again before then.
+func (c *memcachedCache) currentVersion() (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if now.Before(c.versionExpires) {
+		return c.version, c.versionErr
+	}
+
+	c.version, c.versionErr = c.fetchVersion()
+	if c.versionErr != nil {
+		c.versionErr = fmt.Errorf("%w: %w", errNoVersion, c.versionErr)
+	}
+	c.versionExpires = now.Add(c.options.VersionTTL)
+	return c.version, c.versionErr
+}
+
+// fetchVersion returns the current version of the namespace, creating it if
+// needed. A new version starts at the current time in nanoseconds, so that
+// versions are not reused when memcached evicts or restarts and loses it.
+func (c *memcachedCache) fetchVersion() (uint64, error) {
+	for {
+		item, err := c.client.Get(c.versionKey())
+		if err == nil {
+			return strconv.ParseUint(string(item.Value), 10, 64)
+		}
+		if !errors.Is(err, memcache.ErrCacheMiss) {
+			return 0, err
+		}
+
+		version := uint64(c.now().UnixNano())
+		err = c.client.Add(&memcache.Item{
+			Key:   c.versionKey(),
+			Value: []byte(strconv.FormatUint(version, 10)),
+		})
+		if err == nil {
+			return version, nil
+		}
+		// Another client created it first
+		if !errors.Is(err, memcache.ErrNotStored) {
+			return 0, err
+		}
+	}
+}
+
+func (c *memcachedCache) versionKey() string {
+	return c.options.Namespace + ":version"
+}
+
+// expiration converts ttl into memcached's expiration time: seconds from
+// now up to 30 days, and a Unix timestamp beyond, as memcached reads longer
+// durations as timestamps. Non-positive TTLs never expire.
+func expiration(ttl time.Duration, now time.Time) int32 {
+	if ttl <= 0 {
+		return 0
+	}
+	// Round up, as 0 would mean never
+	seconds := int64((ttl + time.Second - 1) / time.Second)
+	if ttl > maxRelativeTTL {
+		return int32(now.Unix() + seconds)
+	}
+	return int32(seconds)
+}
+
+func legalKey(key string) bool {
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,278 @@
+package memcached
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/cachetest"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestCache creates a cache on the given servers whose clock follows the
+// first one
+func newTestCache(t *testing.T, options *Options, servers ...*testServer) *memcachedCache {
+	addrs := make([]string, len(servers))
+	for i, s := range servers {
+		addrs[i] = s.Addr()
+	}
+	client, err := NewClient(addrs...)
+	assert.NoError(t, err)
+
+	c, err := New(client, options)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+
+	mc := c.(*memcachedCache)
+	mc.now = servers[0].Now
+	return mc
+}
+
+func TestMemcachedCache_Contract(t *testing.T) {
+	srv := newTestServer(t)
+	cachetest.Run(t, cachetest.Suite{
+		New: func(t *testing.T, options *cache.Options) cache.Cache {
+			return newTestCache(t, &Options{Options: *options, Namespace: t.Name()}, srv)
+		},
+		Sleep: srv.FastForward,
+		Unit:  time.Second,
+	})
+}
+
+func TestMemcachedCache_Values(t *testing.T) {
+	srv := newTestServer(t)
+	c := newTestCache(t, nil, srv)
+	ctx := context.Background()
+
+	payload := []byte(`{"Dest":{"Name":"admin"},"RowsAffected":1}`)
+	assert.NoError(t, c.Set(ctx, "raw", payload, 0))
+	assert.NoError(t, c.Set(ctx, "json", map[string]interface{}{"n": float64(1)}, 0))
+
+	val, err := c.Get(ctx, "raw")
+	assert.NoError(t, err)
+	assert.Equal(t, payload, val)
+
+	val, err = c.Get(ctx, "json")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"n": float64(1)}, val)
+
+	_, err = c.Get(ctx, "missing")
+	assert.ErrorIs(t, err, cache.ErrNotFound)
+
+	t.Run("illegal keys", func(t *testing.T) {
+		// GORM plugin keys contain spaces, and may be longer than memcached allows
+		keys := []string{"SELECT * FROM users WHERE id = 1", strings.Repeat("k", 300), "tab\tkey"}
+		for i, key := range keys {
+			assert.NoError(t, c.Set(ctx, key, i, 0))
+		}
+		for i, key := range keys {
+			val, err := c.Get(ctx, key)
+			assert.NoError(t, err)
+			assert.Equal(t, float64(i), val)
+		}
+		assert.NoError(t, c.Delete(ctx, keys[1]))
+		_, err := c.Get(ctx, keys[1])
+		assert.ErrorIs(t, err, cache.ErrNotFound)
+	})
+}
+
+func TestExpiration(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		ttl  time.Duration
+		want int32
+	}{
+		{0, 0},
+		{-time.Second, 0},
+		{time.Millisecond, 1},
+		{1500 * time.Millisecond, 2},
+		{time.Minute, 60},
+		{maxRelativeTTL, 2592000},
+		{maxRelativeTTL + time.Second, 1700000000 + 2592001},
+		{60 * 24 * time.Hour, 1700000000 + 5184000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ttl.String(), func(t *testing.T) {
+			assert.Equal(t, tt.want, expiration(tt.ttl, now))
+		})
+	}
+}
+
+func TestMemcachedCache_LongTTL(t *testing.T) {
+	srv := newTestServer(t)
+	c := newTestCache(t, &Options{}, srv)
+	ctx := context.Background()
+
+	// Sent as a timestamp; as a number of seconds, memcached would read it
+	// as a date in 1970 and drop the entry at once
+	assert.NoError(t, c.Set(ctx, "key", "value", 40*24*time.Hour))
+	srv.FastForward(39 * 24 * time.Hour)
+	_, err := c.Get(ctx, "key")
+	assert.NoError(t, err)
+
+	srv.FastForward(2 * 24 * time.Hour)
+	_, err = c.Get(ctx, "key")
+	assert.ErrorIs(t, err, cache.ErrNotFound)
+}
+
+func TestMemcachedCache_Clear(t *testing.T) {
+	servers := []*testServer{newTestServer(t), newTestServer(t), newTestServer(t)}
+	c := newTestCache(t, &Options{Namespace: "users"}, servers...)
+	other := newTestCache(t, &Options{Namespace: "roles"}, servers...)
+	ctx := context.Background()
+
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, c.Set(ctx, fmt.Sprintf("key%d", i), i, time.Hour))
+	}
+	assert.NoError(t, other.Set(ctx, "key0", "role", time.Hour))
+	for _, srv := range servers {
+		assert.Greater(t, srv.Len(), 5, "keys are spread over every server")
+	}
+
+	assert.NoError(t, c.Clear(ctx))
+	for i := 0; i < 100; i++ {
+		_, err := c.Get(ctx, fmt.Sprintf("key%d", i))
+		assert.ErrorIs(t, err, cache.ErrNotFound)
+	}
+
+	// Other namespaces are left alone
+	val, err := other.Get(ctx, "key0")
+	assert.NoError(t, err)
+	assert.Equal(t, "role", val)
+
+	// The cache keeps working under the new version
+	assert.NoError(t, c.Set(ctx, "key0", "new", time.Hour))
+	val, err = c.Get(ctx, "key0")
+	assert.NoError(t, err)
+	assert.Equal(t, "new", val)
+
+	t.Run("lost version", func(t *testing.T) {
+		c := newTestCache(t, &Options{Namespace: "lost"}, servers[0])
+		assert.NoError(t, c.Set(ctx, "key", "value", time.Hour))
+		before, err := c.currentVersion()
+		assert.NoError(t, err)
+
+		// After an eviction of the version, a new one is started rather
+		// than the old entries coming back
+		assert.NoError(t, c.client.Delete(c.versionKey()))
+		servers[0].FastForward(time.Second)
+		assert.NoError(t, c.Clear(ctx))
+		after, err := c.currentVersion()
+		assert.NoError(t, err)
+		assert.Greater(t, after, before)
+
+		_, err = c.Get(ctx, "key")
+		assert.ErrorIs(t, err, cache.ErrNotFound)
+	})
+}
+
+func TestMemcachedCache_Version(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("cached for VersionTTL", func(t *testing.T) {
+		srv := newTestServer(t)
+		c := newTestCache(t, &Options{Namespace: "cached", VersionTTL: time.Second}, srv)
+		assert.NoError(t, c.Set(ctx, "key", "value", time.Hour))
+
+		before := srv.Commands()
+		for i := 0; i < 10; i++ {
+			_, err := c.Get(ctx, "key")
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, 10, srv.Commands()-before)
+
+		// Once stale, the version is fetched again
+		srv.FastForward(time.Second)
+		before = srv.Commands()
+		_, err := c.Get(ctx, "key")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, srv.Commands()-before)
+	})
+
+	t.Run("clears by other instances show within VersionTTL", func(t *testing.T) {
+		srv := newTestServer(t)
+		c := newTestCache(t, &Options{Namespace: "shared", VersionTTL: time.Second}, srv)
+		other := newTestCache(t, &Options{Namespace: "shared", VersionTTL: time.Second}, srv)
+		assert.NoError(t, c.Set(ctx, "key", "value", time.Hour))
+		_, err := other.Get(ctx, "key")
+		assert.NoError(t, err)
+
+		assert.NoError(t, c.Clear(ctx))
+		_, err = c.Get(ctx, "key")
+		assert.ErrorIs(t, err, cache.ErrNotFound)
+
+		srv.FastForward(time.Second)
+		_, err = other.Get(ctx, "key")
+		assert.ErrorIs(t, err, cache.ErrNotFound)
+	})
+
+	t.Run("unreachable version server is a miss", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		addr := ln.Addr().String()
+		ln.Close()
+
+		client, err := NewClient(addr)
+		assert.NoError(t, err)
+		c, err := New(client, nil)
+		assert.NoError(t, err)
+		defer c.Close()
+
+		_, err = c.Get(ctx, "key")
+		assert.ErrorIs(t, err, cache.ErrNotFound)
+		// Writes and invalidations can't pass for done
+		assert.Error(t, c.Set(ctx, "key", "value", 0))
+		assert.Error(t, c.Delete(ctx, "key"))
+	})
+}
+
+func TestRing(t *testing.T) {
+	servers := []string{"10.0.0.1:11211", "10.0.0.2:11211", "10.0.0.3:11211"}
+	ring, err := NewRing(servers...)
+	assert.NoError(t, err)
+
+	const keys = 30000
+	owners := make(map[string]string, keys)
+	counts := make(map[string]int)
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("key%d", i)
+		addr, err := ring.PickServer(key)
+		assert.NoError(t, err)
+		owners[key] = addr.String()
+		counts[addr.String()]++
+	}
+	assert.Len(t, counts, 3)
+	for server, n := range counts {
+		assert.InDelta(t, keys/3, n, keys/3*0.2, server)
+	}
+
+	// Adding a server takes its share from the others and moves nothing else
+	assert.NoError(t, ring.SetServers(append(servers, "10.0.0.4:11211")...))
+	moved := 0
+	for key, owner := range owners {
+		addr, err := ring.PickServer(key)
+		assert.NoError(t, err)
+		if addr.String() != owner {
+			assert.Equal(t, "10.0.0.4:11211", addr.String())
+			moved++
+		}
+	}
+	assert.InDelta(t, keys/4, moved, keys/4*0.2)
+
+	var each []string
+	assert.NoError(t, ring.Each(func(addr net.Addr) error {
+		each = append(each, addr.String())
+		return nil
+	}))
+	assert.Len(t, each, 4)
+
+	empty, err := NewRing()
+	assert.NoError(t, err)
+	_, err = empty.PickServer("key")
+	assert.ErrorIs(t, err, memcache.ErrNoServers)
+}
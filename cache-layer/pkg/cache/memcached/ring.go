@@ -0,0 +1,108 @@
+package memcached
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// pointsPerServer is the number of points each server gets on the ring;
+// more points spread keys more evenly
+const pointsPerServer = 160
+
+// Ring is a memcache.ServerSelector placing servers and keys on a
+// consistent hash ring (ketama), so that adding or removing a server only
+// moves the keys of its neighbours instead of nearly all of them
+type Ring struct {
+	mu      sync.RWMutex
+	points  []uint32
+	servers map[uint32]net.Addr
+	addrs   []net.Addr
+}
+
+// NewRing creates a ring of the given servers, each a host:port or the path
+// of a unix socket
+func NewRing(servers ...string) (*Ring, error) {
+	r := &Ring{}
+	if err := r.SetServers(servers...); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// SetServers replaces the servers of the ring
+func (r *Ring) SetServers(servers ...string) error {
+	addrs := make([]net.Addr, 0, len(servers))
+	points := make([]uint32, 0, len(servers)*pointsPerServer)
+	owners := make(map[uint32]net.Addr, len(servers)*pointsPerServer)
+
+	for _, server := range servers {
+		var addr net.Addr
+		var err error
+		if strings.Contains(server, "/") {
+			addr, err = net.ResolveUnixAddr("unix", server)
+		} else {
+			addr, err = net.ResolveTCPAddr("tcp", server)
+		}
+		if err != nil {
+			return err
+		}
+		addrs = append(addrs, addr)
+
+		// Each digest yields four points, as in libketama
+		for i := 0; i < pointsPerServer/4; i++ {
+			digest := md5.Sum([]byte(server + "-" + strconv.Itoa(i)))
+			for j := 0; j < 4; j++ {
+				p := binary.LittleEndian.Uint32(digest[j*4:])
+				if _, taken := owners[p]; !taken {
+					owners[p] = addr
+					points = append(points, p)
+				}
+			}
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i] < points[j] })
+
+	r.mu.Lock()
+	r.points, r.servers, r.addrs = points, owners, addrs
+	r.mu.Unlock()
+	return nil
+}
+
+// PickServer implements memcache.ServerSelector by returning the server of
+// the first point at or after the hash of key
+func (r *Ring) PickServer(key string) (net.Addr, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.points) == 0 {
+		return nil, memcache.ErrNoServers
+	}
+
+	digest := md5.Sum([]byte(key))
+	h := binary.LittleEndian.Uint32(digest[:])
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.servers[r.points[i]], nil
+}
+
+// Each implements memcache.ServerSelector
+func (r *Ring) Each(f func(net.Addr) error) error {
+	r.mu.RLock()
+	addrs := r.addrs
+	r.mu.RUnlock()
+
+	for _, addr := range addrs {
+		if err := f(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
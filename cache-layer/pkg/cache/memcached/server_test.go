@@ -0,0 +1,211 @@
+package memcached
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testServer is a memcached stand-in speaking the text protocol commands the
+// client uses, with a clock the tests move forward
+type testServer struct {
+	ln net.Listener
+
+	mu    sync.Mutex
+	items map[string]testItem
+	now   time.Time
+	cas   uint64
+	// commands counts the commands served
+	commands int
+}
+
+type testItem struct {
+	value   []byte
+	flags   uint32
+	expires time.Time // zero for never
+	cas     uint64
+}
+
+func newTestServer(t *testing.T) *testServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testServer{ln: ln, items: make(map[string]testItem), now: time.Now()}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *testServer) Addr() string { return s.ln.Addr().String() }
+
+// FastForward moves the clock of the server forward by d
+func (s *testServer) FastForward(d time.Duration) {
+	s.mu.Lock()
+	s.now = s.now.Add(d)
+	s.mu.Unlock()
+}
+
+// Now returns the time on the server clock
+func (s *testServer) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.now
+}
+
+// Commands returns the number of commands served so far
+func (s *testServer) Commands() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.commands
+}
+
+// Len returns the number of live items
+func (s *testServer) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for key := range s.items {
+		if _, ok := s.lookup(key); ok {
+			n++
+		}
+	}
+	return n
+}
+
+// lookup returns the item of key unless it expired; callers must hold s.mu
+func (s *testServer) lookup(key string) (testItem, bool) {
+	item, ok := s.items[key]
+	if ok && !item.expires.IsZero() && !s.now.Before(item.expires) {
+		delete(s.items, key)
+		return testItem{}, false
+	}
+	return item, ok
+}
+
+// expires interprets an expiration time the way memcached does
+func (s *testServer) expires(exptime int64) time.Time {
+	switch {
+	case exptime == 0:
+		return time.Time{}
+	case exptime < 0:
+		return s.now
+	case exptime <= int64(maxRelativeTTL/time.Second):
+		return s.now.Add(time.Duration(exptime) * time.Second)
+	default:
+		return time.Unix(exptime, 0)
+	}
+}
+
+func (s *testServer) serve(conn net.Conn) {
+	defer conn.Close()
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if err := s.handle(rw, fields); err != nil {
+			return
+		}
+		if err := rw.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (s *testServer) handle(rw *bufio.ReadWriter, fields []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands++
+
+	switch cmd := fields[0]; cmd {
+	case "get", "gets":
+		for _, key := range fields[1:] {
+			if item, ok := s.lookup(key); ok {
+				fmt.Fprintf(rw, "VALUE %s %d %d %d\r\n%s\r\n", key, item.flags, len(item.value), item.cas, item.value)
+			}
+		}
+		rw.WriteString("END\r\n")
+	case "set", "add", "replace":
+		if len(fields) != 5 {
+			rw.WriteString("ERROR\r\n")
+			return nil
+		}
+		flags, _ := strconv.ParseUint(fields[2], 10, 32)
+		exptime, _ := strconv.ParseInt(fields[3], 10, 64)
+		size, _ := strconv.Atoi(fields[4])
+		value := make([]byte, size+2)
+		if _, err := io.ReadFull(rw, value); err != nil {
+			return err
+		}
+
+		key := fields[1]
+		_, exists := s.lookup(key)
+		if (cmd == "add" && exists) || (cmd == "replace" && !exists) {
+			rw.WriteString("NOT_STORED\r\n")
+			return nil
+		}
+		s.cas++
+		s.items[key] = testItem{value: value[:size], flags: uint32(flags), expires: s.expires(exptime), cas: s.cas}
+		rw.WriteString("STORED\r\n")
+	case "delete":
+		if _, ok := s.lookup(fields[1]); !ok {
+			rw.WriteString("NOT_FOUND\r\n")
+			return nil
+		}
+		delete(s.items, fields[1])
+		rw.WriteString("DELETED\r\n")
+	case "incr", "decr":
+		item, ok := s.lookup(fields[1])
+		if !ok {
+			rw.WriteString("NOT_FOUND\r\n")
+			return nil
+		}
+		n, err := strconv.ParseUint(string(item.value), 10, 64)
+		if err != nil {
+			rw.WriteString("CLIENT_ERROR cannot increment or decrement non-numeric value\r\n")
+			return nil
+		}
+		delta, _ := strconv.ParseUint(fields[2], 10, 64)
+		if cmd == "incr" {
+			n += delta
+		} else if delta > n {
+			n = 0
+		} else {
+			n -= delta
+		}
+		s.cas++
+		item.value, item.cas = []byte(strconv.FormatUint(n, 10)), s.cas
+		s.items[fields[1]] = item
+		fmt.Fprintf(rw, "%d\r\n", n)
+	case "flush_all":
+		s.items = make(map[string]testItem)
+		rw.WriteString("OK\r\n")
+	case "version":
+		rw.WriteString("VERSION test\r\n")
+	default:
+		rw.WriteString("ERROR\r\n")
+	}
+	return nil
+}
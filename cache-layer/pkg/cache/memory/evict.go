@@ -0,0 +1,108 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+)
+
+// eviction is a queued call of the eviction callbacks
+type eviction struct {
+	key    string
+	value  interface{}
+	reason cache.EvictionReason
+}
+
+// evictDispatcher runs eviction callbacks on its own goroutine, so that the
+// caches can report evictions while holding their locks. The queue is
+// unbounded so that no eviction is ever dropped.
+type evictDispatcher struct {
+	mu      sync.Mutex
+	fns     []cache.EvictFunc
+	queue   []eviction
+	running bool
+	closed  bool
+	wake    chan struct{}
+	done    chan struct{}
+}
+
+func newEvictDispatcher() *evictDispatcher {
+	return &evictDispatcher{
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+}
+
+// add registers a callback, starting the dispatching goroutine on first use
+func (d *evictDispatcher) add(fn cache.EvictFunc) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.fns = append(d.fns, fn)
+	if !d.running && !d.closed {
+		d.running = true
+		go d.run()
+	}
+}
+
+// enabled reports whether any callback is registered
+func (d *evictDispatcher) enabled() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.fns) > 0 && !d.closed
+}
+
+// emit queues an eviction; it is a no-op without callbacks
+func (d *evictDispatcher) emit(key string, value interface{}, reason cache.EvictionReason) {
+	d.mu.Lock()
+	if len(d.fns) == 0 || d.closed {
+		d.mu.Unlock()
+		return
+	}
+	d.queue = append(d.queue, eviction{key: key, value: value, reason: reason})
+	d.mu.Unlock()
+
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// close delivers the queued evictions and stops the dispatching goroutine
+func (d *evictDispatcher) close() {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+	d.closed = true
+	running := d.running
+	d.mu.Unlock()
+
+	if running {
+		close(d.wake)
+		<-d.done
+	}
+}
+
+func (d *evictDispatcher) run() {
+	defer close(d.done)
+
+	for {
+		_, ok := <-d.wake
+
+		d.mu.Lock()
+		queue, fns := d.queue, d.fns
+		d.queue = nil
+		d.mu.Unlock()
+
+		for _, ev := range queue {
+			for _, fn := range fns {
+				fn(ev.key, ev.value, ev.reason)
+			}
+		}
+		if !ok {
+			return
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+// evictRecorder collects the evictions reported to it
+type evictRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *evictRecorder) record(key string, value interface{}, reason cache.EvictionReason) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, key+"="+value.(string)+":"+reason.String())
+}
+
+// wait returns the recorded evictions, sorted, once n of them arrived
+func (r *evictRecorder) wait(t *testing.T, n int) []string {
+	t.Helper()
+	assert.Eventually(t, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return len(r.events) >= n
+	}, time.Second, time.Millisecond)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events := append([]string(nil), r.events...)
+	r.events = nil
+	sort.Strings(events)
+	return events
+}
+
+func TestMemoryCache_OnEvict(t *testing.T) {
+	c, err := New(&cache.Options{DefaultTTL: time.Minute, MaxTTL: time.Minute, MaxSize: 2})
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	r := &evictRecorder{}
+	c.(cache.EvictNotifier).OnEvict(r.record)
+
+	t.Run("capacity", func(t *testing.T) {
+		assert.NoError(t, c.Set(ctx, "a", "1", 0))
+		time.Sleep(time.Millisecond)
+		assert.NoError(t, c.Set(ctx, "b", "2", 0))
+		assert.NoError(t, c.Set(ctx, "c", "3", 0))
+		assert.Equal(t, []string{"a=1:capacity"}, r.wait(t, 1))
+	})
+
+	t.Run("deleted", func(t *testing.T) {
+		assert.NoError(t, c.Delete(ctx, "b"))
+		// Deleting a missing key evicts nothing
+		assert.NoError(t, c.Delete(ctx, "b"))
+		assert.Equal(t, []string{"b=2:deleted"}, r.wait(t, 1))
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		assert.NoError(t, c.Set(ctx, "d", "4", 10*time.Millisecond))
+		time.Sleep(20 * time.Millisecond)
+		v, err := c.Get(ctx, "d")
+		assert.NoError(t, err)
+		assert.Nil(t, v)
+		assert.Equal(t, []string{"d=4:expired"}, r.wait(t, 1))
+	})
+
+	t.Run("cleared", func(t *testing.T) {
+		assert.NoError(t, c.Set(ctx, "e", "5", 0))
+		assert.NoError(t, c.Clear(ctx))
+		assert.Equal(t, []string{"c=3:cleared", "e=5:cleared"}, r.wait(t, 2))
+	})
+
+	t.Run("callbacks may use the cache", func(t *testing.T) {
+		done := make(chan struct{})
+		c.(cache.EvictNotifier).OnEvict(func(key string, value interface{}, reason cache.EvictionReason) {
+			if key == "f" {
+				_ = c.Set(ctx, "g", "7", 0)
+				close(done)
+			}
+		})
+		assert.NoError(t, c.Set(ctx, "f", "6", 0))
+		assert.NoError(t, c.Delete(ctx, "f"))
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("callback deadlocked")
+		}
+		r.wait(t, 1)
+	})
+
+	// Close delivers the evictions still queued
+	assert.NoError(t, c.Set(ctx, "h", "8", 0))
+	assert.NoError(t, c.Close())
+	r.mu.Lock()
+	assert.Contains(t, r.events, "h=8:cleared")
+	r.mu.Unlock()
+}
+
+func TestGoCacheWrapper_OnEvict(t *testing.T) {
+	c := NewGoCacheWrapper(&cache.Options{DefaultTTL: time.Minute})
+	ctx := context.Background()
+
+	r := &evictRecorder{}
+	c.(cache.EvictNotifier).OnEvict(r.record)
+
+	assert.NoError(t, c.Set(ctx, "a", "1", 0))
+	assert.NoError(t, c.Delete(ctx, "a"))
+	assert.Equal(t, []string{"a=1:deleted"}, r.wait(t, 1))
+
+	// Expired items are reported when the janitor removes them
+	assert.NoError(t, c.Set(ctx, "b", "2", time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	c.(*goCacheWrapper).cache.DeleteExpired()
+	assert.Equal(t, []string{"b=2:expired"}, r.wait(t, 1))
+
+	assert.NoError(t, c.Set(ctx, "c", "3", 0))
+	assert.NoError(t, c.Set(ctx, "d", "4", 0))
+	assert.NoError(t, c.Clear(ctx))
+	assert.Equal(t, []string{"c=3:cleared", "d=4:cleared"}, r.wait(t, 2))
+
+	assert.NoError(t, c.Close())
+}
@@ -0,0 +1,114 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+)
+
+// Keys implements cache.KeyLister; the cursor is the last key returned
+func (c *memoryCache) Keys(ctx context.Context, pattern, cursor string, limit int) (cache.KeyPage, error) {
+	if err := ctx.Err(); err != nil {
+		return cache.KeyPage{}, err
+	}
+
+	c.mu.RLock()
+	keys := make([]string, 0, len(c.entries))
+	for key, entry := range c.entries {
+		if !entry.IsExpired() {
+			keys = append(keys, key)
+		}
+	}
+	c.mu.RUnlock()
+
+	return keyPage(keys, pattern, cursor, limit), nil
+}
+
+// Inspect implements cache.Inspector
+func (c *memoryCache) Inspect(ctx context.Context, key string) (cache.EntryInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return cache.EntryInfo{}, err
+	}
+
+	c.mu.RLock()
+	entry, exists := c.entries[key]
+	c.mu.RUnlock()
+	if !exists || entry.IsExpired() {
+		return cache.EntryInfo{}, cache.ErrNotFound
+	}
+
+	return cache.EntryInfo{
+		Key:       key,
+		Value:     entry.Value,
+		Size:      sizeOf(entry.Value),
+		ExpiresAt: entry.Created.Add(entry.TTL),
+	}, nil
+}
+
+// Keys implements cache.KeyLister; the cursor is the last key returned
+func (c *goCacheWrapper) Keys(ctx context.Context, pattern, cursor string, limit int) (cache.KeyPage, error) {
+	if err := ctx.Err(); err != nil {
+		return cache.KeyPage{}, err
+	}
+
+	items := c.cache.Items()
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	return keyPage(keys, pattern, cursor, limit), nil
+}
+
+// Inspect implements cache.Inspector
+func (c *goCacheWrapper) Inspect(ctx context.Context, key string) (cache.EntryInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return cache.EntryInfo{}, err
+	}
+
+	value, expiresAt, found := c.cache.GetWithExpiration(key)
+	if !found {
+		return cache.EntryInfo{}, cache.ErrNotFound
+	}
+	return cache.EntryInfo{
+		Key:       key,
+		Value:     value,
+		Size:      sizeOf(value),
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// keyPage returns the page of the sorted keys matching pattern that follows
+// cursor
+func keyPage(keys []string, pattern, cursor string, limit int) cache.KeyPage {
+	sort.Strings(keys)
+	start := sort.SearchStrings(keys, cursor)
+	if start < len(keys) && keys[start] == cursor {
+		start++
+	}
+
+	page := cache.KeyPage{Keys: []string{}}
+	for _, key := range keys[start:] {
+		if !cache.Match(pattern, key) {
+			continue
+		}
+		if limit > 0 && len(page.Keys) == limit {
+			page.Cursor = page.Keys[len(page.Keys)-1]
+			break
+		}
+		page.Keys = append(page.Keys, key)
+	}
+	return page
+}
+
+// sizeOf returns the size of the values whose size is known: byte slices
+// and strings, as stored by the GORM plugin
+func sizeOf(value interface{}) int64 {
+	switch v := value.(type) {
+	case []byte:
+		return int64(len(v))
+	case string:
+		return int64(len(v))
+	}
+	return -1
+}
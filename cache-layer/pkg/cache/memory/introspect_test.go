@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntrospection(t *testing.T) {
+	ctx := context.Background()
+	options := &cache.Options{DefaultTTL: time.Minute, MaxTTL: time.Minute}
+	backends := map[string]func() cache.Cache{
+		"memory":  func() cache.Cache { c, _ := New(options); return c },
+		"gocache": func() cache.Cache { return NewGoCacheWrapper(options) },
+	}
+
+	for name, newCache := range backends {
+		t.Run(name, func(t *testing.T) {
+			c := newCache()
+			defer c.Close()
+			for i := 0; i < 5; i++ {
+				assert.NoError(t, c.Set(ctx, fmt.Sprintf("users:%d", i), []byte("payload"), 0))
+			}
+			assert.NoError(t, c.Set(ctx, "roles:0", "admin", 0))
+
+			lister := c.(cache.KeyLister)
+			page, err := lister.Keys(ctx, "users:*", "", 2)
+			assert.NoError(t, err)
+			assert.Equal(t, cache.KeyPage{Keys: []string{"users:0", "users:1"}, Cursor: "users:1"}, page)
+			page, err = lister.Keys(ctx, "users:*", page.Cursor, 2)
+			assert.NoError(t, err)
+			assert.Equal(t, cache.KeyPage{Keys: []string{"users:2", "users:3"}, Cursor: "users:3"}, page)
+			page, err = lister.Keys(ctx, "users:*", page.Cursor, 2)
+			assert.NoError(t, err)
+			assert.Equal(t, cache.KeyPage{Keys: []string{"users:4"}}, page)
+
+			page, err = lister.Keys(ctx, "", "", 0)
+			assert.NoError(t, err)
+			assert.Len(t, page.Keys, 6)
+
+			inspector := c.(cache.Inspector)
+			before := time.Now()
+			info, err := inspector.Inspect(ctx, "users:0")
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("payload"), info.Value)
+			assert.Equal(t, int64(7), info.Size)
+			assert.WithinDuration(t, before.Add(time.Minute), info.ExpiresAt, time.Second)
+
+			_, err = inspector.Inspect(ctx, "missing")
+			assert.ErrorIs(t, err, cache.ErrNotFound)
+
+			// Inspecting is not a read
+			stats, err := c.(cache.StatsProvider).Stats(ctx)
+			assert.NoError(t, err)
+			assert.Zero(t, stats.Hits+stats.Misses)
+		})
+	}
+}
@@ -3,6 +3,7 @@ package memory
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/seokheejang/go/cache-layer/pkg/cache"
@@ -12,6 +13,9 @@ type memoryCache struct {
 	mu      sync.RWMutex
 	entries map[string]*cache.Entry
 	options *cache.Options
+	evicted *evictDispatcher
+
+	hits, misses, sets, deletes, evictions atomic.Uint64
 }
 
 // New creates a new memory cache instance
@@ -27,30 +31,50 @@ func New(options *cache.Options) (cache.Cache, error) {
 	return &memoryCache{
 		entries: make(map[string]*cache.Entry),
 		options: options,
+		evicted: newEvictDispatcher(),
 	}, nil
 }
 
 func (c *memoryCache) Get(ctx context.Context, key string) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	c.mu.RLock()
 	entry, exists := c.entries[key]
 	c.mu.RUnlock()
 
 	if !exists {
+		c.misses.Add(1)
 		return nil, nil
 	}
 
 	// Check if the entry has expired
 	if entry.IsExpired() {
 		c.mu.Lock()
-		delete(c.entries, key)
+		// Another reader may have removed or replaced it in the meantime
+		current := c.entries[key] == entry
+		if current {
+			delete(c.entries, key)
+		}
 		c.mu.Unlock()
+		c.misses.Add(1)
+		if current {
+			c.evictions.Add(1)
+			c.evicted.emit(key, entry.Value, cache.EvictExpired)
+		}
 		return nil, nil
 	}
 
+	c.hits.Add(1)
 	return entry.Value, nil
 }
 
 func (c *memoryCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	if ttl == 0 {
 		ttl = c.options.DefaultTTL
 	}
@@ -61,35 +85,65 @@ func (c *memoryCache) Set(ctx context.Context, key string, value interface{}, tt
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	// Check if we need to evict entries
-	if int64(len(c.entries)) >= c.options.MaxSize {
+	// Check if we need to evict entries; overwriting one takes no room and
+	// a zero MaxSize means no limit
+	if _, exists := c.entries[key]; !exists && c.options.MaxSize > 0 && int64(len(c.entries)) >= c.options.MaxSize {
 		c.evictOldest()
 	}
 
 	c.entries[key] = cache.NewEntry(key, value, ttl)
+	c.sets.Add(1)
 	return nil
 }
 
 func (c *memoryCache) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	c.mu.Lock()
+	entry, exists := c.entries[key]
 	delete(c.entries, key)
 	c.mu.Unlock()
+	c.deletes.Add(1)
+
+	if exists {
+		c.evicted.emit(key, entry.Value, cache.EvictDeleted)
+	}
 	return nil
 }
 
 func (c *memoryCache) Clear(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	c.mu.Lock()
+	entries := c.entries
 	c.entries = make(map[string]*cache.Entry)
 	c.mu.Unlock()
+
+	if c.evicted.enabled() {
+		for key, entry := range entries {
+			c.evicted.emit(key, entry.Value, cache.EvictCleared)
+		}
+	}
 	return nil
 }
 
 func (c *memoryCache) Close() error {
 	c.Clear(context.Background())
+	c.evicted.close()
 	return nil
 }
 
-// evictOldest removes the oldest entry from the cache
+// OnEvict implements cache.EvictNotifier
+func (c *memoryCache) OnEvict(fn cache.EvictFunc) {
+	c.evicted.add(fn)
+}
+
+// evictOldest removes the oldest entry from the cache. The eviction is only
+// queued for the callbacks, so it is safe to call with c.mu held.
 func (c *memoryCache) evictOldest() {
 	var oldestKey string
 	var oldestTime time.Time
@@ -102,6 +156,29 @@ func (c *memoryCache) evictOldest() {
 	}
 
 	if oldestKey != "" {
+		value := c.entries[oldestKey].Value
 		delete(c.entries, oldestKey)
+		c.evictions.Add(1)
+		c.evicted.emit(oldestKey, value, cache.EvictCapacity)
 	}
 }
+
+// Stats implements cache.StatsProvider
+func (c *memoryCache) Stats(ctx context.Context) (cache.Stats, error) {
+	if err := ctx.Err(); err != nil {
+		return cache.Stats{}, err
+	}
+
+	c.mu.RLock()
+	entries := len(c.entries)
+	c.mu.RUnlock()
+
+	return cache.Stats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Sets:      c.sets.Load(),
+		Deletes:   c.deletes.Load(),
+		Evictions: c.evictions.Load(),
+		Entries:   int64(entries),
+	}, nil
+}
@@ -2,57 +2,276 @@ package memory
 
 import (
 	"context"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	gocache "github.com/patrickmn/go-cache"
 	"github.com/seokheejang/go/cache-layer/pkg/cache"
 )
 
+// defaultCleanupInterval is the janitor interval used when none is configured
+const defaultCleanupInterval = 5 * time.Second
+
 type goCacheWrapper struct {
-	cache *gocache.Cache
+	cache   *gocache.Cache
+	options cache.Options
+	evicted *evictDispatcher
+
+	// setMu serializes Set when MaxSize is enforced, so that concurrent
+	// writers can't overshoot it
+	setMu ctxMutex
+
+	// removing tracks the removals in progress per key, telling the go-cache
+	// OnEvicted callback deletions and capacity evictions apart from expirations
+	removingMu sync.Mutex
+	removing   map[string]*removal
+
+	// go-cache's own janitor is only stopped by a finalizer, which never
+	// runs because OnEvicted points back at the wrapper, so we run our own
+	stopJanitor chan struct{}
+	janitorDone chan struct{}
+	closeOnce   sync.Once
+
+	hits, misses, sets, deletes, evictions atomic.Uint64
+}
+
+// ctxMutex is a mutex whose acquisition is abandoned once a context is done
+type ctxMutex chan struct{}
+
+func (m ctxMutex) lock(ctx context.Context) error {
+	select {
+	case m <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m ctxMutex) unlock() {
+	<-m
+}
+
+// removal is a removal in progress started by the wrapper itself
+type removal struct {
+	reason cache.EvictionReason
+	n      int
 }
 
+// NewGoCacheWrapper creates a memory cache backed by go-cache. Nil options
+// get the same defaults as New. A zero MaxTTL or MaxSize means no limit, and
+// expired entries are removed every CleanupInterval.
 func NewGoCacheWrapper(options *cache.Options) cache.Cache {
-	defaultTTL := options.DefaultTTL
-	cleanupInterval := 5 * time.Second // static janitor interval
+	if options == nil {
+		options = &cache.Options{
+			DefaultTTL: 2 * time.Second,
+			MaxTTL:     5 * time.Second,
+			MaxSize:    1000,
+		}
+	}
+	cleanupInterval := options.CleanupInterval
+	if cleanupInterval <= 0 {
+		cleanupInterval = defaultCleanupInterval
+	}
 
-	return &goCacheWrapper{
-		cache: gocache.New(defaultTTL, cleanupInterval),
+	c := &goCacheWrapper{
+		cache:       gocache.New(options.DefaultTTL, 0),
+		options:     *options,
+		evicted:     newEvictDispatcher(),
+		setMu:       make(ctxMutex, 1),
+		removing:    make(map[string]*removal),
+		stopJanitor: make(chan struct{}),
+		janitorDone: make(chan struct{}),
 	}
+	c.cache.OnEvicted(c.onEvicted)
+	go c.janitor(cleanupInterval)
+	return c
 }
 
-func (c *goCacheWrapper) Get(_ context.Context, key string) (interface{}, error) {
+// janitor removes expired items every interval until Close
+func (c *goCacheWrapper) janitor(interval time.Duration) {
+	defer close(c.janitorDone)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.cache.DeleteExpired()
+		case <-c.stopJanitor:
+			return
+		}
+	}
+}
+
+func (c *goCacheWrapper) Get(ctx context.Context, key string) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	val, found := c.cache.Get(key)
 	if !found {
+		c.misses.Add(1)
 		return nil, cache.ErrNotFound
 	}
 
+	c.hits.Add(1)
 	return val, nil
 }
 
-func (c *goCacheWrapper) Set(_ context.Context, key string, value interface{}, ttl time.Duration) error {
+func (c *goCacheWrapper) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	if ttl == 0 {
-		ttl = gocache.DefaultExpiration
+		ttl = c.options.DefaultTTL
+	}
+	if c.options.MaxTTL > 0 && (ttl > c.options.MaxTTL || ttl <= 0) {
+		ttl = c.options.MaxTTL
+	}
+	if ttl == 0 {
+		ttl = gocache.NoExpiration
+	}
+
+	if c.options.MaxSize > 0 {
+		// Making room scans the whole cache, so waiting writers may pile up
+		if err := c.setMu.lock(ctx); err != nil {
+			return err
+		}
+		defer c.setMu.unlock()
+
+		if _, found := c.cache.Get(key); !found && int64(c.cache.ItemCount()) >= c.options.MaxSize {
+			c.makeRoom()
+		}
 	}
 
 	c.cache.Set(key, value, ttl)
+	c.sets.Add(1)
 
 	return nil
 }
 
-func (c *goCacheWrapper) Delete(_ context.Context, key string) error {
-	c.cache.Delete(key)
+func (c *goCacheWrapper) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.remove(key, cache.EvictDeleted)
+	c.deletes.Add(1)
 
 	return nil
 }
 
-func (c *goCacheWrapper) Clear(_ context.Context) error {
+func (c *goCacheWrapper) Clear(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Flush doesn't call OnEvicted, so report the flushed items ourselves
+	var items map[string]gocache.Item
+	if c.evicted.enabled() {
+		items = c.cache.Items()
+	}
 	c.cache.Flush()
 
+	for key, item := range items {
+		c.evicted.emit(key, item.Object, cache.EvictCleared)
+	}
 	return nil
 }
 
 func (c *goCacheWrapper) Close() error {
-	// go-cache doesn't require Close()
+	c.closeOnce.Do(func() {
+		close(c.stopJanitor)
+		<-c.janitorDone
+		c.evicted.close()
+	})
 	return nil
 }
+
+// OnEvict implements cache.EvictNotifier
+func (c *goCacheWrapper) OnEvict(fn cache.EvictFunc) {
+	c.evicted.add(fn)
+}
+
+// makeRoom removes the expired items, and if the cache is still full, the
+// item closest to expiring. go-cache doesn't keep insertion times, so items
+// without expiration are evicted last.
+func (c *goCacheWrapper) makeRoom() {
+	c.cache.DeleteExpired()
+	if int64(c.cache.ItemCount()) < c.options.MaxSize {
+		return
+	}
+
+	var victim string
+	var victimExpiration int64
+	for key, item := range c.cache.Items() {
+		expiration := item.Expiration
+		if expiration <= 0 {
+			expiration = 1<<63 - 1
+		}
+		if victim == "" || expiration < victimExpiration {
+			victim, victimExpiration = key, expiration
+		}
+	}
+
+	if victim != "" {
+		c.remove(victim, cache.EvictCapacity)
+		c.evictions.Add(1)
+	}
+}
+
+// remove deletes key, reporting the removal with reason
+func (c *goCacheWrapper) remove(key string, reason cache.EvictionReason) {
+	c.removingMu.Lock()
+	r, ok := c.removing[key]
+	if !ok {
+		r = &removal{}
+		c.removing[key] = r
+	}
+	r.reason = reason
+	r.n++
+	c.removingMu.Unlock()
+
+	// go-cache calls onEvicted from Delete, on this goroutine
+	c.cache.Delete(key)
+
+	c.removingMu.Lock()
+	if r.n--; r.n == 0 {
+		delete(c.removing, key)
+	}
+	c.removingMu.Unlock()
+}
+
+// onEvicted bridges go-cache's OnEvicted, which is called both by Delete and
+// by the janitor removing expired items
+func (c *goCacheWrapper) onEvicted(key string, value interface{}) {
+	reason := cache.EvictExpired
+	c.removingMu.Lock()
+	if r, ok := c.removing[key]; ok {
+		reason = r.reason
+	}
+	c.removingMu.Unlock()
+
+	if reason == cache.EvictExpired {
+		c.evictions.Add(1)
+	}
+	c.evicted.emit(key, value, reason)
+}
+
+// Stats implements cache.StatsProvider
+func (c *goCacheWrapper) Stats(ctx context.Context) (cache.Stats, error) {
+	if err := ctx.Err(); err != nil {
+		return cache.Stats{}, err
+	}
+
+	return cache.Stats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Sets:      c.sets.Load(),
+		Deletes:   c.deletes.Load(),
+		Evictions: c.evictions.Load(),
+		Entries:   int64(c.cache.ItemCount()),
+	}, nil
+}
@@ -2,10 +2,12 @@ package memory
 
 import (
 	"context"
+	"runtime"
 	"testing"
 	"time"
 
 	cachepkg "github.com/seokheejang/go/cache-layer/pkg/cache"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/cachetest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -165,3 +167,79 @@ func TestGoCacheWrapper(t *testing.T) {
 		assert.Nil(t, result2)
 	})
 }
+
+func TestGoCacheWrapper_Contract(t *testing.T) {
+	cachetest.Run(t, cachetest.Suite{
+		New: func(t *testing.T, options *cachepkg.Options) cachepkg.Cache {
+			return NewGoCacheWrapper(options)
+		},
+		Bounded: true,
+	})
+}
+
+func TestGoCacheWrapper_Options(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("nil options get defaults", func(t *testing.T) {
+		c := NewGoCacheWrapper(nil)
+		defer c.Close()
+		assert.NoError(t, c.Set(ctx, "key", "value", 0))
+		assert.Equal(t, cachepkg.Options{DefaultTTL: 2 * time.Second, MaxTTL: 5 * time.Second, MaxSize: 1000}, c.(*goCacheWrapper).options)
+	})
+
+	t.Run("capacity evictions", func(t *testing.T) {
+		c := NewGoCacheWrapper(&cachepkg.Options{DefaultTTL: time.Hour, MaxSize: 2})
+		defer c.Close()
+		r := &evictRecorder{}
+		c.(cachepkg.EvictNotifier).OnEvict(r.record)
+
+		assert.NoError(t, c.Set(ctx, "a", "1", time.Minute))
+		assert.NoError(t, c.Set(ctx, "b", "2", 0))
+		assert.NoError(t, c.Set(ctx, "c", "3", 0))
+		// The entry closest to expiring goes first
+		assert.Equal(t, []string{"a=1:capacity"}, r.wait(t, 1))
+
+		stats, err := c.(cachepkg.StatsProvider).Stats(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(1), stats.Evictions)
+		assert.Equal(t, int64(2), stats.Entries)
+	})
+
+	t.Run("lock waits stop when the context is done", func(t *testing.T) {
+		c := NewGoCacheWrapper(&cachepkg.Options{DefaultTTL: time.Hour, MaxSize: 1})
+		defer c.Close()
+		w := c.(*goCacheWrapper)
+
+		assert.NoError(t, w.setMu.lock(ctx))
+		timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, c.Set(timeout, "key", "value", 0), context.DeadlineExceeded)
+		w.setMu.unlock()
+
+		assert.NoError(t, c.Set(ctx, "key", "value", 0))
+	})
+
+	t.Run("cleanup interval", func(t *testing.T) {
+		c := NewGoCacheWrapper(&cachepkg.Options{DefaultTTL: 10 * time.Millisecond, CleanupInterval: 10 * time.Millisecond})
+		defer c.Close()
+		r := &evictRecorder{}
+		c.(cachepkg.EvictNotifier).OnEvict(r.record)
+
+		assert.NoError(t, c.Set(ctx, "a", "1", 0))
+		assert.Equal(t, []string{"a=1:expired"}, r.wait(t, 1))
+	})
+	t.Run("close stops the janitor", func(t *testing.T) {
+		before := runtime.NumGoroutine()
+		for i := 0; i < 20; i++ {
+			c := NewGoCacheWrapper(&cachepkg.Options{DefaultTTL: time.Minute})
+			c.(cachepkg.EvictNotifier).OnEvict(func(string, interface{}, cachepkg.EvictionReason) {})
+			assert.NoError(t, c.Set(ctx, "a", "1", 0))
+			assert.NoError(t, c.Close())
+			assert.NoError(t, c.Close())
+		}
+		for i := 0; i < 100 && runtime.NumGoroutine() > before; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		assert.LessOrEqual(t, runtime.NumGoroutine(), before)
+	})
+}
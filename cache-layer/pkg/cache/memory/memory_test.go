@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/cachetest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -232,3 +233,14 @@ func TestMemoryCache_ConcurrentAccess(t *testing.T) {
 		}
 	}
 }
+
+func TestMemoryCache_Contract(t *testing.T) {
+	cachetest.Run(t, cachetest.Suite{
+		New: func(t *testing.T, options *cache.Options) cache.Cache {
+			c, err := New(options)
+			assert.NoError(t, err)
+			return c
+		},
+		Bounded: true,
+	})
+}
@@ -0,0 +1,49 @@
+package netcache
+
+import (
+	"context"
+	"crypto/subtle"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TokenInterceptor rejects calls whose "authorization" metadata is not
+// "Bearer " followed by token. Install it with grpc.UnaryInterceptor when
+// the server is reachable by untrusted clients, since any client may read,
+// overwrite or clear the whole cache.
+func TokenInterceptor(token string) grpc.UnaryServerInterceptor {
+	want := []byte("Bearer " + token)
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get("authorization")
+		if len(values) != 1 || subtle.ConstantTimeCompare([]byte(values[0]), want) != 1 {
+			return nil, status.Error(codes.Unauthenticated, "netcache: invalid token")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// TokenCredentials sends token with every call, for servers using
+// TokenInterceptor; pass it to Dial with grpc.WithPerRPCCredentials. Unless
+// insecure is set, calls are refused over connections without TLS, so that
+// the token is not sent in the clear.
+func TokenCredentials(token string, insecure bool) credentials.PerRPCCredentials {
+	return tokenCredentials{token: token, insecure: insecure}
+}
+
+type tokenCredentials struct {
+	token    string
+	insecure bool
+}
+
+func (c tokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + c.token}, nil
+}
+
+func (c tokenCredentials) RequireTransportSecurity() bool {
+	return !c.insecure
+}
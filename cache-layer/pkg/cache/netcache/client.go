@@ -0,0 +1,211 @@
+package netcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	cachepb "github.com/seokheejang/go/cache-layer/pkg/cache/netcache/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrValueTooLarge is returned for values larger than ClientOptions.MaxValueSize
+var ErrValueTooLarge = errors.New("netcache: value too large")
+
+// ClientOptions represents the configuration options for a Client
+type ClientOptions struct {
+	// Timeout is the deadline of calls whose context has none (defaults
+	// to 1 second)
+	Timeout time.Duration
+	// MaxValueSize is the size of the largest value sent (defaults to
+	// DefaultMaxValueSize); it should not exceed the server's
+	MaxValueSize int
+}
+
+// Client is a cache.Cache stored in a remote Server
+type Client struct {
+	rpc     cachepb.CacheServiceClient
+	conn    *grpc.ClientConn // closed by Close when owned
+	options ClientOptions
+}
+
+// NewClient creates a client using conn, which the caller keeps ownership of
+func NewClient(conn grpc.ClientConnInterface, options *ClientOptions) *Client {
+	c := &Client{rpc: cachepb.NewCacheServiceClient(conn)}
+	if options != nil {
+		c.options = *options
+	}
+	if c.options.Timeout <= 0 {
+		c.options.Timeout = time.Second
+	}
+	if c.options.MaxValueSize <= 0 {
+		c.options.MaxValueSize = DefaultMaxValueSize
+	}
+	return c
+}
+
+// Dial creates a client connected to target; Close closes the connection
+func Dial(target string, options *ClientOptions, opts ...grpc.DialOption) (*Client, error) {
+	conn, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, err
+	}
+	c := NewClient(conn, options)
+	c.conn = conn
+	return c, nil
+}
+
+func (c *Client) Get(ctx context.Context, key string) (interface{}, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	resp, err := c.rpc.Get(ctx, &cachepb.GetRequest{Key: key})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	if !resp.Found {
+		return nil, cache.ErrNotFound
+	}
+	return decode(resp.Value)
+}
+
+func (c *Client) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	req, err := c.setRequest(key, value, ttl)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	_, err = c.rpc.Set(ctx, req)
+	return fromStatus(err)
+}
+
+func (c *Client) Delete(ctx context.Context, key string) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	_, err := c.rpc.Delete(ctx, &cachepb.DeleteRequest{Key: key})
+	return fromStatus(err)
+}
+
+func (c *Client) Clear(ctx context.Context) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	_, err := c.rpc.Clear(ctx, &cachepb.ClearRequest{})
+	return fromStatus(err)
+}
+
+// Close closes the connection if the client was created with Dial
+func (c *Client) Close() error {
+	if c.conn != nil {
+		return c.conn.Close()
+	}
+	return nil
+}
+
+// Stats implements cache.StatsProvider with the statistics of the remote cache
+func (c *Client) Stats(ctx context.Context) (cache.Stats, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	resp, err := c.rpc.Stats(ctx, &cachepb.StatsRequest{})
+	if err != nil {
+		return cache.Stats{}, fromStatus(err)
+	}
+	return cache.Stats{
+		Hits:      resp.Hits,
+		Misses:    resp.Misses,
+		Sets:      resp.Sets,
+		Deletes:   resp.Deletes,
+		Evictions: resp.Evictions,
+		Entries:   resp.Entries,
+	}, nil
+}
+
+// GetMany returns the values of the keys that were found in one call
+func (c *Client) GetMany(ctx context.Context, keys ...string) (map[string]interface{}, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	resp, err := c.rpc.GetMany(ctx, &cachepb.GetManyRequest{Keys: keys})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+
+	values := make(map[string]interface{}, len(resp.Entries))
+	for key, v := range resp.Entries {
+		value, err := decode(v)
+		if err != nil {
+			return nil, fmt.Errorf("netcache: failed to decode %q: %w", key, err)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// SetMany stores all values with the same TTL in one call
+func (c *Client) SetMany(ctx context.Context, values map[string]interface{}, ttl time.Duration) error {
+	req := &cachepb.SetManyRequest{Items: make([]*cachepb.SetRequest, 0, len(values))}
+	for key, value := range values {
+		item, err := c.setRequest(key, value, ttl)
+		if err != nil {
+			return err
+		}
+		req.Items = append(req.Items, item)
+	}
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	_, err := c.rpc.SetMany(ctx, req)
+	return fromStatus(err)
+}
+
+// DeleteMany removes all keys in one call
+func (c *Client) DeleteMany(ctx context.Context, keys ...string) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	_, err := c.rpc.DeleteMany(ctx, &cachepb.DeleteManyRequest{Keys: keys})
+	return fromStatus(err)
+}
+
+func (c *Client) setRequest(key string, value interface{}, ttl time.Duration) (*cachepb.SetRequest, error) {
+	v, err := encode(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(v.Data) > c.options.MaxValueSize {
+		return nil, fmt.Errorf("%w: %q is %d bytes, more than %d", ErrValueTooLarge, key, len(v.Data), c.options.MaxValueSize)
+	}
+	return &cachepb.SetRequest{Key: key, Value: v, TtlMillis: ttl.Milliseconds()}, nil
+}
+
+// withTimeout applies the default timeout to contexts without a deadline
+func (c *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.options.Timeout)
+}
+
+// fromStatus maps gRPC status errors back to the errors of the cache package
+func fromStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	switch status.Code(err) {
+	case codes.Canceled:
+		return fmt.Errorf("%w: %v", context.Canceled, err)
+	case codes.DeadlineExceeded:
+		return fmt.Errorf("%w: %v", context.DeadlineExceeded, err)
+	case codes.Unimplemented:
+		return fmt.Errorf("%w: %v", cache.ErrNotSupported, err)
+	}
+	return err
+}
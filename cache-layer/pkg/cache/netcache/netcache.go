@@ -0,0 +1,49 @@
+// Package netcache shares a cache.Cache between processes over gRPC. A
+// Server hosts any backend, and a Client implements cache.Cache on top of it,
+// so it can be used wherever a local backend is, such as with WithGormCache.
+//
+// The service is defined in proto/cache.proto; regenerate the code in proto
+// with protoc-gen-go and protoc-gen-go-grpc after changing it.
+package netcache
+
+import (
+	"encoding/json"
+	"fmt"
+
+	cachepb "github.com/seokheejang/go/cache-layer/pkg/cache/netcache/proto"
+)
+
+const (
+	// DefaultMaxValueSize is the size of the largest value accepted by default
+	DefaultMaxValueSize = 1 << 20
+	// DefaultMaxBatchSize is the number of keys a batch call may hold by default
+	DefaultMaxBatchSize = 1000
+)
+
+// encode turns a value into its wire form. []byte values, such as encoded
+// GORM query results, are sent verbatim, all others as JSON.
+func encode(value interface{}) (*cachepb.Value, error) {
+	if raw, ok := value.([]byte); ok {
+		return &cachepb.Value{Data: raw, Raw: true}, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return &cachepb.Value{Data: data}, nil
+}
+
+// decode is the inverse of encode
+func decode(v *cachepb.Value) (interface{}, error) {
+	if v == nil {
+		return nil, fmt.Errorf("netcache: missing value")
+	}
+	if v.Raw {
+		return v.Data, nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(v.Data, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
@@ -0,0 +1,232 @@
+package netcache
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/cachetest"
+	gormCache "github.com/seokheejang/go/cache-layer/pkg/cache/gorm"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/memory"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// serve hosts c on an in-process connection and returns a client for it
+func serve(t *testing.T, c cache.Cache, serverOptions *ServerOptions, clientOptions *ClientOptions) *Client {
+	lis := bufconn.Listen(1 << 20)
+	g := grpc.NewServer()
+	NewServer(c, serverOptions).Register(g)
+	go func() { _ = g.Serve(lis) }()
+	t.Cleanup(g.Stop)
+
+	client, err := Dial("passthrough:///bufconn", clientOptions,
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+// slowCache delays every call until its context is done
+type slowCache struct {
+	cache.Cache
+}
+
+func (s slowCache) Get(ctx context.Context, key string) (interface{}, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestClient_Contract(t *testing.T) {
+	cachetest.Run(t, cachetest.Suite{
+		New: func(t *testing.T, options *cache.Options) cache.Cache {
+			c, err := memory.New(options)
+			assert.NoError(t, err)
+			return serve(t, c, nil, nil)
+		},
+		Bounded: true,
+	})
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.NewGoCacheWrapper(&cache.Options{DefaultTTL: time.Minute})
+	client := serve(t, backend, &ServerOptions{MaxValueSize: 64, MaxBatchSize: 3}, nil)
+
+	t.Run("values", func(t *testing.T) {
+		raw := []byte{0, 1, 2}
+		assert.NoError(t, client.Set(ctx, "raw", raw, 0))
+		assert.NoError(t, client.Set(ctx, "json", map[string]interface{}{"n": 1}, 0))
+
+		v, err := client.Get(ctx, "raw")
+		assert.NoError(t, err)
+		assert.Equal(t, raw, v)
+		v, err = client.Get(ctx, "json")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"n": float64(1)}, v)
+
+		// The backend holds what a local user would have stored
+		v, err = backend.Get(ctx, "raw")
+		assert.NoError(t, err)
+		assert.Equal(t, raw, v)
+	})
+
+	t.Run("batches", func(t *testing.T) {
+		assert.NoError(t, client.SetMany(ctx, map[string]interface{}{"a": "1", "b": "2"}, 0))
+		values, err := client.GetMany(ctx, "a", "b", "missing")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"a": "1", "b": "2"}, values)
+
+		assert.NoError(t, client.DeleteMany(ctx, "a", "b"))
+		values, err = client.GetMany(ctx, "a", "b")
+		assert.NoError(t, err)
+		assert.Empty(t, values)
+
+		_, err = client.GetMany(ctx, "a", "b", "c", "d")
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("size limits", func(t *testing.T) {
+		big := strings.Repeat("x", 100)
+		err := client.Set(ctx, "big", []byte(big), 0)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		// Nothing of a batch is stored when one of its values is too large
+		err = client.SetMany(ctx, map[string]interface{}{"small": "x", "big": big}, 0)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		_, err = client.Get(ctx, "small")
+		assert.ErrorIs(t, err, cache.ErrNotFound)
+
+		limited := NewClient(nil, &ClientOptions{MaxValueSize: 8})
+		assert.ErrorIs(t, limited.Set(ctx, "big", big, 0), ErrValueTooLarge)
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		stats, err := client.Stats(ctx)
+		assert.NoError(t, err)
+		assert.Greater(t, stats.Sets, uint64(0))
+		assert.Greater(t, stats.Hits, uint64(0))
+
+		// Backends without statistics report them as not supported
+		other := serve(t, slowCache{backend}, nil, nil)
+		_, err = other.Stats(ctx)
+		assert.ErrorIs(t, err, cache.ErrNotSupported)
+	})
+}
+
+func TestClient_Deadlines(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.NewGoCacheWrapper(&cache.Options{DefaultTTL: time.Minute})
+
+	t.Run("client timeout", func(t *testing.T) {
+		client := serve(t, slowCache{backend}, nil, &ClientOptions{Timeout: 20 * time.Millisecond})
+		start := time.Now()
+		_, err := client.Get(ctx, "key")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), time.Second)
+
+		// The deadline of the context wins over the default timeout
+		deadline, cancel := context.WithTimeout(ctx, 5*time.Millisecond)
+		defer cancel()
+		_, err = client.Get(deadline, "key")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("server timeout", func(t *testing.T) {
+		client := serve(t, slowCache{backend}, &ServerOptions{Timeout: 20 * time.Millisecond}, &ClientOptions{Timeout: time.Minute})
+		_, err := client.Get(ctx, "key")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+type member struct {
+	ID   uint
+	Name string
+}
+
+func TestClient_GormCache(t *testing.T) {
+	backend, err := memory.New(&cache.Options{DefaultTTL: time.Minute, MaxTTL: time.Minute, MaxSize: 100})
+	assert.NoError(t, err)
+	client := serve(t, backend, nil, nil)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "netcache.db")), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.AutoMigrate(&member{}))
+	assert.NoError(t, db.Create(&member{Name: "admin"}).Error)
+	assert.NoError(t, gormCache.WithGormCache(db, client))
+
+	var m member
+	assert.NoError(t, db.First(&m, 1).Error)
+	// Raw updates don't invalidate the cache, so a stale name proves a hit
+	assert.NoError(t, db.Exec("UPDATE members SET name = 'root' WHERE id = 1").Error)
+	var cached member
+	assert.NoError(t, db.First(&cached, 1).Error)
+	assert.Equal(t, "admin", cached.Name)
+}
+
+func TestTokenInterceptor(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.NewGoCacheWrapper(&cache.Options{DefaultTTL: time.Minute})
+
+	lis := bufconn.Listen(1 << 20)
+	g := grpc.NewServer(grpc.UnaryInterceptor(TokenInterceptor("s3cret")))
+	NewServer(backend, nil).Register(g)
+	go func() { _ = g.Serve(lis) }()
+	t.Cleanup(g.Stop)
+
+	dial := func(opts ...grpc.DialOption) *Client {
+		opts = append(opts,
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				return lis.DialContext(ctx)
+			}),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		)
+		client, err := Dial("passthrough:///bufconn", nil, opts...)
+		assert.NoError(t, err)
+		t.Cleanup(func() { _ = client.Close() })
+		return client
+	}
+
+	t.Run("valid token", func(t *testing.T) {
+		client := dial(grpc.WithPerRPCCredentials(TokenCredentials("s3cret", true)))
+		assert.NoError(t, client.Set(ctx, "key", "value", 0))
+		v, err := client.Get(ctx, "key")
+		assert.NoError(t, err)
+		assert.Equal(t, "value", v)
+	})
+
+	t.Run("missing or wrong token", func(t *testing.T) {
+		for _, client := range []*Client{
+			dial(),
+			dial(grpc.WithPerRPCCredentials(TokenCredentials("wrong", true))),
+		} {
+			err := client.Clear(ctx)
+			assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		}
+		v, err := backend.Get(ctx, "key")
+		assert.NoError(t, err)
+		assert.Equal(t, "value", v, "rejected calls must not reach the cache")
+	})
+
+	t.Run("token requires TLS unless insecure", func(t *testing.T) {
+		_, err := Dial("passthrough:///bufconn", nil,
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithPerRPCCredentials(TokenCredentials("s3cret", false)),
+		)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,236 @@
+package netcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	cachepb "github.com/seokheejang/go/cache-layer/pkg/cache/netcache/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ServerOptions represents the configuration options for a Server
+type ServerOptions struct {
+	// MaxValueSize is the size of the largest value stored (defaults to
+	// DefaultMaxValueSize). Limit whole messages with grpc.MaxRecvMsgSize.
+	MaxValueSize int
+	// MaxBatchSize is the number of keys a batch call may hold (defaults to
+	// DefaultMaxBatchSize)
+	MaxBatchSize int
+	// Timeout bounds every call, whatever deadline the client asked for
+	// (defaults to 5 seconds)
+	Timeout time.Duration
+}
+
+// Server implements the CacheService on top of a cache.Cache
+type Server struct {
+	cachepb.UnimplementedCacheServiceServer
+
+	cache   cache.Cache
+	options ServerOptions
+}
+
+// NewServer creates a server for c; register it with Register
+func NewServer(c cache.Cache, options *ServerOptions) *Server {
+	s := &Server{cache: c}
+	if options != nil {
+		s.options = *options
+	}
+	if s.options.MaxValueSize <= 0 {
+		s.options.MaxValueSize = DefaultMaxValueSize
+	}
+	if s.options.MaxBatchSize <= 0 {
+		s.options.MaxBatchSize = DefaultMaxBatchSize
+	}
+	if s.options.Timeout <= 0 {
+		s.options.Timeout = 5 * time.Second
+	}
+	return s
+}
+
+// Register registers the server with g
+func (s *Server) Register(g *grpc.Server) {
+	cachepb.RegisterCacheServiceServer(g, s)
+}
+
+// Get implements cachepb.CacheServiceServer
+func (s *Server) Get(ctx context.Context, req *cachepb.GetRequest) (*cachepb.GetResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.options.Timeout)
+	defer cancel()
+
+	value, found, err := s.get(ctx, req.Key)
+	if err != nil || !found {
+		return &cachepb.GetResponse{}, err
+	}
+	return &cachepb.GetResponse{Found: true, Value: value}, nil
+}
+
+// Set implements cachepb.CacheServiceServer
+func (s *Server) Set(ctx context.Context, req *cachepb.SetRequest) (*cachepb.SetResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.options.Timeout)
+	defer cancel()
+
+	return &cachepb.SetResponse{}, s.set(ctx, req)
+}
+
+// Delete implements cachepb.CacheServiceServer
+func (s *Server) Delete(ctx context.Context, req *cachepb.DeleteRequest) (*cachepb.DeleteResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.options.Timeout)
+	defer cancel()
+
+	return &cachepb.DeleteResponse{}, toStatus(s.cache.Delete(ctx, req.Key))
+}
+
+// Clear implements cachepb.CacheServiceServer
+func (s *Server) Clear(ctx context.Context, _ *cachepb.ClearRequest) (*cachepb.ClearResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.options.Timeout)
+	defer cancel()
+
+	return &cachepb.ClearResponse{}, toStatus(s.cache.Clear(ctx))
+}
+
+// Stats implements cachepb.CacheServiceServer
+func (s *Server) Stats(ctx context.Context, _ *cachepb.StatsRequest) (*cachepb.StatsResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.options.Timeout)
+	defer cancel()
+
+	sp, ok := s.cache.(cache.StatsProvider)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, cache.ErrNotSupported.Error())
+	}
+	stats, err := sp.Stats(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &cachepb.StatsResponse{
+		Hits:      stats.Hits,
+		Misses:    stats.Misses,
+		Sets:      stats.Sets,
+		Deletes:   stats.Deletes,
+		Evictions: stats.Evictions,
+		Entries:   stats.Entries,
+	}, nil
+}
+
+// GetMany implements cachepb.CacheServiceServer
+func (s *Server) GetMany(ctx context.Context, req *cachepb.GetManyRequest) (*cachepb.GetManyResponse, error) {
+	if err := s.checkBatch(len(req.Keys)); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.options.Timeout)
+	defer cancel()
+
+	resp := &cachepb.GetManyResponse{Entries: make(map[string]*cachepb.Value, len(req.Keys))}
+	for _, key := range req.Keys {
+		value, found, err := s.get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			resp.Entries[key] = value
+		}
+	}
+	return resp, nil
+}
+
+// SetMany implements cachepb.CacheServiceServer. Items are stored in order,
+// and a failure leaves the ones before it stored.
+func (s *Server) SetMany(ctx context.Context, req *cachepb.SetManyRequest) (*cachepb.SetManyResponse, error) {
+	if err := s.checkBatch(len(req.Items)); err != nil {
+		return nil, err
+	}
+	// Reject oversized values before storing anything
+	for _, item := range req.Items {
+		if err := s.checkValue(item); err != nil {
+			return nil, err
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.options.Timeout)
+	defer cancel()
+
+	for _, item := range req.Items {
+		if err := s.set(ctx, item); err != nil {
+			return nil, err
+		}
+	}
+	return &cachepb.SetManyResponse{}, nil
+}
+
+// DeleteMany implements cachepb.CacheServiceServer
+func (s *Server) DeleteMany(ctx context.Context, req *cachepb.DeleteManyRequest) (*cachepb.DeleteManyResponse, error) {
+	if err := s.checkBatch(len(req.Keys)); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.options.Timeout)
+	defer cancel()
+
+	for _, key := range req.Keys {
+		if err := s.cache.Delete(ctx, key); err != nil {
+			return nil, toStatus(err)
+		}
+	}
+	return &cachepb.DeleteManyResponse{}, nil
+}
+
+// get reads key, treating both ways backends report misses as not found
+func (s *Server) get(ctx context.Context, key string) (*cachepb.Value, bool, error) {
+	value, err := s.cache.Get(ctx, key)
+	if errors.Is(err, cache.ErrNotFound) || (err == nil && value == nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, toStatus(err)
+	}
+
+	v, err := encode(value)
+	if err != nil {
+		return nil, false, status.Errorf(codes.Internal, "failed to encode %q: %v", key, err)
+	}
+	return v, true, nil
+}
+
+// set stores a value, decoded so that the backend holds the same values a
+// local user would have stored
+func (s *Server) set(ctx context.Context, req *cachepb.SetRequest) error {
+	if err := s.checkValue(req); err != nil {
+		return err
+	}
+	value, err := decode(req.Value)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid value for %q: %v", req.Key, err)
+	}
+	ttl := time.Duration(req.TtlMillis) * time.Millisecond
+	return toStatus(s.cache.Set(ctx, req.Key, value, ttl))
+}
+
+func (s *Server) checkValue(req *cachepb.SetRequest) error {
+	if size := len(req.GetValue().GetData()); size > s.options.MaxValueSize {
+		return status.Errorf(codes.InvalidArgument, "value for %q is %d bytes, more than the limit of %d", req.Key, size, s.options.MaxValueSize)
+	}
+	return nil
+}
+
+func (s *Server) checkBatch(n int) error {
+	if n > s.options.MaxBatchSize {
+		return status.Errorf(codes.InvalidArgument, "batch of %d keys is larger than the limit of %d", n, s.options.MaxBatchSize)
+	}
+	return nil
+}
+
+// toStatus maps cache errors to gRPC status errors
+func toStatus(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	case errors.Is(err, cache.ErrNotSupported):
+		return status.Error(codes.Unimplemented, err.Error())
+	default:
+		return status.Error(codes.Unavailable, fmt.Sprintf("cache: %v", err))
+	}
+}
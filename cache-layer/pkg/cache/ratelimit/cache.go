@@ -0,0 +1,130 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+)
+
+// lockStripes is the number of mutexes the keys of a Cache store share
+const lockStripes = 64
+
+// Cache is a Store that keeps the limiter state in a cache.Cache, so that
+// limits work on every backend. Calls for the same key are serialized in
+// process, but instances sharing a backend other than Redis may race and
+// let a few extra calls through. The backend's MaxTTL must be at least two
+// Periods, or states are forgotten early.
+type Cache struct {
+	cache  cache.Cache
+	prefix string
+	locks  [lockStripes]sync.Mutex
+
+	now func() time.Time
+}
+
+// redisBackend is implemented by the Redis cache backend
+type redisBackend interface {
+	Client() *redis.Client
+	Prefix() string
+}
+
+// NewCache creates a store whose keys in c start with prefix ("ratelimit:"
+// if empty). When c is the Redis cache backend itself, the Redis store is
+// returned instead, so that every call is a single atomic script; its keys
+// start with the prefix of the cache followed by prefix. Decorated caches,
+// such as one behind a circuit breaker, are used through their decorators.
+func NewCache(c cache.Cache, prefix string) Store {
+	if prefix == "" {
+		prefix = "ratelimit:"
+	}
+	if backend, ok := c.(redisBackend); ok {
+		return NewRedis(backend.Client(), backend.Prefix()+prefix)
+	}
+	return &Cache{cache: c, prefix: prefix, now: time.Now}
+}
+
+// TokenBucket implements Store
+func (s *Cache) TokenBucket(ctx context.Context, key string, limit Limit) (Result, error) {
+	key = s.prefix + "tb:" + key
+	mu := s.lock(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	data, err := s.load(ctx, key, 16)
+	if err != nil {
+		return Result{}, err
+	}
+	now := s.now().UnixMilli()
+	b := bucketState{tokens: float64(limit.Burst), last: now}
+	if data != nil {
+		b.tokens = math.Float64frombits(binary.BigEndian.Uint64(data))
+		b.last = int64(binary.BigEndian.Uint64(data[8:]))
+	}
+
+	res, expires := takeToken(&b, limit, now)
+	data = binary.BigEndian.AppendUint64(nil, math.Float64bits(b.tokens))
+	data = binary.BigEndian.AppendUint64(data, uint64(b.last))
+	return res, s.store(ctx, key, data, expires-now)
+}
+
+// SlidingWindow implements Store
+func (s *Cache) SlidingWindow(ctx context.Context, key string, limit Limit) (Result, error) {
+	key = s.prefix + "sw:" + key
+	mu := s.lock(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	data, err := s.load(ctx, key, 24)
+	if err != nil {
+		return Result{}, err
+	}
+	w := windowState{window: -2}
+	if data != nil {
+		w.window = int64(binary.BigEndian.Uint64(data))
+		w.cur = int(binary.BigEndian.Uint64(data[8:]))
+		w.prev = int(binary.BigEndian.Uint64(data[16:]))
+	}
+
+	now := s.now().UnixMilli()
+	res, expires := countCall(&w, limit, now)
+	data = binary.BigEndian.AppendUint64(nil, uint64(w.window))
+	data = binary.BigEndian.AppendUint64(data, uint64(w.cur))
+	data = binary.BigEndian.AppendUint64(data, uint64(w.prev))
+	return res, s.store(ctx, key, data, expires-now)
+}
+
+func (s *Cache) lock(key string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &s.locks[h.Sum32()%lockStripes]
+}
+
+// load returns the encoded state of key, or nil if there is none or it is
+// not size bytes long
+func (s *Cache) load(ctx context.Context, key string, size int) ([]byte, error) {
+	value, err := s.cache.Get(ctx, key)
+	if errors.Is(err, cache.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, ok := value.([]byte)
+	if !ok || len(data) != size {
+		return nil, nil
+	}
+	return data, nil
+}
+
+// store writes the encoded state of key, to be forgotten after ttl
+// milliseconds
+func (s *Cache) store(ctx context.Context, key string, data []byte, ttl int64) error {
+	return s.cache.Set(ctx, key, data, time.Duration(max(1, ttl))*time.Millisecond)
+}
@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepEvery is the number of calls between sweeps of forgotten states
+const sweepEvery = 1024
+
+// Memory is a Store that keeps the limiter state in process
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+	windows map[string]*memoryWindow
+	calls   int
+
+	now func() time.Time
+}
+
+type memoryBucket struct {
+	bucketState
+	expires int64
+}
+
+type memoryWindow struct {
+	windowState
+	expires int64
+}
+
+// NewMemory creates an in-process store
+func NewMemory() *Memory {
+	return &Memory{
+		buckets: make(map[string]*memoryBucket),
+		windows: make(map[string]*memoryWindow),
+		now:     time.Now,
+	}
+}
+
+// TokenBucket implements Store
+func (m *Memory) TokenBucket(ctx context.Context, key string, limit Limit) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.tick()
+
+	b, ok := m.buckets[key]
+	if !ok || b.expires <= now {
+		b = &memoryBucket{bucketState: bucketState{tokens: float64(limit.Burst), last: now}}
+		m.buckets[key] = b
+	}
+	res, expires := takeToken(&b.bucketState, limit, now)
+	b.expires = expires
+	return res, nil
+}
+
+// SlidingWindow implements Store
+func (m *Memory) SlidingWindow(ctx context.Context, key string, limit Limit) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.tick()
+
+	s, ok := m.windows[key]
+	if !ok || s.expires <= now {
+		s = &memoryWindow{windowState: windowState{window: -2}}
+		m.windows[key] = s
+	}
+	res, expires := countCall(&s.windowState, limit, now)
+	s.expires = expires
+	return res, nil
+}
+
+// tick returns the current time in milliseconds, sweeping forgotten states
+// now and then; callers must hold m.mu
+func (m *Memory) tick() int64 {
+	now := m.now().UnixMilli()
+
+	m.calls++
+	if m.calls%sweepEvery == 0 {
+		for key, b := range m.buckets {
+			if b.expires <= now {
+				delete(m.buckets, key)
+			}
+		}
+		for key, s := range m.windows {
+			if s.expires <= now {
+				delete(m.windows, key)
+			}
+		}
+	}
+	return now
+}
@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// KeyFunc returns the key a request is limited by; requests with an empty
+// key are not limited
+type KeyFunc func(r *http.Request) string
+
+// ClientIP limits requests by the IP address of the client. It does not
+// trust X-Forwarded-For; use a custom KeyFunc behind a trusted proxy.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// MiddlewareOptions represents the configuration options for Middleware
+type MiddlewareOptions struct {
+	// Key identifies the caller of a request (defaults to ClientIP)
+	Key KeyFunc
+	// FailClosed rejects requests with 503 when the limiter fails, instead
+	// of letting them through
+	FailClosed bool
+}
+
+// Middleware rejects the requests the limiter doesn't allow with 429 Too
+// Many Requests and a Retry-After header. Every response carries the
+// X-RateLimit-Limit and X-RateLimit-Remaining headers.
+func Middleware(l Limiter, options *MiddlewareOptions) func(http.Handler) http.Handler {
+	if options == nil {
+		options = &MiddlewareOptions{}
+	}
+	key := options.Key
+	if key == nil {
+		key = ClientIP
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			k := key(r)
+			if k == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			res, err := l.Allow(r.Context(), k)
+			if err != nil {
+				log.Printf("ratelimit: %v", err)
+				if options.FailClosed {
+					writeError(w, http.StatusServiceUnavailable, "rate limiter unavailable")
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			if !res.Allowed {
+				// Retry-After is in whole seconds, so round up
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(res.RetryAfter.Seconds()))))
+				writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
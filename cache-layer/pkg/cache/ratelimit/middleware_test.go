@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// failingLimiter is a Limiter whose store is unavailable
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	return Result{}, errors.New("redis unavailable")
+}
+
+func TestMiddleware(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	serve := func(h http.Handler, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("limits by client ip", func(t *testing.T) {
+		store := NewMemory()
+		store.now = func() time.Time { return start }
+		l, err := NewTokenBucket(store, Limit{Rate: 1, Period: 2500 * time.Millisecond, Burst: 2})
+		assert.NoError(t, err)
+		h := Middleware(l, nil)(ok)
+
+		rec := serve(h, "10.0.0.1:1234")
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get("X-RateLimit-Limit"))
+		assert.Equal(t, "1", rec.Header().Get("X-RateLimit-Remaining"))
+		assert.Equal(t, http.StatusNoContent, serve(h, "10.0.0.1:5678").Code)
+
+		rec = serve(h, "10.0.0.1:1234")
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "0", rec.Header().Get("X-RateLimit-Remaining"))
+		assert.Equal(t, "3", rec.Header().Get("Retry-After"))
+		assert.JSONEq(t, `{"error":"rate limit exceeded"}`, rec.Body.String())
+
+		assert.Equal(t, http.StatusNoContent, serve(h, "10.0.0.2:1234").Code)
+	})
+
+	t.Run("custom keys", func(t *testing.T) {
+		l, err := NewSlidingWindow(NewMemory(), PerMinute(1))
+		assert.NoError(t, err)
+		h := Middleware(l, &MiddlewareOptions{Key: func(r *http.Request) string {
+			return r.Header.Get("X-API-Key")
+		}})(ok)
+
+		// Requests without a key are not limited
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, http.StatusNoContent, serve(h, "10.0.0.1:1234").Code)
+		}
+	})
+
+	t.Run("limiter failures", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, serve(Middleware(failingLimiter{}, nil)(ok), "10.0.0.1:1234").Code)
+
+		h := Middleware(failingLimiter{}, &MiddlewareOptions{FailClosed: true})(ok)
+		assert.Equal(t, http.StatusServiceUnavailable, serve(h, "10.0.0.1:1234").Code)
+	})
+}
@@ -0,0 +1,178 @@
+// Package ratelimit provides token bucket and sliding window rate limiters
+// whose state is kept in process, in any cache.Cache or in Redis, and
+// net/http middleware that applies them.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// ErrInvalidLimit is returned for limits that allow nothing
+var ErrInvalidLimit = errors.New("ratelimit: rate must be positive and period at least 1ms")
+
+// Limit is the number of calls allowed per period
+type Limit struct {
+	// Rate is the number of calls allowed per Period
+	Rate int
+	// Period is the time Rate applies to
+	Period time.Duration
+	// Burst is the token bucket capacity (defaults to Rate); sliding windows
+	// ignore it
+	Burst int
+}
+
+// PerSecond returns a limit of rate calls per second
+func PerSecond(rate int) Limit {
+	return Limit{Rate: rate, Period: time.Second}
+}
+
+// PerMinute returns a limit of rate calls per minute
+func PerMinute(rate int) Limit {
+	return Limit{Rate: rate, Period: time.Minute}
+}
+
+func (l Limit) validate() (Limit, error) {
+	if l.Rate <= 0 || l.Period < time.Millisecond {
+		return l, ErrInvalidLimit
+	}
+	if l.Burst <= 0 {
+		l.Burst = l.Rate
+	}
+	return l, nil
+}
+
+// Result is the outcome of a call to Allow
+type Result struct {
+	// Allowed tells whether the call may proceed
+	Allowed bool
+	// Limit is the configured rate
+	Limit int
+	// Remaining is the number of calls still allowed right now
+	Remaining int
+	// RetryAfter is how long to wait before the next call is allowed; it is
+	// zero for allowed calls
+	RetryAfter time.Duration
+}
+
+// Limiter decides whether calls identified by key may proceed
+type Limiter interface {
+	Allow(ctx context.Context, key string) (Result, error)
+}
+
+// Store keeps the state of the limiters. Each method atomically records a
+// call for key under limit if it is allowed.
+type Store interface {
+	TokenBucket(ctx context.Context, key string, limit Limit) (Result, error)
+	SlidingWindow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// limiter applies one of the algorithms of a Store with a fixed limit
+type limiter struct {
+	limit Limit
+	allow func(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+func (l *limiter) Allow(ctx context.Context, key string) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	return l.allow(ctx, key, l.limit)
+}
+
+// NewTokenBucket creates a limiter that allows bursts of up to limit.Burst
+// calls, refilled at limit.Rate per limit.Period
+func NewTokenBucket(store Store, limit Limit) (Limiter, error) {
+	limit, err := limit.validate()
+	if err != nil {
+		return nil, err
+	}
+	return &limiter{limit: limit, allow: store.TokenBucket}, nil
+}
+
+// NewSlidingWindow creates a limiter that allows limit.Rate calls in any
+// limit.Period. The window is approximated from the counts of the current
+// and previous fixed windows, so it needs constant space per key.
+func NewSlidingWindow(store Store, limit Limit) (Limiter, error) {
+	limit, err := limit.validate()
+	if err != nil {
+		return nil, err
+	}
+	return &limiter{limit: limit, allow: store.SlidingWindow}, nil
+}
+
+// The algorithms work on milliseconds. The Lua scripts of the Redis store
+// implement the same arithmetic.
+
+// bucketState is the state of a token bucket
+type bucketState struct {
+	tokens float64
+	last   int64
+}
+
+// takeToken refills b up to now and takes a token from it. It returns the
+// result and the time after which b is full again and can be forgotten.
+func takeToken(b *bucketState, l Limit, now int64) (Result, int64) {
+	rate := float64(l.Rate) / float64(l.Period.Milliseconds())
+	burst := float64(l.Burst)
+
+	elapsed := max(0, now-b.last)
+	b.tokens = math.Min(burst, b.tokens+float64(elapsed)*rate)
+	b.last = now
+
+	res := Result{Limit: l.Rate}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+		res.Remaining = int(math.Floor(b.tokens))
+	} else {
+		res.RetryAfter = time.Duration(math.Ceil((1-b.tokens)/rate)) * time.Millisecond
+	}
+	return res, now + int64(math.Ceil((burst-b.tokens)/rate)) + 1
+}
+
+// windowState is the state of a sliding window: the call counts of the
+// fixed window with the given index and of the one before it
+type windowState struct {
+	window    int64
+	cur, prev int
+}
+
+// countCall counts a call at now if the estimated count of the sliding
+// window allows it. It returns the result and the time after which s no
+// longer matters.
+func countCall(s *windowState, l Limit, now int64) (Result, int64) {
+	w := l.Period.Milliseconds()
+	idx := now / w
+	switch idx {
+	case s.window:
+	case s.window + 1:
+		s.prev, s.cur = s.cur, 0
+	default:
+		s.prev, s.cur = 0, 0
+	}
+	s.window = idx
+
+	elapsed := now - idx*w
+	rate := float64(l.Rate)
+	count := float64(s.prev)*(1-float64(elapsed)/float64(w)) + float64(s.cur)
+
+	res := Result{Limit: l.Rate}
+	if count+1 <= rate {
+		s.cur++
+		res.Allowed = true
+		res.Remaining = int(math.Floor(rate - count - 1))
+	} else {
+		// Wait until the previous window's weight has dropped enough
+		var retry int64
+		if float64(s.cur) >= rate {
+			retry = (w - elapsed) + int64(math.Ceil((1-(rate-1)/float64(s.cur))*float64(w)))
+		} else {
+			retry = int64(math.Ceil((1-(rate-1-float64(s.cur))/float64(s.prev))*float64(w))) - elapsed
+		}
+		res.RetryAfter = time.Duration(max(1, retry)) * time.Millisecond
+	}
+	return res, now + 2*w - elapsed
+}
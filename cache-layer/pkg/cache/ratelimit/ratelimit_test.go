@@ -0,0 +1,211 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/breaker"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/memory"
+	redisCache "github.com/seokheejang/go/cache-layer/pkg/cache/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+// start is aligned to a second, so that sliding windows start with it
+var start = time.Unix(1700000000, 0)
+
+// testStore checks the behaviour shared by every Store implementation.
+// newStore returns a fresh store and a function that sets its clock.
+func testStore(t *testing.T, newStore func() (Store, func(time.Time))) {
+	ctx := context.Background()
+
+	allow := func(t *testing.T, l Limiter, key string) Result {
+		t.Helper()
+		res, err := l.Allow(ctx, key)
+		assert.NoError(t, err)
+		return res
+	}
+
+	t.Run("token bucket", func(t *testing.T) {
+		store, setTime := newStore()
+		setTime(start)
+		l, err := NewTokenBucket(store, Limit{Rate: 10, Period: time.Second, Burst: 3})
+		assert.NoError(t, err)
+
+		for _, remaining := range []int{2, 1, 0} {
+			assert.Equal(t, Result{Allowed: true, Limit: 10, Remaining: remaining}, allow(t, l, "a"))
+		}
+		assert.Equal(t, Result{Limit: 10, RetryAfter: 100 * time.Millisecond}, allow(t, l, "a"))
+		// Keys are limited independently
+		assert.True(t, allow(t, l, "b").Allowed)
+
+		setTime(start.Add(50 * time.Millisecond))
+		assert.Equal(t, 50*time.Millisecond, allow(t, l, "a").RetryAfter)
+		setTime(start.Add(100 * time.Millisecond))
+		assert.True(t, allow(t, l, "a").Allowed)
+		assert.False(t, allow(t, l, "a").Allowed)
+
+		// An idle bucket fills up to its burst, not beyond
+		setTime(start.Add(time.Hour))
+		for i := 0; i < 3; i++ {
+			assert.True(t, allow(t, l, "a").Allowed)
+		}
+		assert.False(t, allow(t, l, "a").Allowed)
+	})
+
+	t.Run("sliding window", func(t *testing.T) {
+		store, setTime := newStore()
+		setTime(start)
+		l, err := NewSlidingWindow(store, Limit{Rate: 3, Period: time.Second})
+		assert.NoError(t, err)
+
+		for _, remaining := range []int{2, 1, 0} {
+			assert.Equal(t, Result{Allowed: true, Limit: 3, Remaining: remaining}, allow(t, l, "a"))
+		}
+		// The window ends in 1s, and 3 calls weigh 2 or less after another third of it
+		assert.Equal(t, Result{Limit: 3, RetryAfter: 1334 * time.Millisecond}, allow(t, l, "a"))
+		assert.True(t, allow(t, l, "b").Allowed)
+
+		setTime(start.Add(1333 * time.Millisecond))
+		assert.Equal(t, time.Millisecond, allow(t, l, "a").RetryAfter)
+		setTime(start.Add(1334 * time.Millisecond))
+		assert.True(t, allow(t, l, "a").Allowed)
+		assert.False(t, allow(t, l, "a").Allowed)
+
+		// Calls older than two windows are forgotten
+		setTime(start.Add(3 * time.Second))
+		for i := 0; i < 3; i++ {
+			assert.True(t, allow(t, l, "a").Allowed)
+		}
+		assert.False(t, allow(t, l, "a").Allowed)
+	})
+
+	t.Run("concurrent calls never exceed the limit", func(t *testing.T) {
+		store, setTime := newStore()
+		setTime(start)
+		l, err := NewSlidingWindow(store, PerMinute(50))
+		assert.NoError(t, err)
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		allowed := 0
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if res, err := l.Allow(ctx, "shared"); err == nil && res.Allowed {
+					mu.Lock()
+					allowed++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 50, allowed)
+	})
+}
+
+func TestMemory(t *testing.T) {
+	testStore(t, func() (Store, func(time.Time)) {
+		m := NewMemory()
+		return m, func(now time.Time) { m.now = func() time.Time { return now } }
+	})
+
+	t.Run("forgotten states are swept", func(t *testing.T) {
+		m := NewMemory()
+		l, err := NewTokenBucket(m, PerSecond(10))
+		assert.NoError(t, err)
+
+		now := start
+		m.now = func() time.Time { return now }
+		for i := 0; i < sweepEvery-1; i++ {
+			_, err := l.Allow(context.Background(), fmt.Sprintf("key%d", i))
+			assert.NoError(t, err)
+		}
+		assert.Len(t, m.buckets, sweepEvery-1)
+
+		now = now.Add(time.Minute)
+		_, err = l.Allow(context.Background(), "last")
+		assert.NoError(t, err)
+		assert.Len(t, m.buckets, 1)
+	})
+}
+
+func TestRedis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	testStore(t, func() (Store, func(time.Time)) {
+		mr.FlushAll()
+		return NewRedis(client, ""), mr.SetTime
+	})
+
+	t.Run("keys expire", func(t *testing.T) {
+		mr.FlushAll()
+		mr.SetTime(start)
+		l, err := NewSlidingWindow(NewRedis(client, "rl:"), PerSecond(10))
+		assert.NoError(t, err)
+
+		_, err = l.Allow(context.Background(), "a")
+		assert.NoError(t, err)
+		assert.Equal(t, 2*time.Second, mr.TTL("rl:sw:a"))
+	})
+}
+
+func TestCache(t *testing.T) {
+	options := &cache.Options{DefaultTTL: time.Minute, MaxTTL: time.Hour}
+
+	testStore(t, func() (Store, func(time.Time)) {
+		c, err := memory.New(options)
+		assert.NoError(t, err)
+		s := NewCache(c, "").(*Cache)
+		return s, func(now time.Time) { s.now = func() time.Time { return now } }
+	})
+
+	t.Run("state lives in the cache", func(t *testing.T) {
+		c, err := memory.New(options)
+		assert.NoError(t, err)
+		l, err := NewTokenBucket(NewCache(c, "rl:"), PerSecond(10))
+		assert.NoError(t, err)
+
+		_, err = l.Allow(context.Background(), "a")
+		assert.NoError(t, err)
+		_, err = c.Get(context.Background(), "rl:tb:a")
+		assert.NoError(t, err)
+	})
+
+	t.Run("redis backends use the scripts", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		defer client.Close()
+
+		c, err := redisCache.NewWithOptions(client, &redisCache.Options{Options: *options, Prefix: "app:"})
+		assert.NoError(t, err)
+		store := NewCache(c, "")
+		assert.IsType(t, &Redis{}, store)
+
+		l, err := NewSlidingWindow(store, PerSecond(10))
+		assert.NoError(t, err)
+		_, err = l.Allow(context.Background(), "a")
+		assert.NoError(t, err)
+		assert.True(t, mr.Exists("app:ratelimit:sw:a"))
+
+		// A breaker in front keeps failing fast while Redis is down
+		assert.IsType(t, &Cache{}, NewCache(breaker.New(c, nil), ""))
+	})
+}
+
+func TestNewLimiter_InvalidLimit(t *testing.T) {
+	for _, limit := range []Limit{{}, {Rate: 1}, {Rate: 1, Period: time.Microsecond}, {Rate: -1, Period: time.Second}} {
+		_, err := NewTokenBucket(NewMemory(), limit)
+		assert.ErrorIs(t, err, ErrInvalidLimit)
+		_, err = NewSlidingWindow(NewMemory(), limit)
+		assert.ErrorIs(t, err, ErrInvalidLimit)
+	}
+}
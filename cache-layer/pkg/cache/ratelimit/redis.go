@@ -0,0 +1,121 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// The scripts read the clock of the Redis server, so that every instance of
+// the application sees the same time, and implement the arithmetic of
+// takeToken and countCall.
+
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+local tokens = tonumber(state[1]) or burst
+local last = tonumber(state[2]) or now
+
+tokens = math.min(burst, tokens + math.max(0, now - last) * rate)
+local allowed, remaining, retry = 0, 0, 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+	remaining = math.floor(tokens)
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'last', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / rate) + 1)
+return {allowed, remaining, retry}
+`)
+
+var slidingWindowScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local w = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local idx = math.floor(now / w)
+
+local state = redis.call('HMGET', KEYS[1], 'window', 'cur', 'prev')
+local window = tonumber(state[1])
+local cur = tonumber(state[2]) or 0
+local prev = tonumber(state[3]) or 0
+if window == nil then
+	cur, prev = 0, 0
+elseif idx == window + 1 then
+	prev, cur = cur, 0
+elseif idx ~= window then
+	prev, cur = 0, 0
+end
+
+local elapsed = now - idx * w
+local count = prev * (1 - elapsed / w) + cur
+local allowed, remaining, retry = 0, 0, 0
+if count + 1 <= rate then
+	cur = cur + 1
+	allowed = 1
+	remaining = math.floor(rate - count - 1)
+else
+	if cur >= rate then
+		retry = (w - elapsed) + math.ceil((1 - (rate - 1) / cur) * w)
+	else
+		retry = math.ceil((1 - (rate - 1 - cur) / prev) * w) - elapsed
+	end
+	retry = math.max(1, retry)
+end
+
+redis.call('HSET', KEYS[1], 'window', idx, 'cur', cur, 'prev', prev)
+redis.call('PEXPIRE', KEYS[1], 2 * w - elapsed)
+return {allowed, remaining, retry}
+`)
+
+// Redis is a Store that keeps the limiter state in Redis, shared by every
+// instance of the application. Each call runs a single Lua script, so
+// concurrent calls for the same key never race.
+type Redis struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedis creates a store whose keys start with prefix ("ratelimit:" if empty)
+func NewRedis(client *redis.Client, prefix string) *Redis {
+	if prefix == "" {
+		prefix = "ratelimit:"
+	}
+	return &Redis{client: client, prefix: prefix}
+}
+
+// TokenBucket implements Store
+func (r *Redis) TokenBucket(ctx context.Context, key string, limit Limit) (Result, error) {
+	rate := float64(limit.Rate) / float64(limit.Period.Milliseconds())
+	return r.run(ctx, tokenBucketScript, r.prefix+"tb:"+key, limit,
+		strconv.FormatFloat(rate, 'g', -1, 64), limit.Burst)
+}
+
+// SlidingWindow implements Store
+func (r *Redis) SlidingWindow(ctx context.Context, key string, limit Limit) (Result, error) {
+	return r.run(ctx, slidingWindowScript, r.prefix+"sw:"+key, limit,
+		limit.Rate, limit.Period.Milliseconds())
+}
+
+func (r *Redis) run(ctx context.Context, script *redis.Script, key string, limit Limit, args ...interface{}) (Result, error) {
+	reply, err := script.Run(ctx, r.client, []string{key}, args...).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{
+		Allowed:    reply[0] == 1,
+		Limit:      limit.Rate,
+		Remaining:  int(reply[1]),
+		RetryAfter: time.Duration(reply[2]) * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,93 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+)
+
+// Keys implements cache.KeyLister with SCAN, whose cursor it returns. Pages
+// may hold more or fewer than limit keys. Without a Prefix, every key of the
+// selected database is listed; with one, only the keys of the current
+// namespace version, without their prefix.
+func (c *redisCache) Keys(ctx context.Context, pattern, cursor string, limit int) (cache.KeyPage, error) {
+	var from uint64
+	if cursor != "" {
+		var err error
+		if from, err = strconv.ParseUint(cursor, 10, 64); err != nil {
+			return cache.KeyPage{}, err
+		}
+	}
+	if pattern == "" {
+		pattern = "*"
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+
+	prefix, ok, err := c.namespace(ctx)
+	if err != nil {
+		return cache.KeyPage{}, err
+	}
+	if !ok {
+		return cache.KeyPage{Keys: []string{}}, nil
+	}
+
+	keys, next, err := c.client.Scan(ctx, from, escapePattern(prefix)+pattern, int64(limit)).Result()
+	if err != nil {
+		return cache.KeyPage{}, err
+	}
+	for i, key := range keys {
+		keys[i] = strings.TrimPrefix(key, prefix)
+	}
+
+	page := cache.KeyPage{Keys: keys}
+	if page.Keys == nil {
+		page.Keys = []string{}
+	}
+	if next != 0 {
+		page.Cursor = strconv.FormatUint(next, 10)
+	}
+	return page, nil
+}
+
+// Inspect implements cache.Inspector
+func (c *redisCache) Inspect(ctx context.Context, key string) (cache.EntryInfo, error) {
+	prefix, ok, err := c.namespace(ctx)
+	if err != nil {
+		return cache.EntryInfo{}, err
+	}
+	if !ok {
+		return cache.EntryInfo{}, cache.ErrNotFound
+	}
+
+	var get *redis.StringCmd
+	var pttl *redis.DurationCmd
+	_, err = c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, prefix+key)
+		pttl = pipe.PTTL(ctx, prefix+key)
+		return nil
+	})
+	if err == redis.Nil {
+		return cache.EntryInfo{}, cache.ErrNotFound
+	}
+	if err != nil {
+		return cache.EntryInfo{}, err
+	}
+
+	data, _ := get.Bytes()
+	value, err := decode(data)
+	if err != nil {
+		return cache.EntryInfo{}, err
+	}
+
+	info := cache.EntryInfo{Key: key, Value: value, Size: int64(len(data))}
+	if ttl := pttl.Val(); ttl > 0 {
+		info.ExpiresAt = time.Now().Add(ttl)
+	}
+	return info, nil
+}
@@ -3,35 +3,115 @@ package redis
 import (
 	"context"
 	"encoding/json"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/seokheejang/go/cache-layer/pkg/cache"
 )
 
+// rawPrefix marks []byte values stored verbatim. It can never start a JSON
+// document, so it tells raw values apart from JSON encoded ones on Get.
+const rawPrefix byte = 0x00
+
+// Options represents the configuration options for a Redis cache
+type Options struct {
+	cache.Options
+	// Prefix starts every key of the cache, so that it can share the
+	// database with other data such as Bloom filters or rate limits. Keys
+	// are namespaced by a version stored under it, so Keys lists only the
+	// current entries and Clear moves to a new version in one command,
+	// leaving the old entries to expire. Without one, the cache owns the
+	// whole database and Clear flushes it.
+	Prefix string
+}
+
+// versionLua sets k to the key of ARGV[2] in the current namespace version,
+// stored in KEYS[1]. A missing version starts at the server time in
+// microseconds, so that a version lost to an eviction is never reused.
+const versionLua = `
+local v = redis.call('GET', KEYS[1])
+if not v then
+	local t = redis.call('TIME')
+	v = t[1] .. string.format('%06d', tonumber(t[2]))
+	if not redis.call('SET', KEYS[1], v, 'NX') then
+		v = redis.call('GET', KEYS[1])
+	end
+end
+local k = ARGV[1] .. v .. ':' .. ARGV[2]
+`
+
+var (
+	getScript = redis.NewScript(versionLua + `return redis.call('GET', k)`)
+	setScript = redis.NewScript(versionLua + `
+if tonumber(ARGV[4]) > 0 then
+	return redis.call('SET', k, ARGV[3], 'PX', ARGV[4])
+end
+return redis.call('SET', k, ARGV[3])`)
+	delScript = redis.NewScript(versionLua + `return redis.call('DEL', k)`)
+
+	clearScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return redis.call('INCR', KEYS[1])
+end
+local t = redis.call('TIME')
+redis.call('SET', KEYS[1], t[1] .. string.format('%06d', tonumber(t[2])))
+return 0`)
+)
+
 type redisCache struct {
 	client  *redis.Client
-	options *cache.Options
+	options Options
 }
 
-// New creates a new Redis cache instance
+// New creates a new Redis cache instance owning the whole database
 func New(client *redis.Client, options *cache.Options) (cache.Cache, error) {
 	if options == nil {
-		options = &cache.Options{
-			DefaultTTL: 2 * time.Second,
-			MaxTTL:     5 * time.Second,
-			MaxSize:    0, // Redis handles size limits internally
+		return NewWithOptions(client, nil)
+	}
+	return NewWithOptions(client, &Options{Options: *options})
+}
+
+// NewWithOptions creates a new Redis cache instance using the given options
+func NewWithOptions(client *redis.Client, options *Options) (cache.Cache, error) {
+	if options == nil {
+		options = &Options{
+			Options: cache.Options{
+				DefaultTTL: 2 * time.Second,
+				MaxTTL:     5 * time.Second,
+				MaxSize:    0, // Redis handles size limits internally
+			},
 		}
 	}
 
 	return &redisCache{
 		client:  client,
-		options: options,
+		options: *options,
 	}, nil
 }
 
+// Client returns the Redis client of the cache, for features such as rate
+// limiting that run their own scripts
+func (c *redisCache) Client() *redis.Client {
+	return c.client
+}
+
+// Prefix returns the prefix of the keys of the cache
+func (c *redisCache) Prefix() string {
+	return c.options.Prefix
+}
+
 func (c *redisCache) Get(ctx context.Context, key string) (interface{}, error) {
-	data, err := c.client.Get(ctx, key).Bytes()
+	var data []byte
+	var err error
+	if c.options.Prefix == "" {
+		data, err = c.client.Get(ctx, key).Bytes()
+	} else {
+		var text string
+		text, err = c.run(ctx, getScript, key).Text()
+		data = []byte(text)
+	}
 	if err == redis.Nil {
 		return nil, cache.ErrNotFound
 	}
@@ -39,12 +119,7 @@ func (c *redisCache) Get(ctx context.Context, key string) (interface{}, error) {
 		return nil, err
 	}
 
-	var value interface{}
-	if err := json.Unmarshal(data, &value); err != nil {
-		return nil, err
-	}
-
-	return value, nil
+	return decode(data)
 }
 
 func (c *redisCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
@@ -55,22 +130,164 @@ func (c *redisCache) Set(ctx context.Context, key string, value interface{}, ttl
 		ttl = c.options.MaxTTL
 	}
 
-	data, err := json.Marshal(value)
+	data, err := encode(value)
 	if err != nil {
 		return err
 	}
 
-	return c.client.Set(ctx, key, data, ttl).Err()
+	if c.options.Prefix == "" {
+		return c.client.Set(ctx, key, data, ttl).Err()
+	}
+	ms := ttl.Milliseconds()
+	if ttl > 0 {
+		ms = max(1, ms)
+	}
+	return c.run(ctx, setScript, key, data, ms).Err()
 }
 
 func (c *redisCache) Delete(ctx context.Context, key string) error {
-	return c.client.Del(ctx, key).Err()
+	if c.options.Prefix == "" {
+		return c.client.Del(ctx, key).Err()
+	}
+	return c.run(ctx, delScript, key).Err()
 }
 
 func (c *redisCache) Clear(ctx context.Context) error {
-	return c.client.FlushDB(ctx).Err()
+	if c.options.Prefix == "" {
+		return c.client.FlushDB(ctx).Err()
+	}
+	return clearScript.Run(ctx, c.client, []string{c.versionKey()}).Err()
+}
+
+// run runs a script of versionLua on key in the current namespace version
+func (c *redisCache) run(ctx context.Context, script *redis.Script, key string, args ...interface{}) *redis.Cmd {
+	args = append([]interface{}{c.options.Prefix, key}, args...)
+	return script.Run(ctx, c.client, []string{c.versionKey()}, args...)
+}
+
+// namespace returns the prefix of the keys in the current namespace
+// version, or ok false when there is no version yet
+func (c *redisCache) namespace(ctx context.Context) (prefix string, ok bool, err error) {
+	if c.options.Prefix == "" {
+		return "", true, nil
+	}
+	version, err := c.client.Get(ctx, c.versionKey()).Result()
+	if err == redis.Nil {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return c.options.Prefix + version + ":", true, nil
+}
+
+// versionKey holds the namespace version; it can't clash with entry keys,
+// which continue the prefix with digits
+func (c *redisCache) versionKey() string {
+	return c.options.Prefix + "version"
 }
 
 func (c *redisCache) Close() error {
 	return c.client.Close()
 }
+
+// Stats implements cache.StatsProvider with the server-wide counters reported
+// by INFO and the size of the selected database, whatever the prefix
+func (c *redisCache) Stats(ctx context.Context) (cache.Stats, error) {
+	info, err := c.client.Info(ctx, "stats").Result()
+	if err != nil {
+		return cache.Stats{}, err
+	}
+	commands, err := c.client.Info(ctx, "commandstats").Result()
+	if err != nil {
+		return cache.Stats{}, err
+	}
+	entries, err := c.client.DBSize(ctx).Result()
+	if err != nil {
+		return cache.Stats{}, err
+	}
+
+	return statsFromInfo(info, commands, entries), nil
+}
+
+// statsFromInfo builds cache statistics from INFO stats and INFO commandstats output
+func statsFromInfo(info, commands string, entries int64) cache.Stats {
+	fields := parseInfo(info)
+	calls := parseInfo(commands)
+
+	return cache.Stats{
+		Hits:      parseUint(fields["keyspace_hits"]),
+		Misses:    parseUint(fields["keyspace_misses"]),
+		Sets:      commandCalls(calls["cmdstat_set"]),
+		Deletes:   commandCalls(calls["cmdstat_del"]),
+		Evictions: parseUint(fields["evicted_keys"]) + parseUint(fields["expired_keys"]),
+		Entries:   entries,
+	}
+}
+
+// parseInfo parses the "key:value" lines of an INFO reply
+func parseInfo(info string) map[string]string {
+	fields := make(map[string]string)
+	for _, line := range strings.Split(info, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			fields[key] = value
+		}
+	}
+	return fields
+}
+
+// commandCalls extracts the calls count from a "calls=N,usec=..." commandstats value
+func commandCalls(value string) uint64 {
+	for _, part := range strings.Split(value, ",") {
+		if n, ok := strings.CutPrefix(part, "calls="); ok {
+			return parseUint(n)
+		}
+	}
+	return 0
+}
+
+func parseUint(s string) uint64 {
+	n, _ := strconv.ParseUint(s, 10, 64)
+	return n
+}
+
+// escapePattern escapes the glob characters of SCAN MATCH patterns in s
+func escapePattern(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// encode serializes a value for Redis. []byte values, such as already
+// encoded GORM query results, are stored verbatim instead of as base64 JSON.
+func encode(value interface{}) ([]byte, error) {
+	if raw, ok := value.([]byte); ok {
+		data := make([]byte, 0, len(raw)+1)
+		data = append(data, rawPrefix)
+		return append(data, raw...), nil
+	}
+	return json.Marshal(value)
+}
+
+// decode reverses encode
+func decode(data []byte) (interface{}, error) {
+	if len(data) > 0 && data[0] == rawPrefix {
+		return data[1:], nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
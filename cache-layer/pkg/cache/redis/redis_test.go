@@ -0,0 +1,198 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/breaker"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/cachetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisCache_RawBytes(t *testing.T) {
+	mr := miniredis.RunT(t)
+	c, err := New(redis.NewClient(&redis.Options{Addr: mr.Addr()}), nil)
+	assert.NoError(t, err)
+	defer c.Close()
+	ctx := context.Background()
+
+	payload := []byte(`{"Dest":{"Name":"admin"},"RowsAffected":1}`)
+	assert.NoError(t, c.Set(ctx, "raw", payload, 0))
+	assert.NoError(t, c.Set(ctx, "json", map[string]interface{}{"n": float64(1)}, time.Second))
+
+	val, err := c.Get(ctx, "raw")
+	assert.NoError(t, err)
+	assert.Equal(t, payload, val)
+
+	val, err = c.Get(ctx, "json")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"n": float64(1)}, val)
+
+	_, err = c.Get(ctx, "missing")
+	assert.ErrorIs(t, err, cache.ErrNotFound)
+}
+
+func TestStatsFromInfo(t *testing.T) {
+	info := "# Stats\r\ntotal_connections_received:3\r\nexpired_keys:4\r\nevicted_keys:1\r\nkeyspace_hits:10\r\nkeyspace_misses:5\r\n"
+	commands := "# Commandstats\r\ncmdstat_get:calls=15,usec=30,usec_per_call=2.00\r\ncmdstat_set:calls=7,usec=21,usec_per_call=3.00\r\ncmdstat_del:calls=2,usec=4,usec_per_call=2.00\r\n"
+
+	stats := statsFromInfo(info, commands, 6)
+	assert.Equal(t, cache.Stats{
+		Hits:      10,
+		Misses:    5,
+		Sets:      7,
+		Deletes:   2,
+		Evictions: 5,
+		Entries:   6,
+	}, stats)
+	assert.InDelta(t, 10.0/15.0, stats.HitRatio(), 1e-9)
+}
+
+func TestRedisCache_Contract(t *testing.T) {
+	mr := miniredis.RunT(t)
+	cachetest.Run(t, cachetest.Suite{
+		New: func(t *testing.T, options *cache.Options) cache.Cache {
+			mr.FlushAll()
+			c, err := New(redis.NewClient(&redis.Options{Addr: mr.Addr()}), options)
+			assert.NoError(t, err)
+			return c
+		},
+		Sleep: mr.FastForward,
+	})
+}
+
+func TestRedisCache_Prefix(t *testing.T) {
+	mr := miniredis.RunT(t)
+	cachetest.Run(t, cachetest.Suite{
+		New: func(t *testing.T, options *cache.Options) cache.Cache {
+			mr.FlushAll()
+			c, err := NewWithOptions(redis.NewClient(&redis.Options{Addr: mr.Addr()}), &Options{Options: *options, Prefix: "app:"})
+			assert.NoError(t, err)
+			return c
+		},
+		Sleep: mr.FastForward,
+	})
+
+	t.Run("other keys are left alone", func(t *testing.T) {
+		mr.FlushAll()
+		c, err := NewWithOptions(redis.NewClient(&redis.Options{Addr: mr.Addr()}), &Options{
+			Options: cache.Options{DefaultTTL: time.Minute, MaxTTL: time.Minute},
+			Prefix:  "app*:",
+		})
+		assert.NoError(t, err)
+		defer c.Close()
+		ctx := context.Background()
+
+		assert.NoError(t, c.Set(ctx, "users:1", "alice", 0))
+		assert.NoError(t, mr.Set("app*:other", "foreign"))
+		assert.NoError(t, mr.Set("appx:users", "foreign"))
+		assert.NoError(t, mr.Set("bloom:users", "bits"))
+
+		// Glob characters of the prefix are literal
+		page, err := c.(cache.KeyLister).Keys(ctx, "*", "", 100)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"users:1"}, page.Keys)
+
+		info, err := c.(cache.Inspector).Inspect(ctx, "users:1")
+		assert.NoError(t, err)
+		assert.Equal(t, "alice", info.Value)
+
+		assert.NoError(t, c.Clear(ctx))
+		_, err = c.Get(ctx, "users:1")
+		assert.ErrorIs(t, err, cache.ErrNotFound)
+		page, err = c.(cache.KeyLister).Keys(ctx, "*", "", 100)
+		assert.NoError(t, err)
+		assert.Empty(t, page.Keys)
+		for _, key := range []string{"app*:other", "appx:users", "bloom:users"} {
+			assert.True(t, mr.Exists(key), key)
+		}
+	})
+
+	t.Run("lost version", func(t *testing.T) {
+		mr.FlushAll()
+		c, err := NewWithOptions(redis.NewClient(&redis.Options{Addr: mr.Addr()}), &Options{
+			Options: cache.Options{DefaultTTL: time.Minute, MaxTTL: time.Minute},
+			Prefix:  "app:",
+		})
+		assert.NoError(t, err)
+		defer c.Close()
+		ctx := context.Background()
+
+		assert.NoError(t, c.Set(ctx, "key", "value", 0))
+		mr.Del("app:version")
+		// A new version starts rather than the old entries coming back
+		mr.SetTime(time.Now().Add(time.Second))
+		_, err = c.Get(ctx, "key")
+		assert.ErrorIs(t, err, cache.ErrNotFound)
+	})
+
+	t.Run("clear is constant time behind the breaker", func(t *testing.T) {
+		mr.FlushAll()
+		for i := 0; i < 100000; i++ {
+			assert.NoError(t, mr.Set(fmt.Sprintf("other:%d", i), "foreign"))
+		}
+		c, err := NewWithOptions(redis.NewClient(&redis.Options{Addr: mr.Addr()}), &Options{
+			Options: cache.Options{DefaultTTL: time.Minute, MaxTTL: time.Minute},
+			Prefix:  "app:",
+		})
+		assert.NoError(t, err)
+		b := breaker.New(c, &breaker.Options{FailureThreshold: 1, Timeout: 100 * time.Millisecond})
+		defer b.Close()
+		ctx := context.Background()
+
+		for i := 0; i < 100; i++ {
+			assert.NoError(t, b.Set(ctx, fmt.Sprintf("key%d", i), i, 0))
+		}
+		before := mr.CommandCount()
+		assert.NoError(t, b.Clear(ctx))
+		assert.LessOrEqual(t, mr.CommandCount()-before, 5, "a few commands, whatever the size of the keyspace")
+		assert.Equal(t, breaker.StateClosed, b.State())
+
+		_, err = b.Get(ctx, "key0")
+		assert.ErrorIs(t, err, cache.ErrNotFound)
+		assert.True(t, mr.Exists("other:0"))
+	})
+}
+
+func TestRedisCache_Introspection(t *testing.T) {
+	mr := miniredis.RunT(t)
+	c, err := New(redis.NewClient(&redis.Options{Addr: mr.Addr()}), &cache.Options{DefaultTTL: time.Minute, MaxTTL: time.Minute})
+	assert.NoError(t, err)
+	defer c.Close()
+	ctx := context.Background()
+
+	for i := 0; i < 25; i++ {
+		assert.NoError(t, c.Set(ctx, fmt.Sprintf("users:%d", i), []byte("payload"), 0))
+	}
+	assert.NoError(t, c.Set(ctx, "roles:0", map[string]interface{}{"name": "admin"}, 0))
+
+	var keys []string
+	cursor := ""
+	for {
+		page, err := c.(cache.KeyLister).Keys(ctx, "users:*", cursor, 10)
+		assert.NoError(t, err)
+		keys = append(keys, page.Keys...)
+		if cursor = page.Cursor; cursor == "" {
+			break
+		}
+	}
+	assert.Len(t, keys, 25)
+
+	info, err := c.(cache.Inspector).Inspect(ctx, "roles:0")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"name": "admin"}, info.Value)
+	assert.Equal(t, int64(len(`{"name":"admin"}`)), info.Size)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), info.ExpiresAt, time.Second)
+
+	info, err = c.(cache.Inspector).Inspect(ctx, "users:0")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("payload"), info.Value)
+
+	_, err = c.(cache.Inspector).Inspect(ctx, "missing")
+	assert.ErrorIs(t, err, cache.ErrNotFound)
+}
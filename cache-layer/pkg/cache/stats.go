@@ -0,0 +1,41 @@
+package cache
+
+import "context"
+
+// Stats represents usage statistics of a cache backend. In-process backends
+// count their own operations; shared backends such as Redis report
+// server-wide counters.
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Sets      uint64
+	Deletes   uint64
+	Evictions uint64
+	// Entries is the current number of entries
+	Entries int64
+}
+
+// HitRatio returns the share of reads that were hits
+func (s Stats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// Sub returns the counter deltas between s and an earlier snapshot
+func (s Stats) Sub(earlier Stats) Stats {
+	return Stats{
+		Hits:      s.Hits - earlier.Hits,
+		Misses:    s.Misses - earlier.Misses,
+		Sets:      s.Sets - earlier.Sets,
+		Deletes:   s.Deletes - earlier.Deletes,
+		Evictions: s.Evictions - earlier.Evictions,
+		Entries:   s.Entries,
+	}
+}
+
+// StatsProvider is implemented by caches that can report statistics
+type StatsProvider interface {
+	Stats(ctx context.Context) (Stats, error)
+}
@@ -0,0 +1,571 @@
+// Package tenant partitions a cache.Cache between tenants. The tenant of
+// every call is taken from its context; each tenant gets its own key space,
+// its own quota and its own statistics, so that a noisy tenant only ever
+// evicts its own entries.
+package tenant
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+)
+
+// ErrNoTenant is returned for calls whose context carries no tenant when
+// there is no DefaultTenant
+var ErrNoTenant = errors.New("tenant: no tenant in context")
+
+type tenantKey struct{}
+
+// WithTenant returns a context whose cache calls are made on behalf of id
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// FromContext returns the tenant set with WithTenant
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(tenantKey{}).(string)
+	return id, ok && id != ""
+}
+
+// Middleware sets the tenant of every request to the value of header.
+// The header comes from the client, so valid, if set, should only accept
+// known tenants; requests without a valid one are left without a tenant.
+func Middleware(header string, valid func(id string) bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if id := r.Header.Get(header); id != "" && (valid == nil || valid(id)) {
+				r = r.WithContext(WithTenant(r.Context(), id))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Quota limits the entries of one tenant (0 means unlimited)
+type Quota struct {
+	MaxEntries int
+	// MaxBytes bounds the size of the keys and encoded values of the tenant
+	MaxBytes int64
+}
+
+// DefaultMaxTenants is the default number of tenants tracked at once
+const DefaultMaxTenants = 1024
+
+// Options represents the configuration options for tenant partitioning
+type Options struct {
+	// Quota applies to every tenant without an entry in Quotas
+	Quota  Quota
+	Quotas map[string]Quota
+	// MaxEntries and MaxBytes bound all tenants together (0 means
+	// unlimited). When they are exceeded, the tenant using the most gives
+	// up its least recently used entry.
+	MaxEntries int
+	MaxBytes   int64
+	// DefaultTenant is used for calls without a tenant; when empty, they
+	// fail with ErrNoTenant
+	DefaultTenant string
+	// Prefix starts the keys of every tenant (defaults to "tenant:")
+	Prefix string
+	// DefaultTTL is the TTL of entries set with a TTL of 0; it should match
+	// the default of the wrapped cache, so that expired entries are no
+	// longer counted against quotas (0 means they never expire)
+	DefaultTTL time.Duration
+	// MaxTenants bounds the tenants tracked at once (defaults to
+	// DefaultMaxTenants). Once reached, tenants without entries are
+	// forgotten, along with their statistics, to make room for new ones;
+	// if every tenant has entries, the writes of new tenants are not cached.
+	MaxTenants int
+}
+
+// Stats holds the counters of one tenant
+type Stats struct {
+	cache.Stats
+	// Bytes is the size of the keys and encoded values of the tenant
+	Bytes int64
+}
+
+// entry is a key written by a tenant
+type entry struct {
+	key       string
+	size      int64
+	expiresAt time.Time // zero when unknown or never
+}
+
+// partition is the state of one tenant
+type partition struct {
+	items map[string]*list.Element
+	lru   *list.List // front is most recently used
+	bytes int64
+	stats Stats
+}
+
+func (p *partition) remove(el *list.Element) {
+	e := el.Value.(*entry)
+	p.lru.Remove(el)
+	delete(p.items, e.key)
+	p.bytes -= e.size
+}
+
+// victim is an entry to delete from the wrapped cache
+type victim struct {
+	tenant, key string
+}
+
+// Cache is a cache.Cache decorator giving each tenant its own key space and
+// quota. It keeps track of the keys each tenant wrote to enforce quotas, so
+// the limits apply to what this process wrote; entries the wrapped cache
+// drops on its own are forgotten on the next miss or once their TTL passed.
+type Cache struct {
+	next    cache.Cache
+	options Options
+	now     func() time.Time
+
+	mu      sync.Mutex
+	tenants map[string]*partition
+	entries int
+	bytes   int64
+}
+
+// New wraps next with tenant partitioning
+func New(next cache.Cache, options *Options) *Cache {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = "tenant:"
+	}
+	if opts.MaxTenants <= 0 {
+		opts.MaxTenants = DefaultMaxTenants
+	}
+
+	return &Cache{
+		next:    next,
+		options: opts,
+		now:     time.Now,
+		tenants: make(map[string]*partition),
+	}
+}
+
+func (c *Cache) Get(ctx context.Context, key string) (interface{}, error) {
+	tenant, err := c.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := c.next.Get(ctx, c.key(tenant, key))
+	if err != nil && !errors.Is(err, cache.ErrNotFound) {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Reads never create partitions, so that unknown tenants cost nothing
+	p, known := c.tenants[tenant]
+	if !known {
+		return value, err
+	}
+	el, ok := p.items[key]
+	if err != nil || value == nil {
+		p.stats.Misses++
+		if ok {
+			c.forget(p, el)
+		}
+		return value, err
+	}
+	p.stats.Hits++
+	if ok {
+		p.lru.MoveToFront(el)
+	}
+	return value, nil
+}
+
+func (c *Cache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	tenant, err := c.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	size, err := sizeOf(key, value)
+	if err != nil {
+		return err
+	}
+
+	quota := c.quota(tenant)
+	// An entry that can never fit is not cached at all, and the value it
+	// replaces is stale
+	if (quota.MaxBytes > 0 && size > quota.MaxBytes) || (c.options.MaxBytes > 0 && size > c.options.MaxBytes) {
+		if err := c.next.Delete(ctx, c.key(tenant, key)); err != nil {
+			return err
+		}
+		c.mu.Lock()
+		if p, ok := c.tenants[tenant]; ok {
+			if el, ok := p.items[key]; ok {
+				c.forget(p, el)
+			}
+		}
+		c.mu.Unlock()
+		return nil
+	}
+
+	c.mu.Lock()
+	admitted := c.admit(tenant)
+	c.mu.Unlock()
+	if !admitted {
+		return nil
+	}
+
+	if ttl == 0 {
+		ttl = c.options.DefaultTTL
+	}
+
+	if err := c.next.Set(ctx, c.key(tenant, key), value, ttl); err != nil {
+		return err
+	}
+
+	e := &entry{key: key, size: size}
+	if ttl > 0 {
+		e.expiresAt = c.now().Add(ttl)
+	}
+
+	c.mu.Lock()
+	p := c.partition(tenant)
+	if el, ok := p.items[key]; ok {
+		c.forget(p, el)
+	}
+	p.items[key] = p.lru.PushFront(e)
+	p.bytes += size
+	c.entries++
+	c.bytes += size
+	p.stats.Sets++
+	victims := c.makeRoom(tenant, p, quota)
+	c.mu.Unlock()
+
+	return c.evict(ctx, victims)
+}
+
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	tenant, err := c.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	if err := c.next.Delete(ctx, c.key(tenant, key)); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	if p, ok := c.tenants[tenant]; ok {
+		if el, ok := p.items[key]; ok {
+			c.forget(p, el)
+		}
+		p.stats.Deletes++
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// Clear clears the whole wrapped cache, whatever the tenant of ctx: a write
+// by one tenant, such as one the GORM plugin invalidates after, may change
+// what the others read. Use Purge to drop the entries of a single tenant.
+func (c *Cache) Clear(ctx context.Context) error {
+	if err := c.next.Clear(ctx); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	for _, p := range c.tenants {
+		p.items = make(map[string]*list.Element)
+		p.lru.Init()
+		p.bytes = 0
+	}
+	c.entries, c.bytes = 0, 0
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *Cache) Close() error {
+	return c.next.Close()
+}
+
+// Purge deletes every entry of tenant. When the wrapped cache is a
+// cache.KeyLister, every key under the tenant's prefix goes, including those
+// written by other processes; otherwise only those written through this
+// cache do.
+func (c *Cache) Purge(ctx context.Context, tenant string) error {
+	_, err := cache.DeleteMatching(ctx, c.next, escapePattern(c.key(tenant, ""))+"*")
+	if err == nil {
+		c.mu.Lock()
+		if p, ok := c.tenants[tenant]; ok {
+			for _, el := range p.items {
+				c.forget(p, el)
+			}
+		}
+		c.mu.Unlock()
+		return nil
+	}
+	if !errors.Is(err, cache.ErrNotSupported) {
+		return err
+	}
+
+	c.mu.Lock()
+	p, ok := c.tenants[tenant]
+	var keys []string
+	if ok {
+		keys = make([]string, 0, len(p.items))
+		for key := range p.items {
+			keys = append(keys, key)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, key := range keys {
+		if err := c.next.Delete(ctx, c.key(tenant, key)); err != nil {
+			return err
+		}
+
+		c.mu.Lock()
+		if el, ok := p.items[key]; ok {
+			c.forget(p, el)
+		}
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+// Tenants returns the tenants seen so far, sorted
+func (c *Cache) Tenants() []string {
+	c.mu.Lock()
+	tenants := make([]string, 0, len(c.tenants))
+	for tenant := range c.tenants {
+		tenants = append(tenants, tenant)
+	}
+	c.mu.Unlock()
+
+	sort.Strings(tenants)
+	return tenants
+}
+
+// TenantStats returns the counters of tenant
+func (c *Cache) TenantStats(tenant string) Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p, ok := c.tenants[tenant]
+	if !ok {
+		return Stats{}
+	}
+	c.expire(p)
+	stats := p.stats
+	stats.Entries = int64(len(p.items))
+	stats.Bytes = p.bytes
+	return stats
+}
+
+// Stats implements cache.StatsProvider with the totals of every tenant
+func (c *Cache) Stats(ctx context.Context) (cache.Stats, error) {
+	if err := ctx.Err(); err != nil {
+		return cache.Stats{}, err
+	}
+
+	var total cache.Stats
+	for _, tenant := range c.Tenants() {
+		stats := c.TenantStats(tenant)
+		total.Hits += stats.Hits
+		total.Misses += stats.Misses
+		total.Sets += stats.Sets
+		total.Deletes += stats.Deletes
+		total.Evictions += stats.Evictions
+		total.Entries += stats.Entries
+	}
+	return total, nil
+}
+
+// tenant returns the tenant of ctx
+func (c *Cache) tenant(ctx context.Context) (string, error) {
+	if tenant, ok := FromContext(ctx); ok {
+		return tenant, nil
+	}
+	if c.options.DefaultTenant != "" {
+		return c.options.DefaultTenant, nil
+	}
+	return "", ErrNoTenant
+}
+
+// key returns the key of key in the key space of tenant. The tenant is
+// escaped so that no tenant can address the keys of another.
+func (c *Cache) key(tenant, key string) string {
+	return c.options.Prefix + url.QueryEscape(tenant) + ":" + key
+}
+
+// escapePattern escapes the wildcards of cache.Match in s
+func escapePattern(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func (c *Cache) quota(tenant string) Quota {
+	if quota, ok := c.options.Quotas[tenant]; ok {
+		return quota
+	}
+	return c.options.Quota
+}
+
+// admit makes sure tenant has a partition, forgetting tenants without
+// entries when MaxTenants is reached. It reports false when there is no
+// room. Callers must hold c.mu.
+func (c *Cache) admit(tenant string) bool {
+	if _, ok := c.tenants[tenant]; ok {
+		return true
+	}
+	if len(c.tenants) >= c.options.MaxTenants {
+		for id, p := range c.tenants {
+			if c.expire(p); p.lru.Len() == 0 {
+				delete(c.tenants, id)
+			}
+		}
+		if len(c.tenants) >= c.options.MaxTenants {
+			return false
+		}
+	}
+	c.partition(tenant)
+	return true
+}
+
+// partition returns the state of tenant, creating it if needed; callers
+// must hold c.mu
+func (c *Cache) partition(tenant string) *partition {
+	p, ok := c.tenants[tenant]
+	if !ok {
+		p = &partition{items: make(map[string]*list.Element), lru: list.New()}
+		c.tenants[tenant] = p
+	}
+	return p
+}
+
+// forget drops an entry from the bookkeeping; callers must hold c.mu
+func (c *Cache) forget(p *partition, el *list.Element) {
+	c.entries--
+	c.bytes -= el.Value.(*entry).size
+	p.remove(el)
+}
+
+// expire forgets the entries of p whose TTL passed; callers must hold c.mu
+func (c *Cache) expire(p *partition) {
+	now := c.now()
+	for el := p.lru.Back(); el != nil; {
+		prev := el.Prev()
+		if e := el.Value.(*entry); !e.expiresAt.IsZero() && !now.Before(e.expiresAt) {
+			c.forget(p, el)
+		}
+		el = prev
+	}
+}
+
+// makeRoom picks the entries to evict after tenant wrote to p: its own least
+// recently used ones while it is over quota, then those of the largest
+// tenants while all of them are over the global limits. The entry just
+// written is never picked. Callers must hold c.mu.
+func (c *Cache) makeRoom(tenant string, p *partition, quota Quota) []victim {
+	var victims []victim
+	take := func(tenant string, p *partition) {
+		el := p.lru.Back()
+		victims = append(victims, victim{tenant: tenant, key: el.Value.(*entry).key})
+		p.stats.Evictions++
+		c.forget(p, el)
+	}
+
+	over := func(entries int, bytes int64, max int, maxBytes int64) bool {
+		return (max > 0 && entries > max) || (maxBytes > 0 && bytes > maxBytes)
+	}
+	if over(len(p.items), p.bytes, quota.MaxEntries, quota.MaxBytes) {
+		c.expire(p)
+	}
+	for p.lru.Len() > 1 && over(len(p.items), p.bytes, quota.MaxEntries, quota.MaxBytes) {
+		take(tenant, p)
+	}
+
+	if !over(c.entries, c.bytes, c.options.MaxEntries, c.options.MaxBytes) {
+		return victims
+	}
+	for _, p := range c.tenants {
+		c.expire(p)
+	}
+	for over(c.entries, c.bytes, c.options.MaxEntries, c.options.MaxBytes) {
+		largest, lp := c.largest(p)
+		if lp == nil {
+			break
+		}
+		take(largest, lp)
+	}
+	return victims
+}
+
+// largest returns the tenant using the largest share of the global limits,
+// leaving out the entry just written to wp; callers must hold c.mu
+func (c *Cache) largest(wp *partition) (string, *partition) {
+	var largest string
+	var lp *partition
+	var most float64
+	for tenant, p := range c.tenants {
+		n := p.lru.Len()
+		if p == wp {
+			n--
+		}
+		if n == 0 {
+			continue
+		}
+		// Compare by whichever limit is the tightest
+		share := 0.0
+		if c.options.MaxEntries > 0 {
+			share = float64(len(p.items)) / float64(c.options.MaxEntries)
+		}
+		if c.options.MaxBytes > 0 {
+			share = max(share, float64(p.bytes)/float64(c.options.MaxBytes))
+		}
+		if lp == nil || share > most || (share == most && tenant < largest) {
+			largest, lp, most = tenant, p, share
+		}
+	}
+	return largest, lp
+}
+
+// evict deletes the victims from the wrapped cache
+func (c *Cache) evict(ctx context.Context, victims []victim) error {
+	var errs []error
+	for _, v := range victims {
+		if err := c.next.Delete(ctx, c.key(v.tenant, v.key)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sizeOf estimates the size of an entry as stored by the wrapped cache
+func sizeOf(key string, value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case []byte:
+		return int64(len(key) + len(v)), nil
+	case string:
+		return int64(len(key) + len(v)), nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(key) + len(data)), nil
+}
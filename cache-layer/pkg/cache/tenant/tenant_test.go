@@ -0,0 +1,337 @@
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/cachetest"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCache(t *testing.T, options *Options) (*Cache, cache.Cache) {
+	next, err := memory.New(&cache.Options{DefaultTTL: time.Hour, MaxTTL: time.Hour})
+	assert.NoError(t, err)
+	c := New(next, options)
+	t.Cleanup(func() { _ = c.Close() })
+	return c, next
+}
+
+// present returns how many of key0..key<n-1> tenant still has
+func present(c *Cache, tenant string, n int) int {
+	ctx := WithTenant(context.Background(), tenant)
+	found := 0
+	for i := 0; i < n; i++ {
+		if v, _ := c.Get(ctx, fmt.Sprintf("key%d", i)); v != nil {
+			found++
+		}
+	}
+	return found
+}
+
+func TestCache_Contract(t *testing.T) {
+	cachetest.Run(t, cachetest.Suite{
+		New: func(t *testing.T, options *cache.Options) cache.Cache {
+			next, err := memory.New(options)
+			assert.NoError(t, err)
+			return New(next, &Options{DefaultTenant: "default"})
+		},
+		Bounded: true,
+	})
+}
+
+func TestCache_Isolation(t *testing.T) {
+	c, next := newTestCache(t, nil)
+	ctx := context.Background()
+	a, b := WithTenant(ctx, "a"), WithTenant(ctx, "b")
+
+	assert.NoError(t, c.Set(a, "key", "a", 0))
+	assert.NoError(t, c.Set(b, "key", "b", 0))
+
+	v, err := c.Get(a, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", v)
+	v, err = c.Get(b, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", v)
+
+	assert.NoError(t, c.Delete(a, "key"))
+	v, _ = c.Get(a, "key")
+	assert.Nil(t, v)
+	v, _ = c.Get(b, "key")
+	assert.Equal(t, "b", v)
+
+	t.Run("no tenant", func(t *testing.T) {
+		_, err := c.Get(ctx, "key")
+		assert.ErrorIs(t, err, ErrNoTenant)
+		assert.ErrorIs(t, c.Set(ctx, "key", "value", 0), ErrNoTenant)
+		assert.ErrorIs(t, c.Delete(ctx, "key"), ErrNoTenant)
+	})
+
+	t.Run("escaped tenants", func(t *testing.T) {
+		assert.NoError(t, c.Set(WithTenant(ctx, "x:y"), "z", "first", 0))
+		assert.NoError(t, c.Set(WithTenant(ctx, "x"), "y:z", "second", 0))
+
+		v, _ := c.Get(WithTenant(ctx, "x:y"), "z")
+		assert.Equal(t, "first", v)
+		v, _ = next.Get(ctx, "tenant:x%3Ay:z")
+		assert.Equal(t, "first", v)
+	})
+}
+
+func TestCache_Quota(t *testing.T) {
+	c, _ := newTestCache(t, &Options{
+		Quota:  Quota{MaxEntries: 3},
+		Quotas: map[string]Quota{"big": {MaxEntries: 100}},
+	})
+	ctx := context.Background()
+	noisy, quiet, big := WithTenant(ctx, "noisy"), WithTenant(ctx, "quiet"), WithTenant(ctx, "big")
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, c.Set(quiet, fmt.Sprintf("key%d", i), i, 0))
+	}
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, c.Set(noisy, fmt.Sprintf("key%d", i), i, 0))
+		// Reads keep key0 the most recently used
+		_, _ = c.Get(noisy, "key0")
+	}
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, c.Set(big, fmt.Sprintf("key%d", i), i, 0))
+	}
+
+	assert.Equal(t, 3, present(c, "quiet", 3))
+	assert.Equal(t, 3, present(c, "noisy", 10))
+	assert.Equal(t, 10, present(c, "big", 10))
+	v, _ := c.Get(noisy, "key0")
+	assert.Equal(t, 0, v)
+
+	stats := c.TenantStats("noisy")
+	assert.Equal(t, int64(3), stats.Entries)
+	assert.Equal(t, uint64(7), stats.Evictions)
+	assert.Equal(t, uint64(0), c.TenantStats("quiet").Evictions)
+
+	t.Run("bytes", func(t *testing.T) {
+		c, _ := newTestCache(t, &Options{Quota: Quota{MaxBytes: 100}})
+		ctx := WithTenant(ctx, "a")
+		value := []byte(strings.Repeat("x", 36))
+
+		// Each entry takes 4 bytes of key and 36 of value
+		for i := 0; i < 5; i++ {
+			assert.NoError(t, c.Set(ctx, fmt.Sprintf("key%d", i), value, 0))
+		}
+		assert.Equal(t, 2, present(c, "a", 5))
+		assert.Equal(t, int64(80), c.TenantStats("a").Bytes)
+
+		// Values that can never fit are not stored, and evict nothing
+		assert.NoError(t, c.Set(ctx, "huge", make([]byte, 200), 0))
+		v, _ := c.Get(ctx, "huge")
+		assert.Nil(t, v)
+		assert.Equal(t, int64(2), c.TenantStats("a").Entries)
+
+		// Overwriting with one drops the stale value
+		assert.NoError(t, c.Set(ctx, "key4", make([]byte, 200), 0))
+		v, _ = c.Get(ctx, "key4")
+		assert.Nil(t, v)
+		assert.Equal(t, int64(1), c.TenantStats("a").Entries)
+		assert.Equal(t, int64(40), c.TenantStats("a").Bytes)
+	})
+}
+
+func TestCache_FairEviction(t *testing.T) {
+	c, _ := newTestCache(t, &Options{MaxEntries: 6})
+	ctx := context.Background()
+
+	for _, tenant := range []string{"a", "b"} {
+		for i := 0; i < 2; i++ {
+			assert.NoError(t, c.Set(WithTenant(ctx, tenant), fmt.Sprintf("key%d", i), i, 0))
+		}
+	}
+	for i := 0; i < 20; i++ {
+		assert.NoError(t, c.Set(WithTenant(ctx, "noisy"), fmt.Sprintf("key%d", i), i, 0))
+	}
+
+	// The noisy tenant only displaced its own entries
+	assert.Equal(t, 2, present(c, "a", 2))
+	assert.Equal(t, 2, present(c, "b", 2))
+	assert.Equal(t, 2, present(c, "noisy", 20))
+	assert.Equal(t, uint64(18), c.TenantStats("noisy").Evictions)
+
+	// Once every tenant holds an equal share, a growing tenant is the
+	// largest and replaces its own entries
+	for i := 2; i < 4; i++ {
+		assert.NoError(t, c.Set(WithTenant(ctx, "a"), fmt.Sprintf("key%d", i), i, 0))
+	}
+	assert.Equal(t, 2, present(c, "a", 4))
+	assert.Equal(t, 2, present(c, "b", 2))
+	assert.Equal(t, 2, present(c, "noisy", 20))
+
+	stats, err := c.Stats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), stats.Entries)
+}
+
+func TestCache_Expiry(t *testing.T) {
+	c, _ := newTestCache(t, &Options{Quota: Quota{MaxEntries: 2}})
+	ctx := WithTenant(context.Background(), "a")
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	assert.NoError(t, c.Set(ctx, "short", "value", time.Minute))
+	assert.NoError(t, c.Set(ctx, "long", "value", time.Hour))
+	now = now.Add(2 * time.Minute)
+
+	// The expired entry makes room instead of the live one
+	assert.NoError(t, c.Set(ctx, "new", "value", time.Hour))
+	v, _ := c.Get(ctx, "long")
+	assert.Equal(t, "value", v)
+	assert.Equal(t, uint64(0), c.TenantStats("a").Evictions)
+	assert.Equal(t, int64(2), c.TenantStats("a").Entries)
+
+	t.Run("default ttl", func(t *testing.T) {
+		c, _ := newTestCache(t, &Options{Quota: Quota{MaxEntries: 2}, DefaultTTL: time.Minute})
+		c.now = func() time.Time { return now }
+
+		assert.NoError(t, c.Set(ctx, "default", "value", 0))
+		assert.NoError(t, c.Set(ctx, "long", "value", time.Hour))
+		now = now.Add(2 * time.Minute)
+
+		assert.NoError(t, c.Set(ctx, "new", "value", time.Hour))
+		v, _ := c.Get(ctx, "long")
+		assert.Equal(t, "value", v)
+		assert.Equal(t, uint64(0), c.TenantStats("a").Evictions)
+	})
+}
+
+func TestCache_Purge(t *testing.T) {
+	c, next := newTestCache(t, nil)
+	ctx := context.Background()
+	a, b := WithTenant(ctx, "a"), WithTenant(ctx, "b")
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, c.Set(a, fmt.Sprintf("key%d", i), i, 0))
+		assert.NoError(t, c.Set(b, fmt.Sprintf("key%d", i), i, 0))
+	}
+
+	assert.NoError(t, c.Purge(ctx, "a"))
+	assert.Equal(t, 0, present(c, "a", 3))
+	assert.Equal(t, 3, present(c, "b", 3))
+	assert.Equal(t, int64(0), c.TenantStats("a").Entries)
+	assert.NoError(t, c.Purge(ctx, "unknown"))
+
+	t.Run("entries written by other processes", func(t *testing.T) {
+		other := New(next, nil)
+		assert.NoError(t, other.Set(a, "key0", 0, 0))
+		assert.NoError(t, other.Set(WithTenant(ctx, "a*"), "key0", 0, 0))
+
+		assert.NoError(t, c.Purge(ctx, "a"))
+		assert.Equal(t, 0, present(c, "a", 1))
+		assert.Equal(t, 1, present(other, "a*", 1))
+	})
+
+	t.Run("without a key lister", func(t *testing.T) {
+		c := New(struct{ cache.Cache }{next}, nil)
+		assert.NoError(t, c.Set(a, "key0", 0, 0))
+		assert.NoError(t, c.Purge(ctx, "a"))
+		assert.Equal(t, 0, present(c, "a", 1))
+	})
+
+	t.Run("clear with tenant", func(t *testing.T) {
+		// A write by one tenant may change what the others read
+		assert.NoError(t, c.Set(a, "key0", 0, 0))
+		assert.NoError(t, c.Set(b, "key0", 0, 0))
+		assert.NoError(t, c.Clear(b))
+		assert.Equal(t, 0, present(c, "a", 1))
+		assert.Equal(t, 0, present(c, "b", 1))
+		assert.Equal(t, int64(0), c.TenantStats("a").Entries)
+	})
+
+	t.Run("clear without tenant", func(t *testing.T) {
+		assert.NoError(t, next.Set(ctx, "shared", "value", 0))
+		assert.NoError(t, c.Clear(ctx))
+		assert.Equal(t, 0, present(c, "a", 1))
+		v, _ := next.Get(ctx, "shared")
+		assert.Nil(t, v)
+	})
+
+	assert.Equal(t, []string{"a", "b"}, c.Tenants())
+}
+
+func TestCache_Stats(t *testing.T) {
+	c, _ := newTestCache(t, nil)
+	ctx := context.Background()
+	a, b := WithTenant(ctx, "a"), WithTenant(ctx, "b")
+
+	assert.NoError(t, c.Set(a, "key", "value", 0))
+	_, _ = c.Get(a, "key")
+	_, _ = c.Get(a, "missing")
+	assert.NoError(t, c.Set(b, "key", "value", 0))
+	assert.NoError(t, c.Delete(b, "key"))
+
+	assert.Equal(t, Stats{
+		Stats: cache.Stats{Hits: 1, Misses: 1, Sets: 1, Entries: 1},
+		Bytes: int64(len("key") + len("value")),
+	}, c.TenantStats("a"))
+	assert.Equal(t, Stats{Stats: cache.Stats{Sets: 1, Deletes: 1}}, c.TenantStats("b"))
+	assert.Equal(t, Stats{}, c.TenantStats("unknown"))
+
+	stats, err := c.Stats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, cache.Stats{Hits: 1, Misses: 1, Sets: 2, Deletes: 1, Entries: 1}, stats)
+}
+
+func TestMiddleware(t *testing.T) {
+	var got string
+	var ok bool
+	valid := func(id string) bool { return id == "acme" }
+	handler := Middleware("X-Tenant-ID", valid)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok = FromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Tenant-ID", "acme")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.True(t, ok)
+	assert.Equal(t, "acme", got)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.False(t, ok)
+
+	req.Header.Set("X-Tenant-ID", "unknown")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.False(t, ok)
+}
+
+func TestCache_MaxTenants(t *testing.T) {
+	c, _ := newTestCache(t, &Options{MaxTenants: 2})
+	ctx := context.Background()
+
+	// Reads and deletes of unknown tenants leave no trace
+	for i := 0; i < 100; i++ {
+		tenant := WithTenant(ctx, fmt.Sprintf("reader%d", i))
+		v, _ := c.Get(tenant, "key")
+		assert.Nil(t, v)
+		assert.NoError(t, c.Delete(tenant, "key"))
+	}
+	assert.Empty(t, c.Tenants())
+
+	a, b, d := WithTenant(ctx, "a"), WithTenant(ctx, "b"), WithTenant(ctx, "d")
+	assert.NoError(t, c.Set(a, "key0", 0, 0))
+	assert.NoError(t, c.Set(b, "key0", 0, 0))
+
+	// Every tenant has entries, so a new one is not cached
+	assert.NoError(t, c.Set(d, "key0", 0, 0))
+	assert.Equal(t, 0, present(c, "d", 1))
+	assert.Equal(t, []string{"a", "b"}, c.Tenants())
+
+	// A tenant without entries makes room
+	assert.NoError(t, c.Delete(b, "key0"))
+	assert.NoError(t, c.Set(d, "key0", 0, 0))
+	assert.Equal(t, 1, present(c, "d", 1))
+	assert.Equal(t, []string{"a", "d"}, c.Tenants())
+}
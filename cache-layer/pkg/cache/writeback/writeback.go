@@ -0,0 +1,361 @@
+// Package writeback adds write-through and write-behind modes to a cache.Cache.
+// Writes are handed to a Store, the system of record, either synchronously
+// (write-through) or queued, coalesced per key and flushed in batches
+// (write-behind).
+package writeback
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+)
+
+// ErrClosed is returned by writes made after Close
+var ErrClosed = errors.New("cache: write-behind queue is closed")
+
+// Mode selects when writes reach the store
+type Mode int
+
+const (
+	// WriteThrough persists every write before it is cached
+	WriteThrough Mode = iota
+	// WriteBehind caches every write at once and persists it later in batches
+	WriteBehind
+)
+
+// Write is a single change to persist
+type Write struct {
+	Key   string
+	Value interface{}
+	// Deleted marks a key removed with Delete; Value is nil
+	Deleted bool
+}
+
+// Store persists writes to the system of record
+type Store interface {
+	Persist(ctx context.Context, writes []Write) error
+}
+
+// StoreFunc adapts a function to the Store interface
+type StoreFunc func(ctx context.Context, writes []Write) error
+
+// Persist implements Store
+func (f StoreFunc) Persist(ctx context.Context, writes []Write) error {
+	return f(ctx, writes)
+}
+
+// Options represents the configuration options for write-through and write-behind
+type Options struct {
+	// Mode selects write-through (default) or write-behind
+	Mode Mode
+	// FlushInterval is how often queued writes are flushed (defaults to 1 second)
+	FlushInterval time.Duration
+	// BatchSize is the maximum number of writes per Persist call; queueing this
+	// many distinct keys triggers a flush before the interval (defaults to 100)
+	BatchSize int
+	// MaxRetries is how many times a failed write is retried before it is
+	// dropped and reported to OnError (defaults to 5)
+	MaxRetries int
+	// RetryBackoff is the first pause between retries while draining on Close,
+	// doubled after every failed attempt (defaults to 100ms)
+	RetryBackoff time.Duration
+	// FlushTimeout bounds each Persist call made in the background (defaults to 10 seconds)
+	FlushTimeout time.Duration
+	// OnError is called with writes that could not be persisted after MaxRetries
+	OnError func(writes []Write, err error)
+}
+
+// pending is a queued write and the number of times it failed to persist
+type pending struct {
+	write    Write
+	failures int
+}
+
+// Cache is a cache.Cache decorator that persists writes to a Store
+type Cache struct {
+	next    cache.Cache
+	store   Store
+	options Options
+
+	mu     sync.Mutex
+	queue  map[string]*pending
+	order  []string // keys in the order they were first queued
+	closed bool
+
+	flushMu sync.Mutex // serializes flushes so writes to a key persist in order
+	kick    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+// New wraps next so that every write also reaches store
+func New(next cache.Cache, store Store, options *Options) *Cache {
+	opts := Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.MaxRetries <= 0 {
+		opts.MaxRetries = 5
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = 100 * time.Millisecond
+	}
+	if opts.FlushTimeout <= 0 {
+		opts.FlushTimeout = 10 * time.Second
+	}
+
+	c := &Cache{
+		next:    next,
+		store:   store,
+		options: opts,
+		queue:   make(map[string]*pending),
+		kick:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if opts.Mode == WriteBehind {
+		go c.flusher()
+	} else {
+		close(c.done)
+	}
+	return c
+}
+
+func (c *Cache) Get(ctx context.Context, key string) (interface{}, error) {
+	return c.next.Get(ctx, key)
+}
+
+func (c *Cache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	w := Write{Key: key, Value: value}
+	if c.options.Mode == WriteThrough {
+		if err := c.store.Persist(ctx, []Write{w}); err != nil {
+			return err
+		}
+		return c.next.Set(ctx, key, value, ttl)
+	}
+
+	if err := c.next.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	return c.enqueue(w)
+}
+
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	w := Write{Key: key, Deleted: true}
+	if c.options.Mode == WriteThrough {
+		if err := c.store.Persist(ctx, []Write{w}); err != nil {
+			return err
+		}
+		return c.next.Delete(ctx, key)
+	}
+
+	if err := c.next.Delete(ctx, key); err != nil {
+		return err
+	}
+	return c.enqueue(w)
+}
+
+// Clear only empties the cache; the store keeps its data and queued writes
+// are still persisted
+func (c *Cache) Clear(ctx context.Context) error {
+	return c.next.Clear(ctx)
+}
+
+// Close drains the write-behind queue, retrying failed writes, and then
+// closes the wrapped cache. Writes that still fail are reported to OnError
+// and returned as an error.
+func (c *Cache) Close() error {
+	var err error
+	c.once.Do(func() {
+		c.mu.Lock()
+		c.closed = true
+		c.mu.Unlock()
+
+		if c.options.Mode == WriteBehind {
+			close(c.stop)
+			<-c.done
+			err = c.drain()
+		}
+		err = errors.Join(err, c.next.Close())
+	})
+	return err
+}
+
+// Pending returns the number of queued writes not yet persisted
+func (c *Cache) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.queue)
+}
+
+// Flush persists every queued write now. Failed writes stay queued for the
+// next flush unless they have run out of retries.
+func (c *Cache) Flush(ctx context.Context) error {
+	_, err := c.flush(ctx)
+	return err
+}
+
+// flush persists every queued write and returns the number of writes dropped
+// after running out of retries along with the errors of failed batches
+func (c *Cache) flush(ctx context.Context) (int, error) {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	var errs []error
+	dropped := 0
+	for _, batch := range c.take() {
+		if err := c.store.Persist(ctx, writesOf(batch)); err != nil {
+			dropped += c.requeue(batch, err)
+			errs = append(errs, err)
+		}
+	}
+	return dropped, errors.Join(errs...)
+}
+
+// Stats implements cache.StatsProvider when the wrapped cache does
+func (c *Cache) Stats(ctx context.Context) (cache.Stats, error) {
+	provider, ok := c.next.(cache.StatsProvider)
+	if !ok {
+		return cache.Stats{}, cache.ErrNotSupported
+	}
+	return provider.Stats(ctx)
+}
+
+// enqueue queues a write, replacing any queued write of the same key
+func (c *Cache) enqueue(w Write) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	if p, ok := c.queue[w.Key]; ok {
+		p.write = w
+		p.failures = 0
+	} else {
+		c.queue[w.Key] = &pending{write: w}
+		c.order = append(c.order, w.Key)
+	}
+	full := len(c.queue) >= c.options.BatchSize
+	c.mu.Unlock()
+
+	if full {
+		select {
+		case c.kick <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// take empties the queue and splits it into batches
+func (c *Cache) take() [][]*pending {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var batches [][]*pending
+	var batch []*pending
+	for _, key := range c.order {
+		batch = append(batch, c.queue[key])
+		if len(batch) == c.options.BatchSize {
+			batches = append(batches, batch)
+			batch = nil
+		}
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+
+	c.queue = make(map[string]*pending)
+	c.order = nil
+	return batches
+}
+
+// requeue puts a failed batch back in the queue, except for keys written
+// again in the meantime and writes that have run out of retries, and returns
+// the number of writes dropped
+func (c *Cache) requeue(batch []*pending, err error) int {
+	var dropped []Write
+
+	c.mu.Lock()
+	retry := make([]string, 0, len(batch))
+	for _, p := range batch {
+		if _, ok := c.queue[p.write.Key]; ok {
+			continue
+		}
+		p.failures++
+		if p.failures > c.options.MaxRetries {
+			dropped = append(dropped, p.write)
+			continue
+		}
+		c.queue[p.write.Key] = p
+		retry = append(retry, p.write.Key)
+	}
+	// Retried writes go first so that they are not starved by newer keys
+	c.order = append(retry, c.order...)
+	c.mu.Unlock()
+
+	if len(dropped) > 0 && c.options.OnError != nil {
+		c.options.OnError(dropped, err)
+	}
+	return len(dropped)
+}
+
+// flusher persists queued writes every FlushInterval or once a batch is full
+func (c *Cache) flusher() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.options.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.kick:
+		case <-c.stop:
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), c.options.FlushTimeout)
+		_ = c.Flush(ctx)
+		cancel()
+	}
+}
+
+// drain flushes until the queue is empty, backing off between failed
+// attempts, and returns the errors that caused writes to be dropped
+func (c *Cache) drain() error {
+	var errs []error
+	backoff := c.options.RetryBackoff
+	for c.Pending() > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), c.options.FlushTimeout)
+		dropped, err := c.flush(ctx)
+		cancel()
+
+		if dropped > 0 {
+			errs = append(errs, err)
+		}
+		if err != nil && c.Pending() > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func writesOf(batch []*pending) []Write {
+	writes := make([]Write, len(batch))
+	for i, p := range batch {
+		writes[i] = p.write
+	}
+	return writes
+}
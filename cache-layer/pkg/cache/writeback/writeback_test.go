@@ -0,0 +1,236 @@
+package writeback
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seokheejang/go/cache-layer/pkg/cache"
+	"github.com/seokheejang/go/cache-layer/pkg/cache/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+var errStore = errors.New("store unavailable")
+
+// fakeStore records every Persist call and fails while down is set
+type fakeStore struct {
+	mu      sync.Mutex
+	down    bool
+	batches [][]Write
+	data    map[string]interface{}
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{data: make(map[string]interface{})}
+}
+
+func (s *fakeStore) Persist(ctx context.Context, writes []Write) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return errStore
+	}
+	s.batches = append(s.batches, writes)
+	for _, w := range writes {
+		if w.Deleted {
+			delete(s.data, w.Key)
+		} else {
+			s.data[w.Key] = w.Value
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) setDown(down bool) {
+	s.mu.Lock()
+	s.down = down
+	s.mu.Unlock()
+}
+
+func (s *fakeStore) snapshot() (map[string]interface{}, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data := make(map[string]interface{}, len(s.data))
+	for k, v := range s.data {
+		data[k] = v
+	}
+	return data, len(s.batches)
+}
+
+func newBackend() cache.Cache {
+	return memory.NewGoCacheWrapper(&cache.Options{DefaultTTL: time.Minute})
+}
+
+func TestWriteThrough(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeStore()
+	c := New(newBackend(), store, nil)
+	defer c.Close()
+
+	assert.NoError(t, c.Set(ctx, "counter", 1, 0))
+	data, batches := store.snapshot()
+	assert.Equal(t, map[string]interface{}{"counter": 1}, data)
+	assert.Equal(t, 1, batches)
+
+	v, err := c.Get(ctx, "counter")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+
+	t.Run("failed persist is not cached", func(t *testing.T) {
+		store.setDown(true)
+		defer store.setDown(false)
+
+		assert.ErrorIs(t, c.Set(ctx, "counter", 2, 0), errStore)
+		v, err := c.Get(ctx, "counter")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, v)
+
+		assert.ErrorIs(t, c.Delete(ctx, "counter"), errStore)
+		_, err = c.Get(ctx, "counter")
+		assert.NoError(t, err)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		assert.NoError(t, c.Delete(ctx, "counter"))
+		data, _ := store.snapshot()
+		assert.Empty(t, data)
+		_, err := c.Get(ctx, "counter")
+		assert.ErrorIs(t, err, cache.ErrNotFound)
+	})
+}
+
+func TestWriteBehind_CoalescesPerKey(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeStore()
+	c := New(newBackend(), store, &Options{Mode: WriteBehind, FlushInterval: time.Hour})
+	defer c.Close()
+
+	for i := 1; i <= 10; i++ {
+		assert.NoError(t, c.Set(ctx, "counter", i, 0))
+	}
+	assert.NoError(t, c.Set(ctx, "session", "a", 0))
+	assert.NoError(t, c.Delete(ctx, "session"))
+
+	// The cache sees writes at once, the store only after a flush
+	v, err := c.Get(ctx, "counter")
+	assert.NoError(t, err)
+	assert.Equal(t, 10, v)
+	assert.Equal(t, 2, c.Pending())
+	_, batches := store.snapshot()
+	assert.Equal(t, 0, batches)
+
+	assert.NoError(t, c.Flush(ctx))
+	assert.Equal(t, 0, c.Pending())
+	assert.Equal(t, [][]Write{{
+		{Key: "counter", Value: 10},
+		{Key: "session", Deleted: true},
+	}}, store.batches)
+}
+
+func TestWriteBehind_FlushTriggers(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("interval", func(t *testing.T) {
+		store := newFakeStore()
+		c := New(newBackend(), store, &Options{Mode: WriteBehind, FlushInterval: 10 * time.Millisecond})
+		defer c.Close()
+
+		assert.NoError(t, c.Set(ctx, "k", "v", 0))
+		assert.Eventually(t, func() bool {
+			data, _ := store.snapshot()
+			return data["k"] == "v"
+		}, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("batch size", func(t *testing.T) {
+		store := newFakeStore()
+		c := New(newBackend(), store, &Options{Mode: WriteBehind, FlushInterval: time.Hour, BatchSize: 3})
+		defer c.Close()
+
+		for _, k := range []string{"a", "b", "c"} {
+			assert.NoError(t, c.Set(ctx, k, k, 0))
+		}
+		assert.Eventually(t, func() bool {
+			data, _ := store.snapshot()
+			return len(data) == 3
+		}, time.Second, 5*time.Millisecond)
+	})
+}
+
+func TestWriteBehind_Retries(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeStore()
+	var dropped []Write
+	c := New(newBackend(), store, &Options{
+		Mode:          WriteBehind,
+		FlushInterval: time.Hour,
+		MaxRetries:    2,
+		OnError:       func(writes []Write, err error) { dropped = append(dropped, writes...) },
+	})
+	defer c.Close()
+
+	store.setDown(true)
+	assert.NoError(t, c.Set(ctx, "a", 1, 0))
+	assert.NoError(t, c.Set(ctx, "b", 1, 0))
+
+	// Failed writes stay queued
+	assert.ErrorIs(t, c.Flush(ctx), errStore)
+	assert.Equal(t, 2, c.Pending())
+
+	// A newer write replaces the failed one and starts over
+	assert.NoError(t, c.Set(ctx, "b", 2, 0))
+	assert.ErrorIs(t, c.Flush(ctx), errStore)
+	assert.ErrorIs(t, c.Flush(ctx), errStore)
+	assert.Equal(t, []Write{{Key: "a", Value: 1}}, dropped)
+	assert.Equal(t, 1, c.Pending())
+
+	store.setDown(false)
+	assert.NoError(t, c.Flush(ctx))
+	data, _ := store.snapshot()
+	assert.Equal(t, map[string]interface{}{"b": 2}, data)
+}
+
+func TestWriteBehind_DrainsOnClose(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeStore()
+	c := New(newBackend(), store, &Options{
+		Mode:          WriteBehind,
+		FlushInterval: time.Hour,
+		BatchSize:     2,
+		RetryBackoff:  5 * time.Millisecond,
+	})
+
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		assert.NoError(t, c.Set(ctx, k, k, 0))
+	}
+
+	// The store recovers while Close is retrying
+	store.setDown(true)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		store.setDown(false)
+	}()
+
+	assert.NoError(t, c.Close())
+	data, _ := store.snapshot()
+	assert.Len(t, data, 5)
+	assert.Equal(t, 0, c.Pending())
+	assert.ErrorIs(t, c.Set(ctx, "f", "f", 0), ErrClosed)
+}
+
+func TestWriteBehind_CloseReportsDroppedWrites(t *testing.T) {
+	store := newFakeStore()
+	store.setDown(true)
+	c := New(newBackend(), store, &Options{
+		Mode:          WriteBehind,
+		FlushInterval: time.Hour,
+		MaxRetries:    1,
+		RetryBackoff:  time.Millisecond,
+	})
+
+	assert.NoError(t, c.Set(context.Background(), "a", 1, 0))
+	assert.ErrorIs(t, c.Close(), errStore)
+	assert.Equal(t, 0, c.Pending())
+}